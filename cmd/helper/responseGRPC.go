@@ -2,34 +2,26 @@ package helper
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	"google.golang.org/grpc"
 )
 
-// RespondWithErrorGRPC returns a properly formatted gRPC error.
-// It logs the error and returns a status error with the given code and message.
-func RespondWithErrorGRPC(ctx context.Context, code codes.Code, msg string, err error) error {
-	if err != nil {
-		log.Println(err)
+// RespondWithErrorGRPC logs an RPC error and returns it for the handler to send, errors outside
+// the rpcerr model are converted to it. Server errors are logged with their cause, client errors
+// only with their reason.
+func RespondWithErrorGRPC(ctx context.Context, err error) error {
+	method, ok := grpc.Method(ctx)
+	if !ok {
+		method = "unknown method"
 	}
 
-	if code > codes.Internal { // 5XX equivalent in gRPC
-		log.Printf("Responding with 5XX gRPC error: %s", msg)
+	e := rpcerr.Convert(err)
+	if rpcerr.IsServerError(e.Code) {
+		log.Printf("%s failed: %v", method, e)
+	} else {
+		log.Printf("%s rejected: %s (%s): %s", method, e.Code, e.Reason, e.Message)
 	}
-
-	type errorResponse struct {
-		NotificationServiceError string `json:"error"`
-	}
-
-	jsonBytes, err := json.Marshal(errorResponse{NotificationServiceError: msg})
-	if err != nil {
-		log.Printf("Error marshalling error JSON: %s", err)
-		return status.Errorf(codes.Internal, "Failed to marshal error response")
-	}
-
-	log.Printf("NotificationServiceError: %s, Code: %s", string(jsonBytes), code.String()) // Log the error
-	return status.Errorf(code, msg)
+	return e
 }
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"github.com/imhasandl/notification-service/internal/auth"
+	pb "github.com/imhasandl/notification-service/protos"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// method returns the full name of a NotificationService RPC
+func method(name string) string {
+	return "/" + pb.NotificationService_ServiceDesc.ServiceName + "/" + name
+}
+
+// AccessPolicy returns who may call each RPC, RPCs it doesn't list are denied. Users may only manage
+// their own devices, topics and engagement; sending notifications, campaigns and stats are for other
+// services. Any authenticated caller may use server reflection.
+func AccessPolicy() auth.Policy {
+	return auth.Policy{
+		reflectionv1.ServerReflection_ServerReflectionInfo_FullMethodName:      auth.Authenticated,
+		reflectionv1alpha.ServerReflection_ServerReflectionInfo_FullMethodName: auth.Authenticated,
+		method("SendNotification"):            auth.ServiceOnly,
+		method("SendToTopic"):                 auth.ServiceOnly,
+		method("SendToSegment"):               auth.ServiceOnly,
+		method("CreateCampaign"):              auth.ServiceOnly,
+		method("StartCampaign"):               auth.ServiceOnly,
+		method("PauseCampaign"):               auth.ServiceOnly,
+		method("CancelCampaign"):              auth.ServiceOnly,
+		method("GetCampaignStats"):            auth.ServiceOnly,
+		method("GetExperimentStats"):          auth.ServiceOnly,
+		method("GetEngagementStats"):          auth.ServiceOnly,
+		method("RegisterDeviceToken"):         auth.UserScoped,
+		method("DeleteDeviceToken"):           auth.UserScoped,
+		method("ListDeviceTokens"):            auth.UserScoped,
+		method("DeleteAllDeviceTokens"):       auth.UserScoped,
+		method("UpdateDeviceToken"):           auth.UserScoped,
+		method("TouchDeviceToken"):            auth.UserScoped,
+		method("SubscribeToTopic"):            auth.UserScoped,
+		method("UnsubscribeFromTopic"):        auth.UserScoped,
+		method("ReportExperimentEvent"):       auth.UserScoped,
+		method("ReportNotificationOpened"):    auth.UserScoped,
+		method("ReportNotificationDismissed"): auth.UserScoped,
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/helper"
+	"github.com/imhasandl/notification-service/internal/campaigns"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/routing"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	"github.com/imhasandl/notification-service/internal/segments"
+	pb "github.com/imhasandl/notification-service/protos"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// CreateCampaign handles requests to create a draft campaign sending a notification to a segment.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) CreateCampaign(ctx context.Context, req *pb.CreateCampaignRequest) (*pb.CreateCampaignResponse, error) {
+	if req.GetName() == "" {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.InvalidField("name", "is required"))
+	}
+
+	_, err := segments.Parse(req.GetSegment(), time.Now())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, invalidSegment(err))
+	}
+
+	var notification Notification
+	err = json.Unmarshal(req.GetNotification(), &notification)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, malformedJSON("notification", err))
+	}
+
+	err = notification.validate()
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, invalidNotification("notification", err))
+	}
+
+	// Store the effective pace so stats show what the campaign actually runs at
+	options := segments.Options{BatchSize: req.GetBatchSize(), Rate: req.GetRatePerSecond()}.WithDefaults()
+
+	createCampaignParams := database.CreateCampaignParams{
+		ID:            uuid.New(),
+		Name:          req.GetName(),
+		Notification:  req.GetNotification(),
+		Segment:       req.GetSegment(),
+		RatePerSecond: options.Rate,
+		BatchSize:     options.BatchSize,
+	}
+	if req.GetScheduledAt() != nil {
+		createCampaignParams.ScheduledAt = sql.NullTime{Time: req.GetScheduledAt().AsTime(), Valid: true}
+	}
+
+	campaign, err := s.db.CreateCampaign(ctx, createCampaignParams)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't create campaign"))
+	}
+
+	return &pb.CreateCampaignResponse{
+		Campaign: campaignToPB(campaign),
+	}, nil
+}
+
+// StartCampaign handles requests to start a draft campaign or resume a paused one. Campaigns
+// scheduled in the future wait for their time, the others are picked up on the next poll.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) StartCampaign(ctx context.Context, req *pb.StartCampaignRequest) (*pb.StartCampaignResponse, error) {
+	campaign, err := s.transitionCampaign(ctx, req.GetId(), s.db.StartCampaign)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.StartCampaignResponse{
+		Campaign: campaignToPB(campaign),
+	}, nil
+}
+
+// PauseCampaign handles requests to pause a scheduled or running campaign, it stops after the current batch.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) PauseCampaign(ctx context.Context, req *pb.PauseCampaignRequest) (*pb.PauseCampaignResponse, error) {
+	campaign, err := s.transitionCampaign(ctx, req.GetId(), s.db.PauseCampaign)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.PauseCampaignResponse{
+		Campaign: campaignToPB(campaign),
+	}, nil
+}
+
+// CancelCampaign handles requests to stop a campaign for good.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) CancelCampaign(ctx context.Context, req *pb.CancelCampaignRequest) (*pb.CancelCampaignResponse, error) {
+	campaign, err := s.transitionCampaign(ctx, req.GetId(), s.db.CancelCampaign)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CancelCampaignResponse{
+		Campaign: campaignToPB(campaign),
+	}, nil
+}
+
+// GetCampaignStats handles requests for the status and progress of a campaign.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) GetCampaignStats(ctx context.Context, req *pb.GetCampaignStatsRequest) (*pb.GetCampaignStatsResponse, error) {
+	id, err := parseID("id", req.GetId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	campaign, err := s.db.GetCampaign(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, campaignNotFound(id))
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't get campaign"))
+	}
+
+	return &pb.GetCampaignStatsResponse{
+		Campaign: campaignToPB(campaign),
+	}, nil
+}
+
+// transitionCampaign applies a status change, which only matches campaigns in an allowed status.
+// When nothing matched it tells a missing campaign apart from one in the wrong status.
+func (s *Server) transitionCampaign(ctx context.Context, rawID string, transition func(context.Context, uuid.UUID) (database.Campaign, error)) (database.Campaign, error) {
+	id, err := parseID("id", rawID)
+	if err != nil {
+		return database.Campaign{}, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	campaign, err := transition(ctx, id)
+	if err == nil {
+		return campaign, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.Campaign{}, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't update campaign"))
+	}
+
+	campaign, err = s.db.GetCampaign(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Campaign{}, helper.RespondWithErrorGRPC(ctx, campaignNotFound(id))
+	}
+	if err != nil {
+		return database.Campaign{}, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't get campaign"))
+	}
+	return database.Campaign{}, helper.RespondWithErrorGRPC(ctx, rpcerr.New(codes.FailedPrecondition, rpcerr.ReasonCampaignState, "campaign is "+campaign.Status).
+		WithMetadata("campaign_id", id.String()).
+		WithMetadata("status", campaign.Status))
+}
+
+// campaignSender parses a campaign's stored notification and routes it like SendToSegment does
+func campaignSender(dispatcher *routing.Dispatcher) campaigns.SenderFunc {
+	return func(payload []byte) (segments.SendFunc, error) {
+		var notification Notification
+		if err := json.Unmarshal(payload, &notification); err != nil {
+			return nil, err
+		}
+		if err := notification.validate(); err != nil {
+			return nil, err
+		}
+		return dispatchTo(dispatcher, notification), nil
+	}
+}
+
+// campaignToPB converts a database campaign into its protobuf representation
+func campaignToPB(campaign database.Campaign) *pb.Campaign {
+	return &pb.Campaign{
+		Id:            campaign.ID.String(),
+		Name:          campaign.Name,
+		Status:        campaign.Status,
+		ScheduledAt:   nullTimeToPB(campaign.ScheduledAt),
+		RatePerSecond: campaign.RatePerSecond,
+		BatchSize:     campaign.BatchSize,
+		Total:         campaign.Total,
+		Processed:     campaign.Processed,
+		Sent:          campaign.Sent,
+		Skipped:       campaign.Skipped,
+		Failed:        campaign.Failed,
+		LastError:     campaign.LastError,
+		CreatedAt:     timestamppb.New(campaign.CreatedAt),
+		UpdatedAt:     timestamppb.New(campaign.UpdatedAt),
+		StartedAt:     nullTimeToPB(campaign.StartedAt),
+		CompletedAt:   nullTimeToPB(campaign.CompletedAt),
+	}
+}
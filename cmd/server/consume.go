@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/imhasandl/notification-service/internal/broker"
+	"github.com/imhasandl/notification-service/internal/events"
+	"github.com/imhasandl/notification-service/internal/routing"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Consume processes the notification requests published to the broker until the context is cancelled.
+// Each message is decoded by its content type and schema and handled like a SendNotification request;
+// a message that fails is handed back to the broker to be delivered again, unless it can never succeed
+// because it is invalid or not signed by a producer allowed to send it, in which case it is dead-lettered.
+func (s *Server) Consume(ctx context.Context) error {
+	return s.broker.Consume(ctx, s.handleMessage)
+}
+
+// handleMessage decodes the notification carried by a consumed message and sends it
+func (s *Server) handleMessage(ctx context.Context, msg broker.Message) error {
+	log.Printf("Received message %s (%s)", msg.ID, msg.ContentType)
+
+	notification, err := s.decoders.Decode(msg)
+	if err != nil {
+		log.Printf("Failed to decode notification: %v", err)
+		return broker.Permanent(err)
+	}
+
+	if err := s.authorize(msg, notification); err != nil {
+		log.Printf("Rejected notification request %s: %v", msg.ID, err)
+		return broker.Permanent(err)
+	}
+
+	// Events about this notification carry the producer's correlation id, and a redelivered
+	// request is tracked under the same id
+	ctx = events.WithCorrelationID(ctx, msg.CorrelationID)
+	_, err = s.sendNotification(ctx, notification, msg.ID)
+	if err != nil {
+		log.Printf("Failed to send notification: %v", err)
+	}
+	if status.Code(err) == codes.InvalidArgument {
+		return broker.Permanent(err)
+	}
+	return err
+}
+
+// authorize checks that a known producer signed the message and may send the notification's category
+func (s *Server) authorize(msg broker.Message, notification Notification) error {
+	if s.verifier == nil {
+		return nil
+	}
+
+	category := notification.Category
+	if category == "" {
+		category = routing.DefaultCategory
+	}
+	return s.verifier.Verify(msg.Headers, msg.ContentType, msg.Body, category)
+}
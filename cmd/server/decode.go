@@ -0,0 +1,95 @@
+package server
+
+import (
+	"github.com/imhasandl/notification-service/internal/codec"
+	pb "github.com/imhasandl/notification-service/protos"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// NotificationV1 is the schema of the notification requests producers have always sent,
+	// it is assumed for messages that name neither a schema nor a schema_version
+	NotificationV1 = codec.Schema{Name: "notification", Version: 1}
+	// NotificationV2 is the current schema, the v1 shape with a schema_version and no unknown fields
+	NotificationV2 = codec.Schema{Name: "notification", Version: 2}
+)
+
+// translationRules describe a localized title and content
+var translationRules = codec.Property{
+	Type: codec.TypeObject,
+	Properties: map[string]codec.Property{
+		"title":   {Type: codec.TypeString},
+		"content": {Type: codec.TypeString},
+	},
+}
+
+// notificationRules describe a NotificationV2 payload
+var notificationRules = codec.Property{
+	Type:     codec.TypeObject,
+	Required: []string{codec.VersionField, "receiver_id"},
+	Properties: map[string]codec.Property{
+		codec.VersionField: {Type: codec.TypeInteger},
+		"title":            {Type: codec.TypeString},
+		"sender_username":  {Type: codec.TypeString},
+		"receiver_id":      {Type: codec.TypeString, Format: codec.FormatUUID},
+		"content":          {Type: codec.TypeString},
+		"sent_at":          {Type: codec.TypeString, Format: codec.FormatDateTime},
+		"category":         {Type: codec.TypeString},
+		"min_app_version":  {Type: codec.TypeString},
+		"translations":     {Type: codec.TypeObject, AdditionalProperties: &translationRules},
+		"experiment": {
+			Type:     codec.TypeObject,
+			Required: []string{"key", "variants"},
+			Properties: map[string]codec.Property{
+				"key": {Type: codec.TypeString},
+				"variants": {Type: codec.TypeArray, Items: &codec.Property{
+					Type:     codec.TypeObject,
+					Required: []string{"name", "weight"},
+					Properties: map[string]codec.Property{
+						"name":         {Type: codec.TypeString},
+						"weight":       {Type: codec.TypeInteger},
+						"title":        {Type: codec.TypeString},
+						"content":      {Type: codec.TypeString},
+						"translations": {Type: codec.TypeObject, AdditionalProperties: &translationRules},
+					},
+				}},
+			},
+		},
+	},
+}
+
+// newDecoders registers the reader of every encoding and the upcaster of every old schema version
+// producers may send
+func newDecoders() *codec.Registry[Notification] {
+	decoders := codec.NewRegistry[Notification](NotificationV2, notificationRules)
+	decoders.RegisterReader(codec.ContentTypeProtobuf, readProtobuf)
+	decoders.RegisterUpcaster(1, upcastV1)
+	return decoders
+}
+
+// readProtobuf reads a notification.Notification protobuf message as the JSON document of the
+// same shape, the message has no schema_version so it is read as whatever the schema header says
+func readProtobuf(data []byte) (codec.Document, error) {
+	var message pb.Notification
+	if err := proto.Unmarshal(data, &message); err != nil {
+		return nil, &codec.ValidationError{Fields: []codec.FieldError{{Message: "must be a notification.Notification message"}}}
+	}
+
+	doc, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(&message)
+	if err != nil {
+		return nil, err
+	}
+	return codec.ReadJSON(doc)
+}
+
+// upcastV1 converts a v1 notification, which was decoded leniently: nulls and fields v2 doesn't
+// know are dropped as v1 decoding ignored them
+func upcastV1(doc codec.Document) (codec.Document, error) {
+	for name, value := range doc {
+		if _, ok := notificationRules.Properties[name]; !ok || value == nil {
+			delete(doc, name)
+		}
+	}
+	return doc, nil
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/helper"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	pb "github.com/imhasandl/notification-service/protos"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// uniqueViolation is the postgres error code raised when a device token is already registered
+const uniqueViolation = "23505"
+
+// ListDeviceTokens handles requests to list every device registered for a user.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) ListDeviceTokens(ctx context.Context, req *pb.ListDeviceTokensRequest) (*pb.ListDeviceTokensResponse, error) {
+	userID, err := parseID("user_id", req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	deviceTokens, err := s.db.ListDeviceTokensByUserID(ctx, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't get device tokens"))
+	}
+
+	response := &pb.ListDeviceTokensResponse{
+		DeviceTokens: make([]*pb.DeviceToken, 0, len(deviceTokens)),
+	}
+	for _, deviceToken := range deviceTokens {
+		response.DeviceTokens = append(response.DeviceTokens, deviceTokenToPB(deviceToken))
+	}
+
+	return response, nil
+}
+
+// DeleteAllDeviceTokens handles requests to revoke every device of a user ("log out everywhere").
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) DeleteAllDeviceTokens(ctx context.Context, req *pb.DeleteAllDeviceTokensRequest) (*pb.DeleteAllDeviceTokensResponse, error) {
+	userID, err := parseID("user_id", req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	deleted, err := s.db.DeleteAllDeviceTokens(ctx, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't delete device tokens"))
+	}
+	s.topics.DevicesRemoved(ctx, userID, deleted...)
+
+	return &pb.DeleteAllDeviceTokensResponse{
+		Status:       true,
+		DeletedCount: int64(len(deleted)),
+	}, nil
+}
+
+// UpdateDeviceToken handles requests to replace the token or type of a registered device,
+// e.g. when FCM rotates the token of an installed app.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) UpdateDeviceToken(ctx context.Context, req *pb.UpdateDeviceTokenRequest) (*pb.UpdateDeviceTokenResponse, error) {
+	id, err := parseID("id", req.GetId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	userID, err := parseID("user_id", req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	updateDeviceTokenParams := database.UpdateDeviceTokenParams{
+		ID:          id,
+		UserID:      userID,
+		DeviceToken: req.GetDeviceToken(),
+		DeviceType:  req.GetDeviceType(),
+	}
+
+	deviceToken, err := s.db.UpdateDeviceToken(ctx, updateDeviceTokenParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.NotFound(rpcerr.ReasonDeviceNotFound, "device token not found").WithCause(err))
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.New(codes.AlreadyExists, rpcerr.ReasonAlreadyExists, "device token is registered to another device").
+			WithMetadata("field", "device_token").
+			WithCause(err))
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't update device token"))
+	}
+	// The replaced token is no longer valid, so FCM drops its subscriptions on its own
+	s.topics.DevicesAdded(ctx, userID, deviceToken.DeviceToken)
+
+	return &pb.UpdateDeviceTokenResponse{
+		DeviceToken: deviceTokenToPB(deviceToken),
+	}, nil
+}
+
+// TouchDeviceToken handles heartbeats sent by apps on launch. It marks the device as seen
+// and re-enables it if the janitor disabled it for being stale.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) TouchDeviceToken(ctx context.Context, req *pb.TouchDeviceTokenRequest) (*pb.TouchDeviceTokenResponse, error) {
+	userID, err := parseID("user_id", req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	touchDeviceTokenParams := database.TouchDeviceTokenParams{
+		UserID:      userID,
+		DeviceToken: req.GetDeviceToken(),
+	}
+
+	deviceToken, err := s.db.TouchDeviceToken(ctx, touchDeviceTokenParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.NotFound(rpcerr.ReasonDeviceNotFound, "device token not found").WithCause(err))
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't update device token"))
+	}
+
+	return &pb.TouchDeviceTokenResponse{
+		DeviceToken: deviceTokenToPB(deviceToken),
+	}, nil
+}
+
+// registration is the outcome of registering a device token
+type registration struct {
+	deviceToken database.DeviceToken
+	// previousUserID is set when the token was taken over from another user
+	previousUserID uuid.NullUUID
+	// evicted holds the devices removed to keep the user within the device limit
+	evicted []database.DeviceToken
+}
+
+// registerDeviceToken registers the token for the user inside a transaction. If the token is
+// registered to another user it is moved to the new user and the transfer is recorded. When the
+// user then has more than maxDevices active devices the least recently seen ones are evicted.
+func registerDeviceToken(ctx context.Context, q database.Querier, params database.RegisterDeviceTokenParams, maxDevices int32) (registration, error) {
+	deviceToken, previousUserID, err := upsertDeviceToken(ctx, q, params)
+	if err != nil {
+		return registration{}, err
+	}
+
+	result := registration{deviceToken: deviceToken, previousUserID: previousUserID}
+	if maxDevices <= 0 {
+		return result, nil
+	}
+
+	// Keep the registered device plus the most recently seen of the others
+	result.evicted, err = q.EvictDeviceTokens(ctx, database.EvictDeviceTokensParams{
+		UserID: deviceToken.UserID,
+		ID:     deviceToken.ID,
+		Offset: maxDevices - 1,
+	})
+	if err != nil {
+		return registration{}, err
+	}
+
+	return result, nil
+}
+
+// upsertDeviceToken inserts the token or claims the existing row for the user, returning the previous owner if it changed
+func upsertDeviceToken(ctx context.Context, q database.Querier, params database.RegisterDeviceTokenParams) (database.DeviceToken, uuid.NullUUID, error) {
+	// Two attempts are enough: if the insert loses a race the winner's row is visible to the second lock
+	for attempt := 0; attempt < 2; attempt++ {
+		existing, err := q.LockDeviceToken(ctx, params.DeviceToken)
+		if err == nil {
+			return claimDeviceToken(ctx, q, existing, params)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return database.DeviceToken{}, uuid.NullUUID{}, err
+		}
+
+		inserted, err := q.RegisterDeviceToken(ctx, params)
+		if err == nil {
+			return inserted, uuid.NullUUID{}, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return database.DeviceToken{}, uuid.NullUUID{}, err
+		}
+	}
+
+	return database.DeviceToken{}, uuid.NullUUID{}, errors.New("device token is being registered concurrently")
+}
+
+// claimDeviceToken updates a locked device token row for the registering user, recording a transfer if it changes owner
+func claimDeviceToken(ctx context.Context, q database.Querier, existing database.DeviceToken, params database.RegisterDeviceTokenParams) (database.DeviceToken, uuid.NullUUID, error) {
+	var previousUserID uuid.NullUUID
+	if existing.UserID != params.UserID {
+		err := q.CreateDeviceTokenTransfer(ctx, database.CreateDeviceTokenTransferParams{
+			ID:          uuid.New(),
+			DeviceToken: existing.DeviceToken,
+			FromUserID:  existing.UserID,
+			ToUserID:    params.UserID,
+		})
+		if err != nil {
+			return database.DeviceToken{}, uuid.NullUUID{}, err
+		}
+		previousUserID = uuid.NullUUID{UUID: existing.UserID, Valid: true}
+	}
+
+	claimed, err := q.ClaimDeviceToken(ctx, database.ClaimDeviceTokenParams{
+		ID:             existing.ID,
+		UserID:         params.UserID,
+		DeviceType:     params.DeviceType,
+		AppVersion:     params.AppVersion,
+		OsVersion:      params.OsVersion,
+		Locale:         params.Locale,
+		Timezone:       params.Timezone,
+		DeviceModel:    params.DeviceModel,
+		PushPermission: params.PushPermission,
+	})
+	if err != nil {
+		return database.DeviceToken{}, uuid.NullUUID{}, err
+	}
+
+	return claimed, previousUserID, nil
+}
+
+// deviceTokenToPB converts a database device token into its protobuf representation
+func deviceTokenToPB(deviceToken database.DeviceToken) *pb.DeviceToken {
+	return &pb.DeviceToken{
+		Id:             deviceToken.ID.String(),
+		UserId:         deviceToken.UserID.String(),
+		DeviceToken:    deviceToken.DeviceToken,
+		DeviceType:     deviceToken.DeviceType,
+		CreatedAt:      timestamppb.New(deviceToken.CreatedAt),
+		UpdatedAt:      timestamppb.New(deviceToken.UpdatedAt),
+		AppVersion:     deviceToken.AppVersion,
+		OsVersion:      deviceToken.OsVersion,
+		Locale:         deviceToken.Locale,
+		Timezone:       deviceToken.Timezone,
+		DeviceModel:    deviceToken.DeviceModel,
+		PushPermission: deviceToken.PushPermission,
+		LastSeenAt:     timestamppb.New(deviceToken.LastSeenAt),
+		DisabledAt:     nullTimeToPB(deviceToken.DisabledAt),
+	}
+}
+
+// nullTimeToPB converts an optional database timestamp, leaving it unset when null
+func nullTimeToPB(t sql.NullTime) *timestamppb.Timestamp {
+	if !t.Valid {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
+
+// pushPermission normalizes the permission status reported by the app, defaulting to unknown
+func pushPermission(permission string) string {
+	permission = strings.ToLower(strings.TrimSpace(permission))
+	if permission == "" {
+		return "unknown"
+	}
+	return permission
+}
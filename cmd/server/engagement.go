@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/helper"
+	"github.com/imhasandl/notification-service/internal/experiments"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	pb "github.com/imhasandl/notification-service/protos"
+)
+
+// ReportNotificationOpened handles requests from apps reporting that the user opened a notification.
+// Opening an experiment notification also counts as an open of its variant.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) ReportNotificationOpened(ctx context.Context, req *pb.ReportNotificationOpenedRequest) (*pb.ReportNotificationOpenedResponse, error) {
+	trackingID, userID, err := parseTracking(req.GetTrackingId(), req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	err = s.engagement.Opened(ctx, trackingID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.NotFound(rpcerr.ReasonNotificationNotFound, "notification not found").WithCause(err))
+	}
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't record open"))
+	}
+
+	// Most notifications aren't part of an experiment, so finding no assignment is expected
+	_, err = s.experiments.Engage(ctx, trackingID, userID, experiments.EventOpened)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't record experiment open"))
+	}
+
+	return &pb.ReportNotificationOpenedResponse{
+		Status: true,
+	}, nil
+}
+
+// ReportNotificationDismissed handles requests from apps reporting that the user dismissed a notification.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) ReportNotificationDismissed(ctx context.Context, req *pb.ReportNotificationDismissedRequest) (*pb.ReportNotificationDismissedResponse, error) {
+	trackingID, userID, err := parseTracking(req.GetTrackingId(), req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	found, err := s.engagement.Dismissed(ctx, trackingID, userID)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't record dismissal"))
+	}
+	if !found {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.NotFound(rpcerr.ReasonNotificationNotFound, "notification not found"))
+	}
+
+	return &pb.ReportNotificationDismissedResponse{
+		Status: true,
+	}, nil
+}
+
+// GetEngagementStats handles requests for open and dismiss rates per notification category.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) GetEngagementStats(ctx context.Context, req *pb.GetEngagementStatsRequest) (*pb.GetEngagementStatsResponse, error) {
+	var since time.Time
+	if req.GetSince() != nil {
+		since = req.GetSince().AsTime()
+	}
+
+	stats, err := s.engagement.Stats(ctx, since)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't get engagement stats"))
+	}
+
+	response := &pb.GetEngagementStatsResponse{
+		Categories: make([]*pb.CategoryEngagement, 0, len(stats)),
+	}
+	for _, category := range stats {
+		response.Categories = append(response.Categories, &pb.CategoryEngagement{
+			Category:    category.Category,
+			Delivered:   category.Delivered,
+			Opened:      category.Opened,
+			Dismissed:   category.Dismissed,
+			OpenRate:    category.OpenRate,
+			DismissRate: category.DismissRate,
+		})
+	}
+
+	return response, nil
+}
+
+// parseTracking parses the tracking id of a notification and the user reporting on it
+func parseTracking(rawTrackingID, rawUserID string) (uuid.UUID, uuid.UUID, error) {
+	trackingID, err := parseID("tracking_id", rawTrackingID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	userID, err := parseID("user_id", rawUserID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	return trackingID, userID, nil
+}
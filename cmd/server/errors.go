@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/experiments"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	"github.com/imhasandl/notification-service/internal/topics"
+	"google.golang.org/grpc/codes"
+)
+
+// parseID parses a UUID request field, the error names the field
+func parseID(field, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, rpcerr.InvalidField(field, "must be a UUID").WithCause(err)
+	}
+	return id, nil
+}
+
+// malformedJSON reports a bytes field that doesn't hold valid JSON
+func malformedJSON(field string, err error) error {
+	return rpcerr.InvalidArgument(rpcerr.ReasonMalformedJSON, field+" is not valid JSON",
+		rpcerr.FieldViolation{Field: field, Description: err.Error()}).WithCause(err)
+}
+
+// invalidNotification reports a notification that parsed but failed validation
+func invalidNotification(field string, err error) error {
+	return rpcerr.InvalidArgument(rpcerr.ReasonInvalidNotification, "invalid notification: "+err.Error(),
+		rpcerr.FieldViolation{Field: field, Description: err.Error()}).WithCause(err)
+}
+
+// invalidSegment reports a segment filter that doesn't compile
+func invalidSegment(err error) error {
+	return rpcerr.InvalidArgument(rpcerr.ReasonInvalidSegment, "invalid segment: "+err.Error(),
+		rpcerr.FieldViolation{Field: "segment", Description: err.Error()}).WithCause(err)
+}
+
+// campaignNotFound reports a campaign id that doesn't exist
+func campaignNotFound(id uuid.UUID) error {
+	return rpcerr.NotFound(rpcerr.ReasonCampaignNotFound, "campaign not found").WithMetadata("campaign_id", id.String())
+}
+
+// topicError maps the errors of the topic manager, FCM errors are classified by rpcerr
+func topicError(err error, message string) error {
+	switch {
+	case errors.Is(err, topics.ErrInvalidTopic):
+		return rpcerr.InvalidArgument(rpcerr.ReasonInvalidTopic, message,
+			rpcerr.FieldViolation{Field: "topic", Description: topics.ErrInvalidTopic.Error()}).WithCause(err)
+	case errors.Is(err, topics.ErrFirebaseUnavailable):
+		return rpcerr.New(codes.Unavailable, rpcerr.ReasonPushProviderUnavailable, message).WithCause(err)
+	}
+	return rpcerr.FromError(err, message)
+}
+
+// experimentError maps the errors of recording an experiment event
+func experimentError(err error, message string) error {
+	if errors.Is(err, experiments.ErrInvalidEvent) {
+		return rpcerr.InvalidArgument(rpcerr.ReasonInvalidEvent, message,
+			rpcerr.FieldViolation{Field: "event", Description: err.Error()}).WithCause(err)
+	}
+	return rpcerr.FromError(err, message)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+
+	"github.com/imhasandl/notification-service/cmd/helper"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	pb "github.com/imhasandl/notification-service/protos"
+)
+
+// ReportExperimentEvent handles requests from apps reporting that a user opened or clicked
+// the content of an A/B experiment.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) ReportExperimentEvent(ctx context.Context, req *pb.ReportExperimentEventRequest) (*pb.ReportExperimentEventResponse, error) {
+	messageID, err := parseID("message_id", req.GetMessageId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	userID, err := parseID("user_id", req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	found, err := s.experiments.Engage(ctx, messageID, userID, req.GetEvent())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, experimentError(err, "can't record experiment event"))
+	}
+	if !found {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.NotFound(rpcerr.ReasonExperimentNotFound, "experiment message not found"))
+	}
+
+	return &pb.ReportExperimentEventResponse{
+		Status: true,
+	}, nil
+}
+
+// GetExperimentStats handles requests for the sends, opens and clicks of every variant of an experiment.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) GetExperimentStats(ctx context.Context, req *pb.GetExperimentStatsRequest) (*pb.GetExperimentStatsResponse, error) {
+	if req.GetExperiment() == "" {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.InvalidField("experiment", "is required"))
+	}
+
+	stats, err := s.experiments.Stats(ctx, req.GetExperiment())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't get experiment stats"))
+	}
+
+	response := &pb.GetExperimentStatsResponse{
+		Variants: make([]*pb.VariantStats, 0, len(stats)),
+	}
+	for _, variant := range stats {
+		response.Variants = append(response.Variants, &pb.VariantStats{
+			Variant:   variant.Variant,
+			Sent:      variant.Sent,
+			Opened:    variant.Opened,
+			Clicked:   variant.Clicked,
+			OpenRate:  variant.OpenRate,
+			ClickRate: variant.ClickRate,
+		})
+	}
+
+	return response, nil
+}
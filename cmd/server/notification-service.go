@@ -3,35 +3,113 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"time"
+	"unicode/utf8"
 
-	"firebase.google.com/go/v4/messaging"
 	"github.com/google/uuid"
 	"github.com/imhasandl/notification-service/cmd/helper"
+	"github.com/imhasandl/notification-service/internal/broker"
+	"github.com/imhasandl/notification-service/internal/campaigns"
+	"github.com/imhasandl/notification-service/internal/codec"
 	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/engagement"
+	"github.com/imhasandl/notification-service/internal/experiments"
 	"github.com/imhasandl/notification-service/internal/firebase"
-	"github.com/imhasandl/notification-service/internal/rabbitmq"
+	"github.com/imhasandl/notification-service/internal/hub"
+	"github.com/imhasandl/notification-service/internal/routing"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	"github.com/imhasandl/notification-service/internal/signing"
+	"github.com/imhasandl/notification-service/internal/topics"
 	pb "github.com/imhasandl/notification-service/protos"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // DBQuerier defines the interface for database operations required by the notification service
 type DBQuerier interface {
-	GetDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) (string, error)
+	GetDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) (database.DeviceToken, error)
 	RegisterDeviceToken(ctx context.Context, arg database.RegisterDeviceTokenParams) (database.DeviceToken, error)
 	DeleteDeviceToken(ctx context.Context, arg database.DeleteDeviceTokenParams) error
+	ListDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) ([]database.DeviceToken, error)
+	DeleteAllDeviceTokens(ctx context.Context, userID uuid.UUID) ([]string, error)
+	UpdateDeviceToken(ctx context.Context, arg database.UpdateDeviceTokenParams) (database.DeviceToken, error)
+	TouchDeviceToken(ctx context.Context, arg database.TouchDeviceTokenParams) (database.DeviceToken, error)
+	CreateTopicSubscription(ctx context.Context, arg database.CreateTopicSubscriptionParams) error
+	DeleteTopicSubscription(ctx context.Context, arg database.DeleteTopicSubscriptionParams) error
+	ListTopicsByUserID(ctx context.Context, userID uuid.UUID) ([]string, error)
+	CountSegmentUsers(ctx context.Context, filter database.SegmentFilter) (int64, error)
+	ListSegmentUsers(ctx context.Context, filter database.SegmentFilter, after uuid.UUID, limit int32) ([]uuid.UUID, error)
+	CreateCampaign(ctx context.Context, arg database.CreateCampaignParams) (database.Campaign, error)
+	GetCampaign(ctx context.Context, id uuid.UUID) (database.Campaign, error)
+	StartCampaign(ctx context.Context, id uuid.UUID) (database.Campaign, error)
+	PauseCampaign(ctx context.Context, id uuid.UUID) (database.Campaign, error)
+	CancelCampaign(ctx context.Context, id uuid.UUID) (database.Campaign, error)
+	ClaimCampaign(ctx context.Context, arg database.ClaimCampaignParams) (database.Campaign, error)
+	CheckpointCampaign(ctx context.Context, arg database.CheckpointCampaignParams) (database.Campaign, error)
+	FinishCampaign(ctx context.Context, arg database.FinishCampaignParams) error
+	CreateExperimentAssignment(ctx context.Context, arg database.CreateExperimentAssignmentParams) error
+	RecordExperimentOpen(ctx context.Context, arg database.RecordExperimentOpenParams) (int64, error)
+	RecordExperimentClick(ctx context.Context, arg database.RecordExperimentClickParams) (int64, error)
+	GetExperimentStats(ctx context.Context, experiment string) ([]database.GetExperimentStatsRow, error)
+	MarkNotificationDismissed(ctx context.Context, arg database.MarkNotificationDismissedParams) (int64, error)
+	GetEngagementByCategory(ctx context.Context, deliveredAt time.Time) ([]database.GetEngagementByCategoryRow, error)
+	IsNotificationSeen(ctx context.Context, id uuid.UUID) (bool, error)
+	CreateRoutingFallback(ctx context.Context, arg database.CreateRoutingFallbackParams) error
 	SendNotification(ctx context.Context) error
+	ExecTx(ctx context.Context, fn func(database.Querier) error) error
 }
 
 // Server implements the notification service gRPC server
 type Server struct {
 	pb.UnimplementedNotificationServiceServer
 	db              DBQuerier
-	rabbitmq        rabbitmq.Client
+	broker          broker.Consumer
 	firebaseKeyPath string
 	firebase        firebase.ClientInterface
+	hub             *hub.Hub
+	dispatcher      *routing.Dispatcher
+	topics          *topics.Manager
+	campaigns       *campaigns.Runner
+	experiments     *experiments.Tracker
+	engagement      *engagement.Tracker
+	maxDevices      int32
+	decoders        *codec.Registry[Notification]
+	verifier        *signing.Verifier
+}
+
+// Option configures optional behaviour of the server
+type Option func(*options)
+
+type options struct {
+	routingPolicy *routing.Policy
+	maxDevices    int32
+	verifier      *signing.Verifier
+}
+
+// DefaultMaxDevicesPerUser is how many active devices a user may have before the least recently seen is evicted
+const DefaultMaxDevicesPerUser = 10
+
+// WithRoutingPolicy replaces the default routing policy used to pick delivery channels per category
+func WithRoutingPolicy(policy *routing.Policy) Option {
+	return func(o *options) {
+		o.routingPolicy = policy
+	}
+}
+
+// WithMaxDevicesPerUser caps the active devices of a user, registering one more evicts the least recently seen.
+// A limit of zero or less disables the cap.
+func WithMaxDevicesPerUser(limit int32) Option {
+	return func(o *options) {
+		o.maxDevices = limit
+	}
+}
+
+// WithVerifier checks the signature and producer of every consumed request with the verifier,
+// without one any service with broker access may send notifications
+func WithVerifier(verifier *signing.Verifier) Option {
+	return func(o *options) {
+		o.verifier = verifier
+	}
 }
 
 // Notification represents the structure of a notification message
@@ -41,17 +119,166 @@ type Notification struct {
 	ReceiverID     string    `json:"receiver_id"` // Fixed: ReceiverId -> ReceiverID
 	Content        string    `json:"content"`
 	SentAt         time.Time `json:"sent_at"`
+	Category       string    `json:"category,omitempty"`
+	// MinAppVersion skips push to devices running an older app version
+	MinAppVersion string `json:"min_app_version,omitempty"`
+	// Translations holds localized title and content keyed by locale, e.g. "es" or "pt-BR"
+	Translations map[string]routing.Translation `json:"translations,omitempty"`
+	// Experiment replaces the title, content and translations with a variant picked per receiver
+	Experiment *experiments.Experiment `json:"experiment,omitempty"`
+}
+
+const (
+	// maxTitleLength limits the characters of a notification's title, in every language and variant
+	maxTitleLength = 256
+	// maxContentLength limits the characters of a notification's content, FCM rejects payloads over 4KB
+	maxContentLength = 2048
+)
+
+// validate checks the parts of a notification that can't be checked while decoding
+func (n Notification) validate() error {
+	err := validateContent("", n.Title, n.Content, n.Translations)
+	if err != nil {
+		return err
+	}
+	if n.Experiment == nil {
+		return nil
+	}
+
+	err = n.Experiment.Validate()
+	if err != nil {
+		return err
+	}
+	for _, variant := range n.Experiment.Variants {
+		err = validateContent(fmt.Sprintf("variant %q ", variant.Name), variant.Title, variant.Content, variant.Translations)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateContent checks the length of a title and content and of their translations, the
+// prefix names where they come from
+func validateContent(prefix, title, content string, translations map[string]routing.Translation) error {
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return fmt.Errorf("%stitle must be at most %d characters", prefix, maxTitleLength)
+	}
+	if utf8.RuneCountInString(content) > maxContentLength {
+		return fmt.Errorf("%scontent must be at most %d characters", prefix, maxContentLength)
+	}
+	for locale, translation := range translations {
+		err := validateContent(fmt.Sprintf("%s%q translation ", prefix, locale), translation.Title, translation.Content, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trackingNamespace is the namespace of the tracking ids derived from request ids
+var trackingNamespace = uuid.MustParse("6f1c0c52-5b8e-4a43-9d0e-3f4f8f2a9c17")
+
+// trackingID returns the id a receiver's copy of a notification is tracked by. A redelivered request
+// gets the same id as before so clients and stats don't count it twice, requests without an id,
+// such as gRPC calls, get a new one.
+func trackingID(requestID string, receiverID uuid.UUID) uuid.UUID {
+	if requestID == "" {
+		return uuid.New()
+	}
+	return uuid.NewSHA1(trackingNamespace, []byte(requestID+"/"+receiverID.String()))
+}
+
+// message builds the routing message delivering the notification to a receiver, requestID is the
+// id of the request it came from if it has one
+func (n Notification) message(receiverID uuid.UUID, requestID string) routing.Message {
+	msg := routing.Message{
+		ID:             trackingID(requestID, receiverID),
+		Category:       n.Category,
+		Title:          n.Title,
+		SenderUsername: n.SenderUsername,
+		ReceiverID:     receiverID,
+		Content:        n.Content,
+		SentAt:         n.SentAt,
+		MinAppVersion:  n.MinAppVersion,
+		Translations:   n.Translations,
+	}
+
+	if n.Experiment != nil {
+		variant := n.Experiment.Assign(receiverID)
+		msg.Title = variant.Title
+		msg.Content = variant.Content
+		msg.Translations = variant.Translations
+		msg.Experiment = n.Experiment.Key
+		msg.Variant = variant.Name
+	}
+	return msg
 }
 
 // NewServer creates a new notification service server with the provided dependencies
-func NewServer(db DBQuerier, rabbitmq rabbitmq.Client, firebaseKeyPath string, firebase firebase.ClientInterface) *Server {
+func NewServer(db DBQuerier, consumer broker.Consumer, firebaseKeyPath string, firebase firebase.ClientInterface, opts ...Option) (*Server, error) {
+	o := options{
+		routingPolicy: routing.DefaultPolicy(),
+		maxDevices:    DefaultMaxDevicesPerUser,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	liveHub := hub.New(hub.DefaultBufferSize)
+
+	experimentTracker := experiments.NewTracker(db)
+	engagementTracker := engagement.NewTracker(db)
+
+	// Delayed fallbacks are skipped once the app reported opening or dismissing the notification
+	dispatcher, err := routing.NewDispatcher(o.routingPolicy, db, engagementTracker,
+		routing.NewPushChannel(db, firebase),
+		routing.NewInAppChannel(liveHub),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record deliveries so apps can report opens against them, and queue events telling producers what happened
+	dispatcher.AddObserver(experimentTracker)
+	dispatcher.AddObserver(engagementTracker)
+
 	return &Server{
 		pb.UnimplementedNotificationServiceServer{},
 		db,
-		rabbitmq,
+		consumer,
 		firebaseKeyPath,
 		firebase,
-	}
+		liveHub,
+		dispatcher,
+		topics.NewManager(db, firebase),
+		campaigns.NewRunner(db, campaignSender(dispatcher)),
+		experimentTracker,
+		engagementTracker,
+		o.maxDevices,
+		newDecoders(),
+		o.verifier,
+	}, nil
+}
+
+// Hub returns the in-process subscription hub used to deliver live notifications
+func (s *Server) Hub() *hub.Hub {
+	return s.hub
+}
+
+// Topics returns the manager that keeps devices subscribed to their user's FCM topics
+func (s *Server) Topics() *topics.Manager {
+	return s.topics
+}
+
+// Campaigns returns the runner that executes scheduled and started campaigns
+func (s *Server) Campaigns() *campaigns.Runner {
+	return s.campaigns
+}
+
+// Dispatcher returns the dispatcher routing notifications, which also runs their delayed fallbacks
+func (s *Server) Dispatcher() *routing.Dispatcher {
+	return s.dispatcher
 }
 
 // SendNotification handles requests to send push notifications to users.
@@ -60,51 +287,29 @@ func (s *Server) SendNotification(ctx context.Context, req *pb.SendNotificationR
 	var notification Notification
 	err := json.Unmarshal(req.GetNotification(), &notification)
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't parse json - SendNotification", err)
+		return nil, helper.RespondWithErrorGRPC(ctx, malformedJSON("notification", err))
 	}
 
-	receiverID, err := uuid.Parse(notification.ReceiverID) // Fixed: receiverId -> receiverID
-	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse receiver id - SendNotification", err)
-	}
-
-	log.Printf("Sent push notification to: %v", notification.ReceiverID)
-
-	// Check if Firebase isn't initialized
-	if s.firebase == nil && s.firebase.GetMessagingClient() == nil {
-		log.Printf("Firebase not initialized, skipping push notification")
-	}
+	return s.sendNotification(ctx, notification, "")
+}
 
-	receiverDeviceToken, err := s.db.GetDeviceTokensByUserID(ctx, receiverID)
+// sendNotification validates a decoded notification and routes it to the receiver, requestID is
+// the broker message id of a consumed request
+func (s *Server) sendNotification(ctx context.Context, notification Notification, requestID string) (*pb.SendNotificationResponse, error) {
+	receiverID, err := parseID("notification.receiver_id", notification.ReceiverID)
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "Can't get receiver's device token from db - SendNotification", err)
-	}
-
-	if receiverDeviceToken == "" {
-		log.Printf("No device token found for user %s", notification.ReceiverID)
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
 	}
 
-	message := &messaging.Message{
-		Notification: &messaging.Notification{
-			Title: notification.Title,
-			Body:  notification.Content,
-		},
-		Token: receiverDeviceToken,
-		Data: map[string]string{
-			"title":           notification.Title,
-			"sender_username": notification.SenderUsername,
-			"receiver_id":     notification.ReceiverID,
-			"content":         notification.Content,
-			"sent_at":         notification.SentAt.Format(time.RFC3339),
-		},
+	err = notification.validate()
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, invalidNotification("notification", err))
 	}
 
-	// Send the Message
-	response, err := s.firebase.GetMessagingClient().Send(ctx, message)
+	// Route the notification through the channels configured for its category
+	_, err = s.dispatcher.Dispatch(ctx, notification.message(receiverID, requestID))
 	if err != nil {
-		log.Printf("Error sending FCM message: %v", err)
-	} else {
-		log.Printf("Successfully sent FCM message: %s", response)
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't dispatch notification"))
 	}
 
 	return &pb.SendNotificationResponse{
@@ -115,41 +320,62 @@ func (s *Server) SendNotification(ctx context.Context, req *pb.SendNotificationR
 // RegisterDeviceToken handles requests to register a new device token for push notifications.
 // It implements the NotificationServiceServer interface from the protobuf definition.
 func (s *Server) RegisterDeviceToken(ctx context.Context, req *pb.RegisterDeviceTokenRequest) (*pb.RegisterDeviceTokenResponse, error) {
-	userID, err := uuid.Parse(req.GetUserId())
+	userID, err := parseID("user_id", req.GetUserId())
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse provided uuid - RegisterDeviceToken", err)
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
 	}
 
 	deviceTokenParams := database.RegisterDeviceTokenParams{
-		ID:          uuid.New(),
-		UserID:      userID,
-		DeviceToken: req.GetDeviceToken(),
-		DeviceType:  req.GetDeviceType(),
+		ID:             uuid.New(),
+		UserID:         userID,
+		DeviceToken:    req.GetDeviceToken(),
+		DeviceType:     req.GetDeviceType(),
+		AppVersion:     req.GetAppVersion(),
+		OsVersion:      req.GetOsVersion(),
+		Locale:         req.GetLocale(),
+		Timezone:       req.GetTimezone(),
+		DeviceModel:    req.GetDeviceModel(),
+		PushPermission: pushPermission(req.GetPushPermission()),
 	}
 
-	deviceToken, err := s.db.RegisterDeviceToken(ctx, deviceTokenParams)
+	// A device token belongs to a single user, registering it takes it over from whoever used the device before
+	var result registration
+	err = s.db.ExecTx(ctx, func(q database.Querier) error {
+		var txErr error
+		result, txErr = registerDeviceToken(ctx, q, deviceTokenParams, s.maxDevices)
+		return txErr
+	})
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.Internal, "can't get device token from db - RegisterDeviceToken", err)
-	}
-
-	return &pb.RegisterDeviceTokenResponse{
-		DeviceToken: &pb.DeviceToken{
-			Id:          deviceToken.ID.String(),
-			UserId:      deviceToken.UserID.String(),
-			DeviceToken: deviceToken.DeviceToken,
-			DeviceType:  deviceToken.DeviceType,
-			CreatedAt:   timestamppb.New(deviceToken.CreatedAt),
-			UpdatedAt:   timestamppb.New(deviceToken.UpdatedAt),
-		},
-	}, nil
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't register device token"))
+	}
+
+	// FCM keeps topic subscriptions per token, move the device to its new user's topics
+	if result.previousUserID.Valid {
+		log.Printf("Device token %s reassigned from user %s to user %s", result.deviceToken.ID, result.previousUserID.UUID, result.deviceToken.UserID)
+		s.topics.DevicesRemoved(ctx, result.previousUserID.UUID, result.deviceToken.DeviceToken)
+	}
+	s.topics.DevicesAdded(ctx, result.deviceToken.UserID, result.deviceToken.DeviceToken)
+
+	response := &pb.RegisterDeviceTokenResponse{
+		DeviceToken:    deviceTokenToPB(result.deviceToken),
+		Reassigned:     result.previousUserID.Valid,
+		EvictedDevices: make([]*pb.DeviceToken, 0, len(result.evicted)),
+	}
+	for _, evicted := range result.evicted {
+		log.Printf("Device token %s of user %s evicted, device limit of %d reached", evicted.ID, evicted.UserID, s.maxDevices)
+		s.topics.DevicesRemoved(ctx, evicted.UserID, evicted.DeviceToken)
+		response.EvictedDevices = append(response.EvictedDevices, deviceTokenToPB(evicted))
+	}
+
+	return response, nil
 }
 
 // DeleteDeviceToken handles requests to delete a device token for a user.
 // It implements the NotificationServiceServer interface from the protobuf definition.
 func (s *Server) DeleteDeviceToken(ctx context.Context, req *pb.DeleteDeviceTokenRequest) (*pb.DeleteDeviceTokenResponse, error) {
-	userID, err := uuid.Parse(req.GetUserId())
+	userID, err := parseID("user_id", req.GetUserId())
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't parse user's incoming id - DeleteDeviceToken", err)
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
 	}
 
 	deleteDeviceTokenParams := database.DeleteDeviceTokenParams{
@@ -159,8 +385,9 @@ func (s *Server) DeleteDeviceToken(ctx context.Context, req *pb.DeleteDeviceToke
 
 	err = s.db.DeleteDeviceToken(ctx, deleteDeviceTokenParams)
 	if err != nil {
-		return nil, helper.RespondWithErrorGRPC(ctx, codes.InvalidArgument, "can't delete device token of a user - DeleteDeviceToken", err)
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't delete device token"))
 	}
+	s.topics.DevicesRemoved(ctx, userID, req.GetDeviceToken())
 
 	return &pb.DeleteDeviceTokenResponse{
 		Status: true,
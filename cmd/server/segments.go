@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/helper"
+	"github.com/imhasandl/notification-service/internal/routing"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	"github.com/imhasandl/notification-service/internal/segments"
+	pb "github.com/imhasandl/notification-service/protos"
+)
+
+// SendToSegment handles requests to send a notification to every user matching a segment.
+// Users are loaded in batches and sent through the same channels as SendNotification at a
+// throttled rate, progress is streamed back after every batch.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) SendToSegment(req *pb.SendToSegmentRequest, stream pb.NotificationService_SendToSegmentServer) error {
+	ctx := stream.Context()
+
+	segment, err := segments.Parse(req.GetSegment(), time.Now())
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, invalidSegment(err))
+	}
+
+	var notification Notification
+	err = json.Unmarshal(req.GetNotification(), &notification)
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, malformedJSON("notification", err))
+	}
+
+	err = notification.validate()
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, invalidNotification("notification", err))
+	}
+
+	broadcaster := segments.NewBroadcaster(s.db, dispatchTo(s.dispatcher, notification))
+	options := segments.Options{
+		BatchSize: req.GetBatchSize(),
+		Rate:      req.GetRatePerSecond(),
+	}
+
+	progress, err := broadcaster.Run(ctx, segment, options, func(progress segments.Progress) error {
+		return stream.Send(progressToPB(progress))
+	})
+	if ctx.Err() != nil {
+		return helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(ctx.Err(), "segment send cancelled"))
+	}
+	if err != nil {
+		return helper.RespondWithErrorGRPC(ctx, rpcerr.FromError(err, "can't send to segment"))
+	}
+
+	log.Printf("Segment send finished: %d users, %d sent, %d skipped, %d failed", progress.Processed, progress.Sent, progress.Skipped, progress.Failed)
+	return nil
+}
+
+// dispatchTo returns a send function routing the notification to one user of a broadcast
+func dispatchTo(dispatcher *routing.Dispatcher, notification Notification) segments.SendFunc {
+	return func(ctx context.Context, userID uuid.UUID) (bool, error) {
+		report, err := dispatcher.Dispatch(ctx, notification.message(userID, ""))
+		if err != nil {
+			return false, err
+		}
+		return report.Delivered(), nil
+	}
+}
+
+// progressToPB converts broadcast progress into its protobuf representation
+func progressToPB(progress segments.Progress) *pb.SendToSegmentProgress {
+	return &pb.SendToSegmentProgress{
+		Total:     progress.Total,
+		Processed: progress.Processed,
+		Sent:      progress.Sent,
+		Skipped:   progress.Skipped,
+		Failed:    progress.Failed,
+		Done:      progress.Done,
+	}
+}
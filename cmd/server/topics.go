@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/imhasandl/notification-service/cmd/helper"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	pb "github.com/imhasandl/notification-service/protos"
+)
+
+// SubscribeToTopic handles requests to subscribe every device of a user to an FCM topic.
+// Devices the user registers later are subscribed automatically.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) SubscribeToTopic(ctx context.Context, req *pb.SubscribeToTopicRequest) (*pb.SubscribeToTopicResponse, error) {
+	userID, err := parseID("user_id", req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	result, err := s.topics.Subscribe(ctx, userID, req.GetTopic())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, topicError(err, "can't subscribe user to topic"))
+	}
+
+	return &pb.SubscribeToTopicResponse{
+		SuccessCount: int64(result.SuccessCount),
+		FailureCount: int64(result.FailureCount),
+		Errors:       result.Errors,
+	}, nil
+}
+
+// UnsubscribeFromTopic handles requests to unsubscribe every device of a user from an FCM topic.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) UnsubscribeFromTopic(ctx context.Context, req *pb.UnsubscribeFromTopicRequest) (*pb.UnsubscribeFromTopicResponse, error) {
+	userID, err := parseID("user_id", req.GetUserId())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, err)
+	}
+
+	result, err := s.topics.Unsubscribe(ctx, userID, req.GetTopic())
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, topicError(err, "can't unsubscribe user from topic"))
+	}
+
+	return &pb.UnsubscribeFromTopicResponse{
+		SuccessCount: int64(result.SuccessCount),
+		FailureCount: int64(result.FailureCount),
+		Errors:       result.Errors,
+	}, nil
+}
+
+// SendToTopic handles requests to push a notification to every device subscribed to a topic
+// with a single FCM message instead of fanning out per user.
+// It implements the NotificationServiceServer interface from the protobuf definition.
+func (s *Server) SendToTopic(ctx context.Context, req *pb.SendToTopicRequest) (*pb.SendToTopicResponse, error) {
+	var notification Notification
+	err := json.Unmarshal(req.GetNotification(), &notification)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, malformedJSON("notification", err))
+	}
+	// A topic message reaches every subscriber at once, so there is no receiver to pick a variant for
+	if notification.Experiment != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, rpcerr.InvalidArgument(rpcerr.ReasonInvalidNotification, "experiments can't be sent to topics",
+			rpcerr.FieldViolation{Field: "notification.experiment", Description: "not supported for topics"}))
+	}
+
+	err = notification.validate()
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, invalidNotification("notification", err))
+	}
+
+	message := &messaging.Message{
+		Notification: &messaging.Notification{
+			Title: notification.Title,
+			Body:  notification.Content,
+		},
+		Data: map[string]string{
+			"title":           notification.Title,
+			"sender_username": notification.SenderUsername,
+			"content":         notification.Content,
+			"sent_at":         notification.SentAt.Format(time.RFC3339),
+			"topic":           req.GetTopic(),
+		},
+	}
+
+	messageID, err := s.topics.Send(ctx, req.GetTopic(), message)
+	if err != nil {
+		return nil, helper.RespondWithErrorGRPC(ctx, topicError(err, "can't send notification to topic"))
+	}
+
+	return &pb.SendToTopicResponse{
+		MessageId: messageID,
+	}, nil
+}
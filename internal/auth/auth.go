@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrMissingToken is returned when a request carries no bearer token
+var ErrMissingToken = errors.New("missing bearer token")
+
+// BearerTokenFromRequest extracts the token from the Authorization header
+func BearerTokenFromRequest(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			return "", errors.New("malformed authorization header")
+		}
+		return token, nil
+	}
+
+	return "", ErrMissingToken
+}
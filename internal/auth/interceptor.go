@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// ServiceRole is the role of other backend services, allowed to call service-only RPCs and to act for any user
+const ServiceRole = "service"
+
+// Access is what a caller needs to call an RPC
+type Access int
+
+const (
+	// Authenticated RPCs accept any valid token
+	Authenticated Access = iota
+	// UserScoped RPCs act on the user named by the request's user_id, which must be the token's
+	// subject unless the caller is a service
+	UserScoped
+	// ServiceOnly RPCs may only be called by services
+	ServiceOnly
+)
+
+// Policy maps full RPC names, e.g. "/notification.NotificationService/SendNotification", to their access.
+// RPCs it doesn't list are denied.
+type Policy map[string]Access
+
+// Identity is the authenticated caller of an RPC
+type Identity struct {
+	// Subject is the token's subject, the user id for users
+	Subject string
+	Roles   []string
+}
+
+// HasRole reports whether the caller was granted the role
+func (i Identity) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// claims are the claims read from a token, roles are granted by the identity provider
+type claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles"`
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying the caller's identity
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the caller's identity put into the context by the interceptors
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
+
+// AuthenticatorOption configures an Authenticator
+type AuthenticatorOption func(*Authenticator)
+
+// WithIssuer only accepts tokens issued by the issuer
+func WithIssuer(issuer string) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.issuer = issuer
+	}
+}
+
+// WithAudience only accepts tokens issued for the audience
+func WithAudience(audience string) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.audience = audience
+	}
+}
+
+// Authenticator validates the bearer tokens of gRPC calls against a key set and enforces a policy
+type Authenticator struct {
+	keys     *JWKS
+	policy   Policy
+	issuer   string
+	audience string
+}
+
+// NewAuthenticator creates an authenticator checking tokens with the keys and calls with the policy
+func NewAuthenticator(keys *JWKS, policy Policy, opts ...AuthenticatorOption) *Authenticator {
+	a := &Authenticator{keys: keys, policy: policy}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Authenticate validates a token and returns the identity it was issued to
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (Identity, error) {
+	var c claims
+	parsed, err := jwt.ParseWithClaims(token, &c, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return a.keys.Key(ctx, kid)
+	}, jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}))
+	if err != nil {
+		return Identity{}, err
+	}
+	if !parsed.Valid || c.Subject == "" {
+		return Identity{}, errors.New("invalid token")
+	}
+	if c.ExpiresAt == nil {
+		return Identity{}, errors.New("token has no expiry")
+	}
+	if a.issuer != "" && !c.VerifyIssuer(a.issuer, true) {
+		return Identity{}, errors.New("token has the wrong issuer")
+	}
+	if a.audience != "" && !c.VerifyAudience(a.audience, true) {
+		return Identity{}, errors.New("token has the wrong audience")
+	}
+
+	return Identity{Subject: c.Subject, Roles: c.Roles}, nil
+}
+
+// UnaryInterceptor authenticates unary calls and checks them against the policy
+func (a *Authenticator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticateCall(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.authorize(ctx, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor authenticates streaming calls and checks every message the client sends against the policy
+func (a *Authenticator) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticateCall(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authorizedStream{ServerStream: stream, ctx: ctx, authenticator: a, method: info.FullMethod})
+	}
+}
+
+// authenticateCall validates the bearer token in the call's metadata and puts the identity into the context
+func (a *Authenticator) authenticateCall(ctx context.Context, method string) (context.Context, error) {
+	access, ok := a.policy[method]
+	if !ok {
+		return nil, rpcerr.New(codes.PermissionDenied, rpcerr.ReasonPermissionDenied, method+" is not in the access policy")
+	}
+
+	token, err := bearerTokenFromMetadata(ctx)
+	if err != nil {
+		return nil, rpcerr.New(codes.Unauthenticated, rpcerr.ReasonMissingToken, err.Error())
+	}
+
+	identity, err := a.Authenticate(ctx, token)
+	if err != nil {
+		return nil, rpcerr.New(codes.Unauthenticated, rpcerr.ReasonInvalidToken, "invalid token: "+err.Error())
+	}
+
+	// Service-only RPCs are checked before reading the request
+	if access == ServiceOnly && !identity.HasRole(ServiceRole) {
+		return nil, rpcerr.New(codes.PermissionDenied, rpcerr.ReasonServiceOnly, "only services may call "+method)
+	}
+	return WithIdentity(ctx, identity), nil
+}
+
+// userScopedRequest is a request naming the user it acts on
+type userScopedRequest interface {
+	GetUserId() string
+}
+
+// authorize checks that a user-scoped request acts on the caller, services may act on any user
+func (a *Authenticator) authorize(ctx context.Context, method string, req interface{}) error {
+	if a.policy[method] != UserScoped {
+		return nil
+	}
+
+	identity, _ := IdentityFromContext(ctx)
+	if identity.HasRole(ServiceRole) {
+		return nil
+	}
+	scoped, ok := req.(userScopedRequest)
+	if !ok {
+		return rpcerr.New(codes.Internal, rpcerr.ReasonInternal, method+" has no user_id")
+	}
+	if !strings.EqualFold(scoped.GetUserId(), identity.Subject) {
+		return rpcerr.New(codes.PermissionDenied, rpcerr.ReasonUserMismatch, "user_id doesn't match the authenticated user").
+			WithMetadata("field", "user_id")
+	}
+	return nil
+}
+
+// authorizedStream carries the caller's identity and checks the messages it receives
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx           context.Context
+	authenticator *Authenticator
+	method        string
+}
+
+// Context returns the context carrying the caller's identity
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+// RecvMsg receives a message and rejects it if the caller may not send it
+func (s *authorizedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.authenticator.authorize(s.ctx, s.method, m)
+}
+
+// bearerTokenFromMetadata extracts the token from the authorization metadata of a call
+func bearerTokenFromMetadata(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", ErrMissingToken
+	}
+
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok || token == "" {
+		return "", errors.New("malformed authorization metadata")
+	}
+	return token, nil
+}
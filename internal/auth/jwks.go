@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// jwksRefreshInterval is how often a JWKS URL may be fetched again for a key id it doesn't have,
+// so tokens with made-up key ids can't flood the identity provider
+const jwksRefreshInterval = time.Minute
+
+// ErrUnknownKey is returned for a token signed with a key the key set doesn't have
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// jwk is a JSON Web Key, only the public parameters of RSA, EC and Ed25519 keys are read
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// JWKS is the set of public keys tokens are signed with, read from a file or an identity provider's URL.
+// A URL is fetched again when a token names a key the set doesn't have, so rotated keys are picked up.
+type JWKS struct {
+	source string
+	client *http.Client
+	// refresh lets concurrent lookups of unknown keys share one fetch
+	refresh singleflight.Group
+
+	mu      sync.Mutex
+	keys    map[string]interface{}
+	fetched time.Time
+}
+
+// LoadJWKS reads the key set at the source, an http(s) URL or a file path
+func LoadJWKS(ctx context.Context, source string) (*JWKS, error) {
+	j := &JWKS{source: source, client: &http.Client{Timeout: 10 * time.Second}}
+	if err := j.load(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load JWKS from %s: %w", source, err)
+	}
+	return j, nil
+}
+
+// Key returns the public key with the id, a token without a key id may use the only key of a set with one
+func (j *JWKS) Key(ctx context.Context, kid string) (interface{}, error) {
+	key, ok, fetched := j.lookup(kid)
+	if ok {
+		return key, nil
+	}
+	if !j.remote() || time.Since(fetched) < jwksRefreshInterval {
+		return nil, ErrUnknownKey
+	}
+
+	// The fetch runs without the lock so tokens with known keys aren't held up by a slow identity
+	// provider, and isn't cancelled with the request that happened to start it
+	_, err, _ := j.refresh.Do(j.source, func() (interface{}, error) {
+		return nil, j.load(context.WithoutCancel(ctx))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if key, ok, _ := j.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// lookup returns the key with the id and when the set was last fetched
+func (j *JWKS) lookup(kid string) (interface{}, bool, time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true, j.fetched
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok, j.fetched
+}
+
+func (j *JWKS) remote() bool {
+	return strings.HasPrefix(j.source, "https://") || strings.HasPrefix(j.source, "http://")
+}
+
+// load reads the key set and replaces the keys
+func (j *JWKS) load(ctx context.Context) error {
+	data, err := j.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("can't parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("JWKS has no signing keys")
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.keys = keys
+	j.fetched = time.Now()
+	return nil
+}
+
+func (j *JWKS) read(ctx context.Context) ([]byte, error) {
+	if !j.remote() {
+		return os.ReadFile(filepath.Clean(j.source))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// publicKey decodes the key into the type jwt verifies its algorithm with
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		return k.rsaKey()
+	case "EC":
+		return k.ecKey()
+	case "OKP":
+		return k.ed25519Key()
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func (k jwk) rsaKey() (*rsa.PublicKey, error) {
+	n, e := decodeInt(k.N), decodeInt(k.E)
+	if n == nil || e == nil || !e.IsInt64() {
+		return nil, errors.New("invalid RSA key")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jwk) ecKey() (*ecdsa.PublicKey, error) {
+	curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+	curve, ok := curves[k.Crv]
+	x, y := decodeInt(k.X), decodeInt(k.Y)
+	if !ok || x == nil || y == nil || !curve.IsOnCurve(x, y) {
+		return nil, errors.New("invalid EC key")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func (k jwk) ed25519Key() (ed25519.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 key")
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+// decodeInt decodes a base64url big-endian integer, nil if it isn't one
+func decodeInt(s string) *big.Int {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
@@ -0,0 +1,95 @@
+// Package broker abstracts the message broker the service consumes notification requests from
+// and publishes its events to, so the transport can be picked by configuration.
+package broker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/imhasandl/notification-service/internal/rabbitmq"
+)
+
+const (
+	// RequestsTopic is where producers publish notification requests for the service
+	RequestsTopic = rabbitmq.ExchangeName
+	// EventsTopic is where the service publishes delivery status events
+	EventsTopic = rabbitmq.EventsExchangeName
+	// ConsumerGroup names the durable subscription the service instances share
+	ConsumerGroup = "notification-service"
+)
+
+// Header names used by transports without native message properties
+const (
+	headerMessageID     = "Message-Id"
+	headerCorrelationID = "Correlation-Id"
+	headerContentType   = "Content-Type"
+)
+
+// Message is a transport-neutral broker message
+type Message struct {
+	ID            string
+	CorrelationID string
+	ContentType   string
+	Timestamp     time.Time
+	Headers       map[string]string
+	Body          []byte
+}
+
+// Handler processes a consumed message. Returning nil acknowledges it, an error hands it back
+// to the broker to be delivered again.
+type Handler func(ctx context.Context, msg Message) error
+
+// Consumer receives the notification requests published to RequestsTopic
+type Consumer interface {
+	// Consume passes every message to the handler until the context is cancelled,
+	// it returns an error if the subscription fails
+	Consume(ctx context.Context, handler Handler) error
+}
+
+// Publisher sends messages to a topic. The key is the routing key on RabbitMQ, the partition
+// key on Kafka and the last subject token on NATS. Publish returns once the broker stored the message.
+type Publisher interface {
+	Publish(ctx context.Context, topic, key string, msg Message) error
+}
+
+// Broker is a connection that both consumes and publishes
+type Broker interface {
+	Consumer
+	Publisher
+	Close() error
+}
+
+// Backend names a broker implementation
+type Backend string
+
+const (
+	// RabbitMQBackend consumes from a durable RabbitMQ queue bound to the requests exchange
+	RabbitMQBackend Backend = "rabbitmq"
+	// NATSBackend consumes from a durable NATS JetStream consumer
+	NATSBackend Backend = "nats"
+	// KafkaBackend consumes as a member of a Kafka consumer group
+	KafkaBackend Backend = "kafka"
+)
+
+// Config selects and configures the broker backend
+type Config struct {
+	Backend      Backend
+	RabbitMQURL  string
+	NATSURL      string
+	KafkaBrokers []string
+}
+
+// Open connects to the configured backend, RabbitMQ if none is set
+func Open(ctx context.Context, config Config) (Broker, error) {
+	switch config.Backend {
+	case RabbitMQBackend, "":
+		return NewRabbitMQ(config.RabbitMQURL)
+	case NATSBackend:
+		return NewNATS(ctx, config.NATSURL)
+	case KafkaBackend:
+		return NewKafka(config.KafkaBrokers), nil
+	default:
+		return nil, fmt.Errorf("unknown broker backend %q", config.Backend)
+	}
+}
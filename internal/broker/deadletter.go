@@ -0,0 +1,62 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/imhasandl/notification-service/internal/rabbitmq"
+)
+
+// DeadLetterTopic is where requests that can never be handled are moved, with the reason in a header
+const DeadLetterTopic = rabbitmq.DeadLetterExchangeName
+
+// HeaderDeadLetterReason is the header of a dead-lettered message explaining why it was rejected
+const HeaderDeadLetterReason = "Dead-Letter-Reason"
+
+// permanentError marks a failure that delivering the message again can't fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks a handler error as permanent, e.g. a payload that fails validation. The consumer
+// moves the message to DeadLetterTopic instead of having it delivered again.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether the error was marked with Permanent
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// handle runs the handler and dead-letters messages it rejects for good. It returns nil once the
+// message is dealt with, or the error if it should be delivered again.
+func handle(ctx context.Context, handler Handler, publisher Publisher, key string, msg Message) error {
+	err := handler(ctx, msg)
+	if !IsPermanent(err) {
+		return err
+	}
+
+	log.Printf("Dead-lettering message %s: %v", msg.ID, err)
+	headers := make(map[string]string, len(msg.Headers)+1)
+	for name, value := range msg.Headers {
+		headers[name] = value
+	}
+	headers[HeaderDeadLetterReason] = err.Error()
+	msg.Headers = headers
+
+	return publisher.Publish(ctx, DeadLetterTopic, key, msg)
+}
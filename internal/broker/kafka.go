@@ -0,0 +1,117 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaRetryDelay is how long a failed message waits before it is handled again
+const kafkaRetryDelay = time.Second
+
+// Kafka consumes as a member of the service's consumer group and publishes with acks from all replicas
+type Kafka struct {
+	brokers []string
+	writer  *kafka.Writer
+}
+
+// Ensure Kafka implements the interface
+var _ Broker = (*Kafka)(nil)
+
+// NewKafka creates a Kafka broker, connections are opened on first use
+func NewKafka(brokers []string) *Kafka {
+	return &Kafka{
+		brokers: brokers,
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+		},
+	}
+}
+
+// Consume implements Consumer. Kafka can't hand a single message back, so a failed message
+// is retried in place and its partition waits behind it; offsets are committed once handled.
+// A permanently failed message is written to the dead-letter topic instead.
+func (k *Kafka) Consume(ctx context.Context, handler Handler) error {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: k.brokers,
+		GroupID: ConsumerGroup,
+		Topic:   RequestsTopic,
+	})
+	defer reader.Close()
+
+	for {
+		m, err := reader.FetchMessage(ctx)
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("kafka: can't fetch message: %w", err)
+		}
+
+		for handle(ctx, handler, k, string(m.Key), fromKafka(m)) != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(kafkaRetryDelay):
+			}
+		}
+
+		if err := reader.CommitMessages(ctx, m); err != nil {
+			log.Printf("Failed to commit message: %v", err)
+		}
+	}
+}
+
+// Publish implements Publisher, the key picks the partition so messages with the same key stay in order
+func (k *Kafka) Publish(ctx context.Context, topic, key string, msg Message) error {
+	headers := []kafka.Header{
+		{Key: headerMessageID, Value: []byte(msg.ID)},
+		{Key: headerCorrelationID, Value: []byte(msg.CorrelationID)},
+		{Key: headerContentType, Value: []byte(msg.ContentType)},
+	}
+	for name, value := range msg.Headers {
+		headers = append(headers, kafka.Header{Key: name, Value: []byte(value)})
+	}
+
+	return k.writer.WriteMessages(ctx, kafka.Message{
+		Topic:   topic,
+		Key:     []byte(key),
+		Value:   msg.Body,
+		Headers: headers,
+		Time:    msg.Timestamp,
+	})
+}
+
+// Close flushes and closes the writer
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
+
+// fromKafka converts a Kafka message, reading the properties back from its headers
+func fromKafka(m kafka.Message) Message {
+	msg := Message{
+		Timestamp: m.Time,
+		Headers:   make(map[string]string, len(m.Headers)),
+		Body:      m.Value,
+	}
+	for _, header := range m.Headers {
+		switch header.Key {
+		case headerMessageID:
+			msg.ID = string(header.Value)
+		case headerCorrelationID:
+			msg.CorrelationID = string(header.Value)
+		case headerContentType:
+			msg.ContentType = string(header.Value)
+		default:
+			msg.Headers[header.Key] = string(header.Value)
+		}
+	}
+	return msg
+}
@@ -0,0 +1,122 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// memoryQueueSize is how many requests the in-memory broker holds before Publish blocks
+const memoryQueueSize = 100
+
+// ErrClosed is returned when publishing to a closed in-memory broker
+var ErrClosed = errors.New("broker: closed")
+
+// Published is a message the in-memory broker received
+type Published struct {
+	Topic   string
+	Key     string
+	Message Message
+}
+
+// Memory is an in-process broker for tests. Messages published to RequestsTopic are passed to
+// the consumer; messages its handler fails are kept for inspection instead of being redelivered,
+// and messages it fails permanently are published to DeadLetterTopic.
+type Memory struct {
+	mu        sync.Mutex
+	published []Published
+	rejected  []Message
+	requests  chan Published
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Ensure Memory implements the interface
+var _ Broker = (*Memory)(nil)
+
+// NewMemory creates an empty in-memory broker
+func NewMemory() *Memory {
+	return &Memory{
+		requests: make(chan Published, memoryQueueSize),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Consume implements Consumer
+func (m *Memory) Consume(ctx context.Context, handler Handler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-m.closed:
+			return nil
+		case request := <-m.requests:
+			if err := handle(ctx, handler, m, request.Key, request.Message); err != nil {
+				m.mu.Lock()
+				m.rejected = append(m.rejected, request.Message)
+				m.mu.Unlock()
+			}
+		}
+	}
+}
+
+// Publish implements Publisher
+func (m *Memory) Publish(ctx context.Context, topic, key string, msg Message) error {
+	select {
+	case <-m.closed:
+		return ErrClosed
+	default:
+	}
+
+	published := Published{Topic: topic, Key: key, Message: msg}
+	m.mu.Lock()
+	m.published = append(m.published, published)
+	m.mu.Unlock()
+
+	if topic != RequestsTopic {
+		return nil
+	}
+	select {
+	case m.requests <- published:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.closed:
+		return ErrClosed
+	}
+}
+
+// Published returns every message published so far, in order
+func (m *Memory) Published() []Published {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Published(nil), m.published...)
+}
+
+// Rejected returns the requests the consumer's handler failed
+func (m *Memory) Rejected() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.rejected...)
+}
+
+// DeadLettered returns the requests the consumer's handler failed permanently, with the reason header
+func (m *Memory) DeadLettered() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deadLettered []Message
+	for _, published := range m.published {
+		if published.Topic == DeadLetterTopic {
+			deadLettered = append(deadLettered, published.Message)
+		}
+	}
+	return deadLettered
+}
+
+// Close stops the consumer and rejects further publishes
+func (m *Memory) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.closed)
+	})
+	return nil
+}
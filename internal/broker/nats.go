@@ -0,0 +1,157 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Streams holding the requests, events and dead letters, each captures every subject under its topic
+const (
+	natsRequestsStream    = "NOTIFICATIONS"
+	natsEventsStream      = "NOTIFICATION_EVENTS"
+	natsDeadLettersStream = "NOTIFICATION_DEAD_LETTERS"
+)
+
+// NATS consumes from a durable JetStream consumer and publishes to JetStream streams
+type NATS struct {
+	conn   *nats.Conn
+	js     jetstream.JetStream
+	closed chan struct{}
+}
+
+// Ensure NATS implements the interface
+var _ Broker = (*NATS)(nil)
+
+// NewNATS connects to NATS and creates the requests, events and dead-letter streams if they don't exist
+func NewNATS(ctx context.Context, url string) (*NATS, error) {
+	closed := make(chan struct{})
+	conn, err := nats.Connect(url, nats.ClosedHandler(func(*nats.Conn) {
+		close(closed)
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	streams := map[string]string{
+		natsRequestsStream:    RequestsTopic,
+		natsEventsStream:      EventsTopic,
+		natsDeadLettersStream: DeadLetterTopic,
+	}
+	for name, topic := range streams {
+		_, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+			Name:     name,
+			Subjects: []string{topic + ".>"},
+			Storage:  jetstream.FileStorage,
+		})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to create stream %s: %w", name, err)
+		}
+	}
+
+	return &NATS{conn: conn, js: js, closed: closed}, nil
+}
+
+// Consume implements Consumer, a failed message is negatively acknowledged and redelivered and
+// a permanently failed one is moved to the dead-letter stream
+func (n *NATS) Consume(ctx context.Context, handler Handler) error {
+	consumer, err := n.js.CreateOrUpdateConsumer(ctx, natsRequestsStream, jetstream.ConsumerConfig{
+		Durable:   ConsumerGroup,
+		AckPolicy: jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create a consumer: %w", err)
+	}
+
+	subscription, err := consumer.Consume(func(m jetstream.Msg) {
+		key := strings.TrimPrefix(m.Subject(), RequestsTopic+".")
+		var settleErr error
+		if handleErr := handle(ctx, handler, n, key, fromNATS(m)); handleErr != nil {
+			settleErr = m.Nak()
+		} else {
+			settleErr = m.Ack()
+		}
+		if settleErr != nil {
+			log.Printf("nats: failed to settle message: %v", settleErr)
+		}
+	}, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+		log.Printf("nats: consumer error: %v", err)
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to register a consumer: %w", err)
+	}
+	defer subscription.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-n.closed:
+		return errors.New("nats: connection closed")
+	}
+}
+
+// Publish implements Publisher, publishing to the subject <topic>.<key>. JetStream drops
+// a message whose id it has already stored, so republishing after a lost ack is safe.
+func (n *NATS) Publish(ctx context.Context, topic, key string, msg Message) error {
+	header := nats.Header{}
+	for name, value := range msg.Headers {
+		header.Set(name, value)
+	}
+	header.Set(headerCorrelationID, msg.CorrelationID)
+	header.Set(headerContentType, msg.ContentType)
+
+	_, err := n.js.PublishMsg(ctx, &nats.Msg{
+		Subject: subject(topic, key),
+		Header:  header,
+		Data:    msg.Body,
+	}, jetstream.WithMsgID(msg.ID))
+	return err
+}
+
+// Close closes the connection
+func (n *NATS) Close() error {
+	n.conn.Close()
+	return nil
+}
+
+// subject appends the key to the topic, messages without a key go to <topic>.default
+func subject(topic, key string) string {
+	if key == "" {
+		key = "default"
+	}
+	return topic + "." + key
+}
+
+// fromNATS converts a JetStream message, the id is the one JetStream deduplicates on
+func fromNATS(m jetstream.Msg) Message {
+	msg := Message{
+		ID:            m.Headers().Get(jetstream.MsgIDHeader),
+		CorrelationID: m.Headers().Get(headerCorrelationID),
+		ContentType:   m.Headers().Get(headerContentType),
+		Headers:       make(map[string]string, len(m.Headers())),
+		Body:          m.Data(),
+	}
+	for name := range m.Headers() {
+		switch name {
+		case jetstream.MsgIDHeader, headerCorrelationID, headerContentType:
+		default:
+			msg.Headers[name] = m.Headers().Get(name)
+		}
+	}
+	if metadata, err := m.Metadata(); err == nil {
+		msg.Timestamp = metadata.Timestamp
+	}
+	return msg
+}
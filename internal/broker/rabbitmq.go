@@ -0,0 +1,82 @@
+package broker
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/rabbitmq"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// RabbitMQ consumes from the service queue and publishes with publisher confirms
+type RabbitMQ struct {
+	client *rabbitmq.RabbitMQ
+}
+
+// Ensure RabbitMQ implements the interface
+var _ Broker = (*RabbitMQ)(nil)
+
+// NewRabbitMQ connects to RabbitMQ and declares the service's exchanges and queue
+func NewRabbitMQ(url string) (*RabbitMQ, error) {
+	client, err := rabbitmq.NewRabbitMQ(url)
+	if err != nil {
+		return nil, err
+	}
+	return &RabbitMQ{client: client}, nil
+}
+
+// Consume implements Consumer, a failed message is requeued and a permanently failed one is moved
+// to the dead-letter exchange. Consumption pauses while the broker blocks the connection during
+// a resource alarm.
+func (r *RabbitMQ) Consume(ctx context.Context, handler Handler) error {
+	return r.client.Consume(ctx, func(ctx context.Context, delivery amqp.Delivery) error {
+		return handle(ctx, handler, r, delivery.RoutingKey, fromDelivery(delivery))
+	})
+}
+
+// Publish implements Publisher, publishing to the exchange named by the topic
+func (r *RabbitMQ) Publish(ctx context.Context, topic, key string, msg Message) error {
+	headers := make(amqp.Table, len(msg.Headers))
+	for name, value := range msg.Headers {
+		headers[name] = value
+	}
+	// The publisher needs a message id, requests from other producers may come without one
+	if msg.ID == "" {
+		msg.ID = uuid.NewString()
+	}
+
+	return r.client.Publish(ctx, topic, key, amqp.Publishing{
+		Headers:       headers,
+		ContentType:   msg.ContentType,
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     msg.ID,
+		CorrelationId: msg.CorrelationID,
+		Timestamp:     msg.Timestamp,
+		Body:          msg.Body,
+	})
+}
+
+// Close closes the connection
+func (r *RabbitMQ) Close() error {
+	r.client.Close()
+	return nil
+}
+
+// fromDelivery converts an AMQP delivery, keeping the string headers
+func fromDelivery(delivery amqp.Delivery) Message {
+	headers := make(map[string]string, len(delivery.Headers))
+	for name, value := range delivery.Headers {
+		if s, ok := value.(string); ok {
+			headers[name] = s
+		}
+	}
+
+	return Message{
+		ID:            delivery.MessageId,
+		CorrelationID: delivery.CorrelationId,
+		ContentType:   delivery.ContentType,
+		Timestamp:     delivery.Timestamp,
+		Headers:       headers,
+		Body:          delivery.Body,
+	}
+}
@@ -0,0 +1,233 @@
+package campaigns
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/segments"
+)
+
+// Campaign statuses
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusRunning   = "running"
+	StatusPaused    = "paused"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+	StatusFailed    = "failed"
+)
+
+const (
+	// DefaultPollInterval is how often the runner looks for campaigns that are due
+	DefaultPollInterval = 10 * time.Second
+	// DefaultLease is how long a claimed campaign stays with this instance without a checkpoint
+	DefaultLease = 5 * time.Minute
+	// DefaultMaxConcurrent is how many campaigns one instance runs at the same time
+	DefaultMaxConcurrent = 4
+	// batchSeconds bounds how long one batch takes so checkpoints renew the lease well before it expires
+	batchSeconds = 30
+)
+
+// errLeaseLost stops a run whose campaign was paused, cancelled or claimed by another instance
+var errLeaseLost = errors.New("campaign lease lost")
+
+// SenderFunc builds the function sending a campaign's stored notification to one user
+type SenderFunc func(notification []byte) (segments.SendFunc, error)
+
+// Store defines the database operations the runner needs
+type Store interface {
+	segments.Source
+	ClaimCampaign(ctx context.Context, arg database.ClaimCampaignParams) (database.Campaign, error)
+	CheckpointCampaign(ctx context.Context, arg database.CheckpointCampaignParams) (database.Campaign, error)
+	FinishCampaign(ctx context.Context, arg database.FinishCampaignParams) error
+}
+
+// Runner executes due campaigns, checkpointing progress in Postgres so that a campaign
+// interrupted by a restart is resumed by whichever instance claims it next
+type Runner struct {
+	store        Store
+	sender       SenderFunc
+	pollInterval time.Duration
+	lease        time.Duration
+	slots        chan struct{}
+	now          func() time.Time
+}
+
+// NewRunner creates a runner that loads campaigns from the store and sends with sender
+func NewRunner(store Store, sender SenderFunc) *Runner {
+	return &Runner{
+		store:        store,
+		sender:       sender,
+		pollInterval: DefaultPollInterval,
+		lease:        DefaultLease,
+		slots:        make(chan struct{}, DefaultMaxConcurrent),
+		now:          time.Now,
+	}
+}
+
+// Run claims and executes due campaigns until the context is cancelled
+func (r *Runner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.claimDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimDue starts due campaigns in the background while there are free slots
+func (r *Runner) claimDue(ctx context.Context) {
+	for {
+		select {
+		case r.slots <- struct{}{}:
+		default:
+			return
+		}
+
+		campaign, ok := r.claim(ctx)
+		if !ok {
+			<-r.slots
+			return
+		}
+
+		go func() {
+			defer func() { <-r.slots }()
+			if err := r.execute(ctx, campaign); err != nil {
+				log.Printf("campaigns: campaign %s stopped: %v", campaign.ID, err)
+			}
+		}()
+	}
+}
+
+// RunNext claims one due campaign and executes it before returning.
+// It reports whether a campaign was claimed.
+func (r *Runner) RunNext(ctx context.Context) (bool, error) {
+	campaign, ok := r.claim(ctx)
+	if !ok {
+		return false, nil
+	}
+	return true, r.execute(ctx, campaign)
+}
+
+// claim takes the lease on the next due campaign
+func (r *Runner) claim(ctx context.Context) (database.Campaign, bool) {
+	campaign, err := r.store.ClaimCampaign(ctx, database.ClaimCampaignParams{
+		LeaseID:     uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		LeasedUntil: r.leaseDeadline(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Campaign{}, false
+	}
+	if err != nil {
+		log.Printf("campaigns: can't claim campaign: %v", err)
+		return database.Campaign{}, false
+	}
+	return campaign, true
+}
+
+// execute sends the campaign from its last checkpoint. Campaigns that can't be sent at all are
+// marked failed, other errors leave it running so it is retried once the lease expires.
+func (r *Runner) execute(ctx context.Context, campaign database.Campaign) error {
+	send, err := r.sender(campaign.Notification)
+	if err != nil {
+		return r.finish(ctx, campaign, StatusFailed, err)
+	}
+
+	segment, err := segments.Parse(campaign.Segment, campaign.SegmentEvaluatedAt.Time)
+	if err != nil {
+		return r.finish(ctx, campaign, StatusFailed, err)
+	}
+
+	log.Printf("campaigns: running campaign %s (%s) from %d processed users", campaign.ID, campaign.Name, campaign.Processed)
+	broadcaster := segments.NewBroadcaster(r.store, send)
+	_, err = broadcaster.Run(ctx, segment, r.options(campaign), func(progress segments.Progress) error {
+		return r.checkpoint(ctx, campaign, progress)
+	})
+	if errors.Is(err, errLeaseLost) {
+		log.Printf("campaigns: campaign %s was paused or cancelled", campaign.ID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.finish(ctx, campaign, StatusCompleted, nil)
+}
+
+// checkpoint saves the progress and renews the lease, failing if the campaign left the running state
+func (r *Runner) checkpoint(ctx context.Context, campaign database.Campaign, progress segments.Progress) error {
+	_, err := r.store.CheckpointCampaign(ctx, database.CheckpointCampaignParams{
+		ID:          campaign.ID,
+		LeaseID:     campaign.LeaseID,
+		Total:       progress.Total,
+		Processed:   progress.Processed,
+		Sent:        progress.Sent,
+		Skipped:     progress.Skipped,
+		Failed:      progress.Failed,
+		LastUserID:  uuid.NullUUID{UUID: progress.LastUserID, Valid: progress.LastUserID != uuid.Nil},
+		LeasedUntil: r.leaseDeadline(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return errLeaseLost
+	}
+	return err
+}
+
+// finish moves the campaign to a final status and releases the lease
+func (r *Runner) finish(ctx context.Context, campaign database.Campaign, status string, cause error) error {
+	lastError := ""
+	if cause != nil {
+		lastError = cause.Error()
+	}
+
+	err := r.store.FinishCampaign(ctx, database.FinishCampaignParams{
+		ID:        campaign.ID,
+		LeaseID:   campaign.LeaseID,
+		Status:    status,
+		LastError: lastError,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("campaigns: campaign %s %s", campaign.ID, status)
+	return cause
+}
+
+// options resumes from the campaign's checkpoint, keeping batches short enough to renew the lease
+func (r *Runner) options(campaign database.Campaign) segments.Options {
+	batchSize := campaign.BatchSize
+	if maxBatch := campaign.RatePerSecond * batchSeconds; maxBatch > 0 && batchSize > maxBatch {
+		batchSize = maxBatch
+	}
+
+	return segments.Options{
+		BatchSize: batchSize,
+		Rate:      campaign.RatePerSecond,
+		Resume: segments.Progress{
+			Total:      campaign.Total,
+			Processed:  campaign.Processed,
+			Sent:       campaign.Sent,
+			Skipped:    campaign.Skipped,
+			Failed:     campaign.Failed,
+			LastUserID: campaign.LastUserID.UUID,
+		},
+	}
+}
+
+// leaseDeadline is when a lease taken or renewed now expires
+func (r *Runner) leaseDeadline() sql.NullTime {
+	return sql.NullTime{Time: r.now().Add(r.lease), Valid: true}
+}
@@ -0,0 +1,131 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// cloudEventsVersion is the CloudEvents specification version the service understands
+const cloudEventsVersion = "1.0"
+
+// binaryPrefixes are the header prefixes of CloudEvents attributes in binary mode, by protocol
+// binding: AMQP, AMQP with JMS-safe names, Kafka, and HTTP style used on NATS. Compared lower case.
+var binaryPrefixes = []string{"cloudevents:", "cloudevents_", "ce_", "ce-"}
+
+// Event holds the CloudEvents context attributes of a message
+type Event struct {
+	ID      string
+	Source  string
+	Type    string
+	Subject string
+	Time    time.Time
+}
+
+// structuredEvent is a CloudEvent in the JSON event format
+type structuredEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            *time.Time      `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema"`
+	Data            json.RawMessage `json:"data"`
+	DataBase64      []byte          `json:"data_base64"`
+}
+
+// unwrapStructured extracts the data of a structured mode CloudEvent. JSON data is embedded as is,
+// any other encoding such as protobuf is carried base64 encoded in data_base64.
+func unwrapStructured(body []byte) (Payload, error) {
+	var event structuredEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return Payload{}, fmt.Errorf("%w: %v", ErrInvalidCloudEvent, err)
+	}
+
+	attributes := map[string]string{
+		"specversion": event.SpecVersion,
+		"id":          event.ID,
+		"source":      event.Source,
+		"type":        event.Type,
+		"subject":     event.Subject,
+		"dataschema":  event.DataSchema,
+	}
+	if event.Time != nil {
+		attributes["time"] = event.Time.Format(time.RFC3339Nano)
+	}
+
+	contentType, err := mediaType(event.DataContentType)
+	if err != nil {
+		return Payload{}, err
+	}
+
+	data := []byte(event.Data)
+	if event.DataBase64 != nil {
+		data = event.DataBase64
+	}
+	return unwrapBinary(attributes, contentType, data)
+}
+
+// binaryAttributes collects the CloudEvents attributes from the headers of a binary mode message,
+// it returns nil if the message isn't a CloudEvent
+func binaryAttributes(headers map[string]string) map[string]string {
+	var attributes map[string]string
+	for key, value := range headers {
+		lower := strings.ToLower(key)
+		for _, prefix := range binaryPrefixes {
+			if strings.HasPrefix(lower, prefix) {
+				if attributes == nil {
+					attributes = make(map[string]string)
+				}
+				attributes[strings.TrimPrefix(lower, prefix)] = value
+				break
+			}
+		}
+	}
+	if attributes["specversion"] == "" {
+		return nil
+	}
+	return attributes
+}
+
+// unwrapBinary validates the attributes of a CloudEvent whose data is encoded as the content type
+func unwrapBinary(attributes map[string]string, contentType string, data []byte) (Payload, error) {
+	if attributes["specversion"] != cloudEventsVersion {
+		return Payload{}, fmt.Errorf("%w: unsupported specversion %q", ErrInvalidCloudEvent, attributes["specversion"])
+	}
+	for _, required := range []string{"id", "source", "type"} {
+		if attributes[required] == "" {
+			return Payload{}, fmt.Errorf("%w: %s is required", ErrInvalidCloudEvent, required)
+		}
+	}
+	if contentType == ContentTypeCloudEvents {
+		return Payload{}, fmt.Errorf("%w: data can't be another cloudevent", ErrInvalidCloudEvent)
+	}
+
+	event := &Event{
+		ID:      attributes["id"],
+		Source:  attributes["source"],
+		Type:    attributes["type"],
+		Subject: attributes["subject"],
+	}
+	if t := attributes["time"]; t != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return Payload{}, fmt.Errorf("%w: invalid time %q", ErrInvalidCloudEvent, t)
+		}
+		event.Time = parsed
+	}
+
+	payload := Payload{ContentType: contentType, Data: data, Event: event}
+	if dataSchema := attributes["dataschema"]; dataSchema != "" {
+		schema, err := ParseSchema(dataSchema)
+		if err != nil {
+			return Payload{}, err
+		}
+		payload.Schema = schema
+	}
+	return payload, nil
+}
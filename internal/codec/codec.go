@@ -0,0 +1,135 @@
+// Package codec decodes the payload of queue messages by their content type and headers. It unwraps
+// CloudEvents in structured and binary mode and picks a decoder by the schema the payload was written in,
+// so producers using different encodings and schema versions can share a queue.
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/imhasandl/notification-service/internal/broker"
+)
+
+// Content types of payloads
+const (
+	// ContentTypeJSON is JSON, also assumed for messages without a content type
+	ContentTypeJSON = "application/json"
+	// ContentTypeProtobuf is a binary protobuf message
+	ContentTypeProtobuf = "application/protobuf"
+	// ContentTypeCloudEvents is a CloudEvent in structured mode, the payload is its data
+	ContentTypeCloudEvents = "application/cloudevents+json"
+)
+
+// SchemaHeader is the message header naming the schema of the payload, e.g. "notification.v1".
+// CloudEvents name it with their dataschema attribute instead.
+const SchemaHeader = "Schema"
+
+var (
+	// ErrUnsupportedContentType is returned for a content type no decoder handles
+	ErrUnsupportedContentType = errors.New("codec: unsupported content type")
+	// ErrUnknownSchema is returned for a schema and content type no decoder is registered for
+	ErrUnknownSchema = errors.New("codec: unknown schema")
+	// ErrInvalidSchema is returned for a schema that isn't written as <name>.v<version>
+	ErrInvalidSchema = errors.New("codec: invalid schema")
+	// ErrInvalidCloudEvent is returned for a CloudEvent missing required attributes
+	ErrInvalidCloudEvent = errors.New("codec: invalid cloudevent")
+)
+
+// aliases maps the media types producers use for the same encoding to one content type
+var aliases = map[string]string{
+	"":                                ContentTypeJSON,
+	"application/json":                ContentTypeJSON,
+	"text/json":                       ContentTypeJSON,
+	"application/protobuf":            ContentTypeProtobuf,
+	"application/x-protobuf":          ContentTypeProtobuf,
+	"application/vnd.google.protobuf": ContentTypeProtobuf,
+	"application/cloudevents+json":    ContentTypeCloudEvents,
+}
+
+// Schema identifies a payload format and its version
+type Schema struct {
+	Name    string
+	Version int
+}
+
+// String formats the schema as <name>.v<version>
+func (s Schema) String() string {
+	return s.Name + ".v" + strconv.Itoa(s.Version)
+}
+
+// ParseSchema parses <name>.v<version>. A URI is accepted too, its last path segment is parsed,
+// so a CloudEvents dataschema like https://schemas.example.com/notification.v2 works.
+func ParseSchema(s string) (Schema, error) {
+	s = s[strings.LastIndex(s, "/")+1:]
+	i := strings.LastIndex(s, ".v")
+	if i <= 0 {
+		return Schema{}, fmt.Errorf("%w: %q", ErrInvalidSchema, s)
+	}
+
+	version, err := strconv.Atoi(s[i+2:])
+	if err != nil || version < 1 {
+		return Schema{}, fmt.Errorf("%w: %q", ErrInvalidSchema, s)
+	}
+	return Schema{Name: s[:i], Version: version}, nil
+}
+
+// Payload is the data of a message with its encoding and schema, any CloudEvents envelope removed
+type Payload struct {
+	ContentType string
+	// Schema is zero if the message doesn't name one
+	Schema Schema
+	Data   []byte
+	// Event holds the CloudEvents attributes if the message was a CloudEvent
+	Event *Event
+}
+
+// Unwrap extracts the payload of a message
+func Unwrap(msg broker.Message) (Payload, error) {
+	contentType, err := mediaType(msg.ContentType)
+	if err != nil {
+		return Payload{}, err
+	}
+
+	if contentType == ContentTypeCloudEvents {
+		return unwrapStructured(msg.Body)
+	}
+	if attributes := binaryAttributes(msg.Headers); attributes != nil {
+		return unwrapBinary(attributes, contentType, msg.Body)
+	}
+
+	payload := Payload{ContentType: contentType, Data: msg.Body}
+	if name := header(msg.Headers, SchemaHeader); name != "" {
+		payload.Schema, err = ParseSchema(name)
+	}
+	return payload, err
+}
+
+// mediaType normalizes a content type, dropping parameters such as charset
+func mediaType(contentType string) (string, error) {
+	if contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+		}
+		contentType = parsed
+	}
+
+	normalized, ok := aliases[contentType]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+	}
+	return normalized, nil
+}
+
+// header looks up a header regardless of case, transports canonicalize names differently
+func header(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,151 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/imhasandl/notification-service/internal/broker"
+)
+
+// VersionField is the payload field naming its schema version when the message doesn't
+const VersionField = "schema_version"
+
+// Document is a payload decoded into generic JSON values, numbers are kept as json.Number
+type Document map[string]interface{}
+
+// Reader decodes the data of a payload in one content type into a document
+type Reader func(data []byte) (Document, error)
+
+// Upcaster converts a document of one schema version into the next version
+type Upcaster func(doc Document) (Document, error)
+
+// Registry decodes payloads of every version of a schema into the current model T. Each content type
+// has a reader producing a document, documents of older versions are upcast one version at a time,
+// and the result is validated against the current version before it is decoded into T.
+type Registry[T any] struct {
+	current   Schema
+	rules     Property
+	readers   map[string]Reader
+	upcasters map[int]Upcaster
+}
+
+// NewRegistry creates a registry for the current schema, validating documents with its rules.
+// JSON is read out of the box.
+func NewRegistry[T any](current Schema, rules Property) *Registry[T] {
+	r := &Registry[T]{
+		current:   current,
+		rules:     rules,
+		readers:   make(map[string]Reader),
+		upcasters: make(map[int]Upcaster),
+	}
+	r.RegisterReader(ContentTypeJSON, ReadJSON)
+	return r
+}
+
+// RegisterReader adds the reader for payloads encoded as the content type
+func (r *Registry[T]) RegisterReader(contentType string, read Reader) {
+	r.readers[contentType] = read
+}
+
+// RegisterUpcaster adds the upcaster converting documents of the version into the version after it
+func (r *Registry[T]) RegisterUpcaster(from int, upcast Upcaster) {
+	r.upcasters[from] = upcast
+}
+
+// Decode unwraps the payload of a message and decodes it
+func (r *Registry[T]) Decode(msg broker.Message) (T, error) {
+	payload, err := Unwrap(msg)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return r.DecodePayload(payload)
+}
+
+// DecodePayload reads, upcasts, validates and decodes a payload. Payloads that name neither a schema
+// nor a schema_version are version 1.
+func (r *Registry[T]) DecodePayload(payload Payload) (T, error) {
+	var model T
+
+	read, ok := r.readers[payload.ContentType]
+	if !ok {
+		return model, fmt.Errorf("%w: %q", ErrUnsupportedContentType, payload.ContentType)
+	}
+	doc, err := read(payload.Data)
+	if err != nil {
+		return model, err
+	}
+
+	version, err := r.version(payload.Schema, doc)
+	if err != nil {
+		return model, err
+	}
+	for ; version < r.current.Version; version++ {
+		if doc, err = r.upcasters[version](doc); err != nil {
+			return model, err
+		}
+	}
+	doc[VersionField] = json.Number(strconv.Itoa(r.current.Version))
+
+	if err := r.rules.Validate(map[string]interface{}(doc)); err != nil {
+		return model, err
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return model, err
+	}
+	err = json.Unmarshal(data, &model)
+	return model, err
+}
+
+// version finds the schema version of a document and checks it can be upcast to the current one
+func (r *Registry[T]) version(schema Schema, doc Document) (int, error) {
+	version := 1
+	if value, ok := doc[VersionField]; ok {
+		n, err := integer(value)
+		if err != nil {
+			return 0, &ValidationError{Fields: []FieldError{{Field: VersionField, Message: "must be an integer"}}}
+		}
+		version = int(n)
+	}
+
+	if schema != (Schema{}) {
+		if schema.Name != r.current.Name {
+			return 0, fmt.Errorf("%w: %s", ErrUnknownSchema, schema)
+		}
+		if _, ok := doc[VersionField]; ok && version != schema.Version {
+			return 0, &ValidationError{Fields: []FieldError{{Field: VersionField, Message: "doesn't match schema " + schema.String()}}}
+		}
+		version = schema.Version
+	}
+
+	return version, r.upcastable(version)
+}
+
+// upcastable checks that every upcaster from the version to the current one is registered
+func (r *Registry[T]) upcastable(version int) error {
+	if version < 1 || version > r.current.Version {
+		return fmt.Errorf("%w: %s", ErrUnknownSchema, Schema{Name: r.current.Name, Version: version})
+	}
+	for v := version; v < r.current.Version; v++ {
+		if r.upcasters[v] == nil {
+			return fmt.Errorf("%w: no upcaster from %s", ErrUnknownSchema, Schema{Name: r.current.Name, Version: v})
+		}
+	}
+	return nil
+}
+
+// ReadJSON reads a JSON object, keeping numbers exact
+func ReadJSON(data []byte) (Document, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc Document
+	if err := decoder.Decode(&doc); err != nil || doc == nil {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "", Message: "must be a JSON object"}}}
+	}
+	return doc, nil
+}
@@ -0,0 +1,191 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type is the JSON type a property must have
+type Type string
+
+// JSON types
+const (
+	TypeObject  Type = "object"
+	TypeArray   Type = "array"
+	TypeString  Type = "string"
+	TypeInteger Type = "integer"
+	TypeBoolean Type = "boolean"
+)
+
+// Format constrains the text of a string property
+type Format string
+
+// String formats
+const (
+	// FormatUUID is a UUID such as a user id
+	FormatUUID Format = "uuid"
+	// FormatDateTime is an RFC 3339 timestamp
+	FormatDateTime Format = "date-time"
+)
+
+// Property describes a JSON value in the style of a JSON Schema: its type, and for objects the
+// properties they may have, for arrays their items and for strings their format
+type Property struct {
+	Type Type
+	// Required lists the properties an object must have
+	Required []string
+	// Properties are the properties an object may have
+	Properties map[string]Property
+	// AdditionalProperties describes properties not listed in Properties, nil rejects them
+	AdditionalProperties *Property
+	// Items describes every element of an array
+	Items  *Property
+	Format Format
+}
+
+// FieldError is a problem with one field of a payload
+type FieldError struct {
+	// Field is the path of the field, e.g. "translations.es.title" or "experiment.variants[0].weight"
+	Field   string
+	Message string
+}
+
+// ValidationError lists every field of a payload that doesn't match its schema
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error joins the field errors
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		problems[i] = field.Message
+		if field.Field != "" {
+			problems[i] = field.Field + ": " + field.Message
+		}
+	}
+	return "invalid payload: " + strings.Join(problems, "; ")
+}
+
+// Validate checks a decoded JSON document against the property, it returns a *ValidationError
+// listing every mismatch or nil if there is none
+func (p Property) Validate(value interface{}) error {
+	var fields []FieldError
+	p.validate("", value, &fields)
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fields}
+}
+
+func (p Property) validate(path string, value interface{}, fields *[]FieldError) {
+	switch p.Type {
+	case TypeObject:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			*fields = append(*fields, FieldError{Field: path, Message: "must be an object"})
+			return
+		}
+		p.validateObject(path, object, fields)
+	case TypeArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			*fields = append(*fields, FieldError{Field: path, Message: "must be an array"})
+			return
+		}
+		for i, item := range items {
+			if p.Items != nil {
+				p.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, fields)
+			}
+		}
+	default:
+		if message := p.checkScalar(value); message != "" {
+			*fields = append(*fields, FieldError{Field: path, Message: message})
+		}
+	}
+}
+
+// checkScalar returns why a string, integer or boolean value doesn't match, or "" if it does
+func (p Property) checkScalar(value interface{}) string {
+	switch p.Type {
+	case TypeString:
+		s, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		return p.Format.check(s)
+	case TypeInteger:
+		if _, err := integer(value); err != nil {
+			return "must be an integer"
+		}
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return "must be a boolean"
+		}
+	}
+	return ""
+}
+
+// validateObject checks the required, known and additional properties of an object in a stable order
+func (p Property) validateObject(path string, object map[string]interface{}, fields *[]FieldError) {
+	for _, name := range p.Required {
+		if _, ok := object[name]; !ok {
+			*fields = append(*fields, FieldError{Field: join(path, name), Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := p.Properties[name]
+		switch {
+		case ok:
+			property.validate(join(path, name), object[name], fields)
+		case p.AdditionalProperties != nil:
+			p.AdditionalProperties.validate(join(path, name), object[name], fields)
+		default:
+			*fields = append(*fields, FieldError{Field: join(path, name), Message: "is not allowed"})
+		}
+	}
+}
+
+// check returns why the string doesn't match the format, or "" if it does
+func (f Format) check(s string) string {
+	switch f {
+	case FormatUUID:
+		if _, err := uuid.Parse(s); err != nil {
+			return "must be a UUID"
+		}
+	case FormatDateTime:
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			return "must be an RFC 3339 date-time"
+		}
+	}
+	return ""
+}
+
+// integer reads a whole number from a document decoded with json.Decoder.UseNumber
+func integer(value interface{}) (int64, error) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%v is not a number", value)
+	}
+	return number.Int64()
+}
+
+// join appends a property name to a path
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -7,10 +7,133 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const claimDeviceToken = `-- name: ClaimDeviceToken :one
+UPDATE device_tokens
+SET user_id = $2, device_type = $3, app_version = $4, os_version = $5, locale = $6,
+    timezone = $7, device_model = $8, push_permission = $9, updated_at = NOW(),
+    last_seen_at = NOW(), disabled_at = NULL
+WHERE id = $1
+RETURNING id, user_id, device_token, device_type, created_at, updated_at, app_version, os_version, locale, timezone, device_model, push_permission, last_seen_at, disabled_at
+`
+
+type ClaimDeviceTokenParams struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	DeviceType     string
+	AppVersion     string
+	OsVersion      string
+	Locale         string
+	Timezone       string
+	DeviceModel    string
+	PushPermission string
+}
+
+func (q *Queries) ClaimDeviceToken(ctx context.Context, arg ClaimDeviceTokenParams) (DeviceToken, error) {
+	row := q.db.QueryRowContext(ctx, claimDeviceToken,
+		arg.ID,
+		arg.UserID,
+		arg.DeviceType,
+		arg.AppVersion,
+		arg.OsVersion,
+		arg.Locale,
+		arg.Timezone,
+		arg.DeviceModel,
+		arg.PushPermission,
+	)
+	var i DeviceToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.DeviceToken,
+		&i.DeviceType,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.OsVersion,
+		&i.Locale,
+		&i.Timezone,
+		&i.DeviceModel,
+		&i.PushPermission,
+		&i.LastSeenAt,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const countStaleDeviceTokens = `-- name: CountStaleDeviceTokens :one
+SELECT COUNT(*) FILTER (WHERE disabled_at IS NULL) AS active, COUNT(*) AS total
+FROM device_tokens
+WHERE last_seen_at < $1
+`
+
+type CountStaleDeviceTokensRow struct {
+	Active int64
+	Total  int64
+}
+
+func (q *Queries) CountStaleDeviceTokens(ctx context.Context, lastSeenAt time.Time) (CountStaleDeviceTokensRow, error) {
+	row := q.db.QueryRowContext(ctx, countStaleDeviceTokens, lastSeenAt)
+	var i CountStaleDeviceTokensRow
+	err := row.Scan(&i.Active, &i.Total)
+	return i, err
+}
+
+const createDeviceTokenTransfer = `-- name: CreateDeviceTokenTransfer :exec
+INSERT INTO device_token_transfers(id, device_token, from_user_id, to_user_id, transferred_at)
+VALUES ($1, $2, $3, $4, NOW())
+`
+
+type CreateDeviceTokenTransferParams struct {
+	ID          uuid.UUID
+	DeviceToken string
+	FromUserID  uuid.UUID
+	ToUserID    uuid.UUID
+}
+
+func (q *Queries) CreateDeviceTokenTransfer(ctx context.Context, arg CreateDeviceTokenTransferParams) error {
+	_, err := q.db.ExecContext(ctx, createDeviceTokenTransfer,
+		arg.ID,
+		arg.DeviceToken,
+		arg.FromUserID,
+		arg.ToUserID,
+	)
+	return err
+}
+
+const deleteAllDeviceTokens = `-- name: DeleteAllDeviceTokens :many
+DELETE FROM device_tokens
+WHERE user_id = $1
+RETURNING device_token
+`
+
+func (q *Queries) DeleteAllDeviceTokens(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, deleteAllDeviceTokens, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var device_token string
+		if err := rows.Scan(&device_token); err != nil {
+			return nil, err
+		}
+		items = append(items, device_token)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const deleteDeviceToken = `-- name: DeleteDeviceToken :exec
 DELETE FROM device_tokens
 WHERE user_id = $1 AND device_token = $2
@@ -26,35 +149,315 @@ func (q *Queries) DeleteDeviceToken(ctx context.Context, arg DeleteDeviceTokenPa
 	return err
 }
 
+const deleteStaleDeviceTokens = `-- name: DeleteStaleDeviceTokens :many
+DELETE FROM device_tokens
+WHERE last_seen_at < $1
+RETURNING user_id, device_token
+`
+
+type DeleteStaleDeviceTokensRow struct {
+	UserID      uuid.UUID
+	DeviceToken string
+}
+
+func (q *Queries) DeleteStaleDeviceTokens(ctx context.Context, lastSeenAt time.Time) ([]DeleteStaleDeviceTokensRow, error) {
+	rows, err := q.db.QueryContext(ctx, deleteStaleDeviceTokens, lastSeenAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeleteStaleDeviceTokensRow
+	for rows.Next() {
+		var i DeleteStaleDeviceTokensRow
+		if err := rows.Scan(&i.UserID, &i.DeviceToken); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const disableStaleDeviceTokens = `-- name: DisableStaleDeviceTokens :execrows
+UPDATE device_tokens
+SET disabled_at = NOW()
+WHERE last_seen_at < $1 AND disabled_at IS NULL
+`
+
+func (q *Queries) DisableStaleDeviceTokens(ctx context.Context, lastSeenAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, disableStaleDeviceTokens, lastSeenAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const evictDeviceTokens = `-- name: EvictDeviceTokens :many
+DELETE FROM device_tokens
+WHERE id IN (
+    SELECT evictable.id FROM device_tokens AS evictable
+    WHERE evictable.user_id = $1 AND evictable.id <> $2 AND evictable.disabled_at IS NULL
+    ORDER BY evictable.last_seen_at DESC, evictable.created_at DESC
+    OFFSET $3
+    FOR UPDATE
+)
+RETURNING id, user_id, device_token, device_type, created_at, updated_at, app_version, os_version, locale, timezone, device_model, push_permission, last_seen_at, disabled_at
+`
+
+type EvictDeviceTokensParams struct {
+	UserID uuid.UUID
+	ID     uuid.UUID
+	Offset int32
+}
+
+func (q *Queries) EvictDeviceTokens(ctx context.Context, arg EvictDeviceTokensParams) ([]DeviceToken, error) {
+	rows, err := q.db.QueryContext(ctx, evictDeviceTokens, arg.UserID, arg.ID, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeviceToken
+	for rows.Next() {
+		var i DeviceToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.DeviceToken,
+			&i.DeviceType,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AppVersion,
+			&i.OsVersion,
+			&i.Locale,
+			&i.Timezone,
+			&i.DeviceModel,
+			&i.PushPermission,
+			&i.LastSeenAt,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getDeviceTokensByUserID = `-- name: GetDeviceTokensByUserID :one
-SELECT device_token FROM device_tokens
-WHERE user_id = $1
+SELECT id, user_id, device_token, device_type, created_at, updated_at, app_version, os_version, locale, timezone, device_model, push_permission, last_seen_at, disabled_at FROM device_tokens
+WHERE user_id = $1 AND disabled_at IS NULL
+ORDER BY last_seen_at DESC NULLS LAST, updated_at DESC
+LIMIT 1
 `
 
-func (q *Queries) GetDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) (string, error) {
+// Pushes go to the most recently seen device, the one eviction keeps longest
+func (q *Queries) GetDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) (DeviceToken, error) {
 	row := q.db.QueryRowContext(ctx, getDeviceTokensByUserID, userID)
-	var device_token string
-	err := row.Scan(&device_token)
-	return device_token, err
+	var i DeviceToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.DeviceToken,
+		&i.DeviceType,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.OsVersion,
+		&i.Locale,
+		&i.Timezone,
+		&i.DeviceModel,
+		&i.PushPermission,
+		&i.LastSeenAt,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const listDeviceTokensByUserID = `-- name: ListDeviceTokensByUserID :many
+SELECT id, user_id, device_token, device_type, created_at, updated_at, app_version, os_version, locale, timezone, device_model, push_permission, last_seen_at, disabled_at FROM device_tokens
+WHERE user_id = $1
+ORDER BY updated_at DESC
+`
+
+func (q *Queries) ListDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) ([]DeviceToken, error) {
+	rows, err := q.db.QueryContext(ctx, listDeviceTokensByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeviceToken
+	for rows.Next() {
+		var i DeviceToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.DeviceToken,
+			&i.DeviceType,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AppVersion,
+			&i.OsVersion,
+			&i.Locale,
+			&i.Timezone,
+			&i.DeviceModel,
+			&i.PushPermission,
+			&i.LastSeenAt,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockDeviceToken = `-- name: LockDeviceToken :one
+SELECT id, user_id, device_token, device_type, created_at, updated_at, app_version, os_version, locale, timezone, device_model, push_permission, last_seen_at, disabled_at FROM device_tokens
+WHERE device_token = $1
+FOR UPDATE
+`
+
+func (q *Queries) LockDeviceToken(ctx context.Context, deviceToken string) (DeviceToken, error) {
+	row := q.db.QueryRowContext(ctx, lockDeviceToken, deviceToken)
+	var i DeviceToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.DeviceToken,
+		&i.DeviceType,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.OsVersion,
+		&i.Locale,
+		&i.Timezone,
+		&i.DeviceModel,
+		&i.PushPermission,
+		&i.LastSeenAt,
+		&i.DisabledAt,
+	)
+	return i, err
 }
 
 const registerDeviceToken = `-- name: RegisterDeviceToken :one
-INSERT INTO device_tokens(id, user_id, device_token, device_type, created_at, updated_at)
-VALUES ($1, $2, $3, $4, NOW(), NOW())
-ON CONFLICT (user_id, device_token)
-DO UPDATE SET updated_at = NOW(), device_type = $3
-RETURNING id, user_id, device_token, device_type, created_at, updated_at
+INSERT INTO device_tokens(id, user_id, device_token, device_type, app_version, os_version, locale, timezone, device_model, push_permission, created_at, updated_at, last_seen_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW(), NOW())
+ON CONFLICT (device_token) DO NOTHING
+RETURNING id, user_id, device_token, device_type, created_at, updated_at, app_version, os_version, locale, timezone, device_model, push_permission, last_seen_at, disabled_at
 `
 
 type RegisterDeviceTokenParams struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	DeviceToken    string
+	DeviceType     string
+	AppVersion     string
+	OsVersion      string
+	Locale         string
+	Timezone       string
+	DeviceModel    string
+	PushPermission string
+}
+
+func (q *Queries) RegisterDeviceToken(ctx context.Context, arg RegisterDeviceTokenParams) (DeviceToken, error) {
+	row := q.db.QueryRowContext(ctx, registerDeviceToken,
+		arg.ID,
+		arg.UserID,
+		arg.DeviceToken,
+		arg.DeviceType,
+		arg.AppVersion,
+		arg.OsVersion,
+		arg.Locale,
+		arg.Timezone,
+		arg.DeviceModel,
+		arg.PushPermission,
+	)
+	var i DeviceToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.DeviceToken,
+		&i.DeviceType,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.OsVersion,
+		&i.Locale,
+		&i.Timezone,
+		&i.DeviceModel,
+		&i.PushPermission,
+		&i.LastSeenAt,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const touchDeviceToken = `-- name: TouchDeviceToken :one
+UPDATE device_tokens
+SET last_seen_at = NOW(), disabled_at = NULL
+WHERE user_id = $1 AND device_token = $2
+RETURNING id, user_id, device_token, device_type, created_at, updated_at, app_version, os_version, locale, timezone, device_model, push_permission, last_seen_at, disabled_at
+`
+
+type TouchDeviceTokenParams struct {
+	UserID      uuid.UUID
+	DeviceToken string
+}
+
+func (q *Queries) TouchDeviceToken(ctx context.Context, arg TouchDeviceTokenParams) (DeviceToken, error) {
+	row := q.db.QueryRowContext(ctx, touchDeviceToken, arg.UserID, arg.DeviceToken)
+	var i DeviceToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.DeviceToken,
+		&i.DeviceType,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.OsVersion,
+		&i.Locale,
+		&i.Timezone,
+		&i.DeviceModel,
+		&i.PushPermission,
+		&i.LastSeenAt,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const updateDeviceToken = `-- name: UpdateDeviceToken :one
+UPDATE device_tokens
+SET device_token = $3, device_type = $4, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING id, user_id, device_token, device_type, created_at, updated_at, app_version, os_version, locale, timezone, device_model, push_permission, last_seen_at, disabled_at
+`
+
+type UpdateDeviceTokenParams struct {
 	ID          uuid.UUID
 	UserID      uuid.UUID
 	DeviceToken string
 	DeviceType  string
 }
 
-func (q *Queries) RegisterDeviceToken(ctx context.Context, arg RegisterDeviceTokenParams) (DeviceToken, error) {
-	row := q.db.QueryRowContext(ctx, registerDeviceToken,
+func (q *Queries) UpdateDeviceToken(ctx context.Context, arg UpdateDeviceTokenParams) (DeviceToken, error) {
+	row := q.db.QueryRowContext(ctx, updateDeviceToken,
 		arg.ID,
 		arg.UserID,
 		arg.DeviceToken,
@@ -68,6 +471,14 @@ func (q *Queries) RegisterDeviceToken(ctx context.Context, arg RegisterDeviceTok
 		&i.DeviceType,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.OsVersion,
+		&i.Locale,
+		&i.Timezone,
+		&i.DeviceModel,
+		&i.PushPermission,
+		&i.LastSeenAt,
+		&i.DisabledAt,
 	)
 	return i, err
 }
@@ -5,11 +5,38 @@
 package database
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Campaign struct {
+	ID                 uuid.UUID
+	Name               string
+	Notification       json.RawMessage
+	Segment            json.RawMessage
+	Status             string
+	ScheduledAt        sql.NullTime
+	RatePerSecond      int32
+	BatchSize          int32
+	Total              int64
+	Processed          int64
+	Sent               int64
+	Skipped            int64
+	Failed             int64
+	LastUserID         uuid.NullUUID
+	SegmentEvaluatedAt sql.NullTime
+	LeaseID            uuid.NullUUID
+	LeasedUntil        sql.NullTime
+	LastError          string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	StartedAt          sql.NullTime
+	CompletedAt        sql.NullTime
+}
+
 type Comment struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
@@ -19,12 +46,38 @@ type Comment struct {
 }
 
 type DeviceToken struct {
-	ID          uuid.UUID
-	UserID      uuid.UUID
-	DeviceToken string
-	DeviceType  string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	DeviceToken    string
+	DeviceType     string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	AppVersion     string
+	OsVersion      string
+	Locale         string
+	Timezone       string
+	DeviceModel    string
+	PushPermission string
+	LastSeenAt     time.Time
+	DisabledAt     sql.NullTime
+}
+
+type DeviceTokenTransfer struct {
+	ID            uuid.UUID
+	DeviceToken   string
+	FromUserID    uuid.UUID
+	ToUserID      uuid.UUID
+	TransferredAt time.Time
+}
+
+type ExperimentAssignment struct {
+	MessageID  uuid.UUID
+	Experiment string
+	Variant    string
+	UserID     uuid.UUID
+	SentAt     time.Time
+	OpenedAt   sql.NullTime
+	ClickedAt  sql.NullTime
 }
 
 type Message struct {
@@ -35,6 +88,30 @@ type Message struct {
 	Content    string
 }
 
+type NotificationTracking struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	Category      string
+	DeliveredAt   time.Time
+	OpenedAt      sql.NullTime
+	DismissedAt   sql.NullTime
+	CorrelationID string
+}
+
+type Outbox struct {
+	ID            uuid.UUID
+	Exchange      string
+	RoutingKey    string
+	CorrelationID string
+	Body          []byte
+	CreatedAt     time.Time
+	PublishedAt   sql.NullTime
+	Attempts      int32
+	LastError     string
+	NextAttemptAt time.Time
+	ParkedAt      sql.NullTime
+}
+
 type Post struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
@@ -60,6 +137,24 @@ type Report struct {
 	Reason     string
 }
 
+type RoutingFallback struct {
+	ID        uuid.UUID
+	MessageID uuid.UUID
+	Channel   string
+	Message   []byte
+	DueAt     time.Time
+	Attempts  int32
+	LastError string
+	CreatedAt time.Time
+}
+
+type TopicSubscription struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Topic     string
+	CreatedAt time.Time
+}
+
 type User struct {
 	ID               uuid.UUID
 	CreatedAt        time.Time
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// SegmentFilter is a compiled condition over the users table. Clause returns a SQL boolean
+// expression using $1..$n placeholders together with the n arguments that fill them.
+type SegmentFilter interface {
+	Clause() (string, []interface{})
+}
+
+// CountSegmentUsers counts the users matching the segment
+func (s *Store) CountSegmentUsers(ctx context.Context, filter SegmentFilter) (int64, error) {
+	clause, args := filter.Clause()
+
+	// The clause only references whitelisted columns, every value is passed as an argument
+	query := fmt.Sprintf("SELECT COUNT(*) FROM users WHERE (%s)", clause) // #nosec G201
+
+	var count int64
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&count)
+	return count, err
+}
+
+// ListSegmentUsers returns up to limit ids of users matching the segment, ordered by id and starting after the given id
+func (s *Store) ListSegmentUsers(ctx context.Context, filter SegmentFilter, after uuid.UUID, limit int32) ([]uuid.UUID, error) {
+	clause, args := filter.Clause()
+
+	// The clause only references whitelisted columns, every value is passed as an argument
+	query := fmt.Sprintf("SELECT id FROM users WHERE (%s) AND id > $%d ORDER BY id LIMIT $%d", clause, len(args)+1, len(args)+2) // #nosec G201
+	args = append(append([]interface{}{}, args...), after, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
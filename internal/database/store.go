@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Store provides the generated queries along with the ability to run them in a transaction
+type Store struct {
+	*Queries
+	db *sql.DB
+}
+
+// NewStore creates a store on top of the database connection
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		Queries: New(db),
+		db:      db,
+	}
+}
+
+// ExecTx runs fn inside a transaction, committing if it returns nil and rolling back otherwise
+func (s *Store) ExecTx(ctx context.Context, fn func(Querier) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(s.WithTx(tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx err: %v, rollback err: %w", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
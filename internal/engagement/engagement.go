@@ -0,0 +1,119 @@
+package engagement
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/events"
+	"github.com/imhasandl/notification-service/internal/routing"
+)
+
+// Store defines the database operations for notification tracking. Tracking rows are written
+// in a transaction together with the events describing them.
+type Store interface {
+	ExecTx(ctx context.Context, fn func(database.Querier) error) error
+	MarkNotificationDismissed(ctx context.Context, arg database.MarkNotificationDismissedParams) (int64, error)
+	GetEngagementByCategory(ctx context.Context, deliveredAt time.Time) ([]database.GetEngagementByCategoryRow, error)
+	IsNotificationSeen(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+// Tracker stores every delivered notification under its tracking id and records what the user
+// did with it, queueing a delivery status event for each change
+type Tracker struct {
+	store Store
+}
+
+// NewTracker creates a tracker backed by the store
+func NewTracker(store Store) *Tracker {
+	return &Tracker{store: store}
+}
+
+// Observe queues the outcome of a dispatched message and stores delivered ones so apps can
+// report on them by their tracking id, it implements routing.Observer. Errors are only logged,
+// losing a tracking row skews the metrics but shouldn't fail a notification that was already sent.
+func (t *Tracker) Observe(ctx context.Context, msg routing.Message, report *routing.Report) {
+	err := t.store.ExecTx(ctx, func(q database.Querier) error {
+		if report.Delivered() {
+			err := q.CreateNotificationTracking(ctx, database.CreateNotificationTrackingParams{
+				ID:            msg.ID,
+				UserID:        msg.ReceiverID,
+				Category:      msg.Category,
+				CorrelationID: events.CorrelationID(ctx),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return events.Enqueue(ctx, q, events.Dispatched(ctx, msg, report))
+	})
+	if err != nil {
+		log.Printf("engagement: can't track message %s: %v", msg.ID, err)
+	}
+}
+
+// Opened records that the user opened the notification, reporting it again keeps the first time
+// and queues the opened event only once. It returns sql.ErrNoRows if the notification wasn't
+// delivered to that user.
+func (t *Tracker) Opened(ctx context.Context, trackingID, userID uuid.UUID) error {
+	return t.store.ExecTx(ctx, func(q database.Querier) error {
+		opened, err := q.MarkNotificationOpened(ctx, database.MarkNotificationOpenedParams{ID: trackingID, UserID: userID})
+		if err != nil || !opened.FirstOpen {
+			return err
+		}
+		return events.Enqueue(ctx, q, events.Opened(opened))
+	})
+}
+
+// Dismissed records that the user swiped the notification away without opening it
+func (t *Tracker) Dismissed(ctx context.Context, trackingID, userID uuid.UUID) (bool, error) {
+	rows, err := t.store.MarkNotificationDismissed(ctx, database.MarkNotificationDismissedParams{ID: trackingID, UserID: userID})
+	return rows > 0, err
+}
+
+// Delivered reports whether the app reported opening or dismissing the notification, the only proof
+// that it reached the user. It implements routing.DeliveryChecker for delayed fallbacks.
+func (t *Tracker) Delivered(ctx context.Context, trackingID uuid.UUID) (bool, error) {
+	return t.store.IsNotificationSeen(ctx, trackingID)
+}
+
+// CategoryStats is the engagement with the notifications of one category
+type CategoryStats struct {
+	Category    string
+	Delivered   int64
+	Opened      int64
+	Dismissed   int64
+	OpenRate    float64
+	DismissRate float64
+}
+
+// Stats aggregates the notifications delivered since the given time by category
+func (t *Tracker) Stats(ctx context.Context, since time.Time) ([]CategoryStats, error) {
+	rows, err := t.store.GetEngagementByCategory(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]CategoryStats, 0, len(rows))
+	for _, row := range rows {
+		stats = append(stats, CategoryStats{
+			Category:    row.Category,
+			Delivered:   row.Delivered,
+			Opened:      row.Opened,
+			Dismissed:   row.Dismissed,
+			OpenRate:    rate(row.Opened, row.Delivered),
+			DismissRate: rate(row.Dismissed, row.Delivered),
+		})
+	}
+	return stats, nil
+}
+
+// rate divides safely, a category without deliveries has a rate of zero
+func rate(n, delivered int64) float64 {
+	if delivered == 0 {
+		return 0
+	}
+	return float64(n) / float64(delivered)
+}
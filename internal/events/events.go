@@ -0,0 +1,125 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/broker"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/routing"
+)
+
+// Event types, also used as routing keys on the events exchange
+const (
+	// TypeSent is published when a channel delivered the notification
+	TypeSent = "notification.sent"
+	// TypeFailed is published when the notification had a target but every send failed
+	TypeFailed = "notification.failed"
+	// TypeSuppressed is published when there was nowhere to deliver, e.g. no device or push turned off
+	TypeSuppressed = "notification.suppressed"
+	// TypeOpened is published the first time the user opens the notification
+	TypeOpened = "notification.opened"
+)
+
+// Event is the JSON body of a delivery status event
+type Event struct {
+	ID            uuid.UUID `json:"id"`
+	Type          string    `json:"type"`
+	TrackingID    uuid.UUID `json:"tracking_id"`
+	ReceiverID    uuid.UUID `json:"receiver_id"`
+	Category      string    `json:"category"`
+	Channel       string    `json:"channel,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+type correlationKey struct{}
+
+// WithCorrelationID returns a context carrying the correlation id of the request being handled
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, correlationID)
+}
+
+// CorrelationID returns the correlation id carried by the context, if any
+func CorrelationID(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationKey{}).(string)
+	return correlationID
+}
+
+// Dispatched describes the outcome of a dispatched message
+func Dispatched(ctx context.Context, msg routing.Message, report *routing.Report) Event {
+	event := Event{
+		TrackingID:    msg.ID,
+		ReceiverID:    msg.ReceiverID,
+		Category:      msg.Category,
+		CorrelationID: CorrelationID(ctx),
+	}
+	event.Type, event.Channel, event.Reason = outcome(report)
+	return event
+}
+
+// Opened describes the first open of a tracked notification
+func Opened(tracking database.MarkNotificationOpenedRow) Event {
+	return Event{
+		Type:          TypeOpened,
+		TrackingID:    tracking.ID,
+		ReceiverID:    tracking.UserID,
+		Category:      tracking.Category,
+		CorrelationID: tracking.CorrelationID,
+	}
+}
+
+// outcome classifies a report, returning the event type with the channel and reason that decided it
+func outcome(report *routing.Report) (string, string, string) {
+	var failed, suppressed *routing.Decision
+	for i, decision := range report.Decisions {
+		switch decision.Outcome {
+		case routing.Delivered:
+			return TypeSent, decision.Channel, decision.Reason
+		case routing.Failed:
+			if failed == nil {
+				failed = &report.Decisions[i]
+			}
+		case routing.NoTargetFound:
+			if suppressed == nil {
+				suppressed = &report.Decisions[i]
+			}
+		}
+	}
+
+	if failed != nil {
+		return TypeFailed, failed.Channel, failed.Reason
+	}
+	if suppressed != nil {
+		return TypeSuppressed, suppressed.Channel, suppressed.Reason
+	}
+	return TypeSuppressed, "", "no channel configured"
+}
+
+// OutboxWriter stores messages in the outbox, usually a transaction's querier
+type OutboxWriter interface {
+	CreateOutboxMessage(ctx context.Context, arg database.CreateOutboxMessageParams) error
+}
+
+// Enqueue stamps the event and writes it to the outbox. Pass the querier of the transaction
+// changing the state the event describes, so the event is stored if and only if it commits.
+func Enqueue(ctx context.Context, q OutboxWriter, event Event) error {
+	event.ID = uuid.New()
+	event.OccurredAt = time.Now().UTC()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return q.CreateOutboxMessage(ctx, database.CreateOutboxMessageParams{
+		ID:            event.ID,
+		Exchange:      broker.EventsTopic,
+		RoutingKey:    event.Type,
+		CorrelationID: event.CorrelationID,
+		Body:          body,
+	})
+}
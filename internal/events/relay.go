@@ -0,0 +1,175 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/imhasandl/notification-service/internal/broker"
+	"github.com/imhasandl/notification-service/internal/database"
+)
+
+const (
+	// DefaultRelayInterval is how often the relay looks for pending outbox messages
+	DefaultRelayInterval = time.Second
+	// DefaultRelayBatchSize is how many messages the relay publishes per transaction
+	DefaultRelayBatchSize = 100
+	// DefaultRetention is how long published messages stay in the outbox before they are pruned
+	DefaultRetention = 7 * 24 * time.Hour
+	// DefaultMaxAttempts is how often a message is tried before it is parked, with the backoff
+	// capped at five minutes this rides out a broker outage of about an hour
+	DefaultMaxAttempts = 20
+	// pruneInterval is how often published messages past the retention are deleted
+	pruneInterval = time.Hour
+	// minBackoff and maxBackoff bound the wait before a failed message is tried again
+	minBackoff = time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// RelayStore defines the database operations the relay needs
+type RelayStore interface {
+	ExecTx(ctx context.Context, fn func(database.Querier) error) error
+	DeletePublishedOutboxMessages(ctx context.Context, publishedAt sql.NullTime) (int64, error)
+}
+
+// Relay publishes outbox messages to the broker in the order they were written. A message is
+// marked published only once the broker confirmed it, so a crash in between publishes it
+// again: consumers get every event at least once and can deduplicate on its message id.
+// A message that fails waits out an exponential backoff while later ones go ahead, and is
+// parked once it runs out of attempts.
+type Relay struct {
+	store       RelayStore
+	publisher   broker.Publisher
+	interval    time.Duration
+	batchSize   int32
+	retention   time.Duration
+	maxAttempts int32
+}
+
+// NewRelay creates a relay reading the outbox from the store and publishing through the publisher
+func NewRelay(store RelayStore, publisher broker.Publisher) *Relay {
+	return &Relay{
+		store:       store,
+		publisher:   publisher,
+		interval:    DefaultRelayInterval,
+		batchSize:   DefaultRelayBatchSize,
+		retention:   DefaultRetention,
+		maxAttempts: DefaultMaxAttempts,
+	}
+}
+
+// Run relays pending messages until the context is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	lastPrune := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Keep going while full batches show there is a backlog
+		for {
+			published, err := r.RelayOnce(ctx)
+			if err != nil {
+				log.Printf("events: relay stopped: %v", err)
+			}
+			if err != nil || published < int(r.batchSize) {
+				break
+			}
+		}
+
+		if time.Since(lastPrune) >= pruneInterval {
+			r.prune(ctx)
+			lastPrune = time.Now()
+		}
+	}
+}
+
+// RelayOnce publishes one batch of pending messages and returns how many were published.
+// It stops at the first message the broker doesn't confirm, as the broker is likely down, and
+// records the failure so the message is retried once its backoff elapsed.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	published := 0
+	var publishErr error
+
+	err := r.store.ExecTx(ctx, func(q database.Querier) error {
+		pending, err := q.ListPendingOutboxMessages(ctx, r.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, message := range pending {
+			publishErr = r.publisher.Publish(ctx, message.Exchange, message.RoutingKey, brokerMessage(message))
+			if publishErr != nil {
+				return r.recordFailure(ctx, q, message, publishErr)
+			}
+
+			if err := q.MarkOutboxMessagePublished(ctx, message.ID); err != nil {
+				return err
+			}
+			published++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return published, publishErr
+}
+
+// recordFailure schedules the next attempt of a message the broker didn't confirm, or parks it
+// once it ran out of attempts so it stops holding up the outbox
+func (r *Relay) recordFailure(ctx context.Context, q database.Querier, message database.Outbox, publishErr error) error {
+	if message.Attempts+1 >= r.maxAttempts {
+		log.Printf("events: parking outbox message %s after %d attempts: %v", message.ID, message.Attempts+1, publishErr)
+		return q.ParkOutboxMessage(ctx, database.ParkOutboxMessageParams{
+			ID:        message.ID,
+			LastError: publishErr.Error(),
+		})
+	}
+
+	return q.RecordOutboxFailure(ctx, database.RecordOutboxFailureParams{
+		ID:            message.ID,
+		LastError:     publishErr.Error(),
+		NextAttemptAt: time.Now().Add(Backoff(message.Attempts + 1)),
+	})
+}
+
+// Backoff is the wait before the next attempt of a message that failed the given number of
+// times, doubling from a second up to five minutes
+func Backoff(attempts int32) time.Duration {
+	backoff := minBackoff
+	for i := int32(1); i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// prune deletes published messages older than the retention
+func (r *Relay) prune(ctx context.Context) {
+	deleted, err := r.store.DeletePublishedOutboxMessages(ctx, sql.NullTime{Time: time.Now().Add(-r.retention), Valid: true})
+	if err != nil {
+		log.Printf("events: can't prune the outbox: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("events: pruned %d published outbox messages", deleted)
+	}
+}
+
+// brokerMessage builds the message for an outbox row, the exchange column holds the topic
+func brokerMessage(message database.Outbox) broker.Message {
+	return broker.Message{
+		ID:            message.ID.String(),
+		CorrelationID: message.CorrelationID,
+		ContentType:   "application/json",
+		Timestamp:     message.CreatedAt,
+		Body:          message.Body,
+	}
+}
@@ -0,0 +1,192 @@
+package experiments
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/routing"
+)
+
+const (
+	// EventOpened is reported when the user taps the notification
+	EventOpened = "opened"
+	// EventClicked is reported when the user follows the notification's call to action
+	EventClicked = "clicked"
+	// maxVariants limits how many variants one experiment can have
+	maxVariants = 10
+)
+
+// ErrInvalidEvent is returned for engagement events other than opened and clicked
+var ErrInvalidEvent = errors.New("event must be opened or clicked")
+
+// Variant is one version of a notification's content. Users are assigned to variants in
+// proportion to their weights.
+type Variant struct {
+	Name         string                         `json:"name"`
+	Weight       uint32                         `json:"weight"`
+	Title        string                         `json:"title"`
+	Content      string                         `json:"content"`
+	Translations map[string]routing.Translation `json:"translations,omitempty"`
+}
+
+// Experiment tests content variants of a notification. The key identifies the experiment
+// in stats and seeds the bucketing, so reusing a key keeps every user on the same variant.
+type Experiment struct {
+	Key      string    `json:"key"`
+	Variants []Variant `json:"variants"`
+}
+
+// Validate checks that the experiment has a key and at least two uniquely named, weighted variants
+func (e *Experiment) Validate() error {
+	if e.Key == "" {
+		return errors.New("experiment key is required")
+	}
+	if len(e.Variants) < 2 || len(e.Variants) > maxVariants {
+		return fmt.Errorf("experiment needs between 2 and %d variants", maxVariants)
+	}
+
+	names := make(map[string]bool, len(e.Variants))
+	for _, variant := range e.Variants {
+		if variant.Name == "" {
+			return errors.New("variant name is required")
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("variant %q is defined twice", variant.Name)
+		}
+		if variant.Weight == 0 {
+			return fmt.Errorf("variant %q needs a positive weight", variant.Name)
+		}
+		names[variant.Name] = true
+	}
+	return nil
+}
+
+// Assign picks the user's variant. The same user always lands in the same bucket of an
+// experiment, so resends and campaigns spread over days show them consistent content.
+func (e *Experiment) Assign(userID uuid.UUID) Variant {
+	var total uint64
+	for _, variant := range e.Variants {
+		total += uint64(variant.Weight)
+	}
+
+	bucket := hash(e.Key, userID) % total
+	for _, variant := range e.Variants {
+		if bucket < uint64(variant.Weight) {
+			return variant
+		}
+		bucket -= uint64(variant.Weight)
+	}
+	return e.Variants[len(e.Variants)-1]
+}
+
+// hash maps the experiment key and user to a uniformly distributed number
+func hash(key string, userID uuid.UUID) uint64 {
+	h := sha256.New()
+	h.Write([]byte(key))
+	h.Write(userID[:])
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+// Store defines the database operations for experiment assignments
+type Store interface {
+	CreateExperimentAssignment(ctx context.Context, arg database.CreateExperimentAssignmentParams) error
+	RecordExperimentOpen(ctx context.Context, arg database.RecordExperimentOpenParams) (int64, error)
+	RecordExperimentClick(ctx context.Context, arg database.RecordExperimentClickParams) (int64, error)
+	GetExperimentStats(ctx context.Context, experiment string) ([]database.GetExperimentStatsRow, error)
+}
+
+// Tracker records which variant each user received and how they engaged with it
+type Tracker struct {
+	store Store
+}
+
+// NewTracker creates a tracker storing assignments in the store
+func NewTracker(store Store) *Tracker {
+	return &Tracker{store: store}
+}
+
+// Observe stores the variant of a delivered experiment message, it implements routing.Observer.
+// Messages outside an experiment or that no channel delivered are ignored. Errors are logged,
+// a lost assignment only skews the stats and shouldn't fail a notification that was already sent.
+func (t *Tracker) Observe(ctx context.Context, msg routing.Message, report *routing.Report) {
+	if msg.Experiment == "" || !report.Delivered() {
+		return
+	}
+
+	err := t.store.CreateExperimentAssignment(ctx, database.CreateExperimentAssignmentParams{
+		MessageID:  msg.ID,
+		Experiment: msg.Experiment,
+		Variant:    msg.Variant,
+		UserID:     msg.ReceiverID,
+	})
+	if err != nil {
+		log.Printf("experiments: can't record variant %s of %s for message %s: %v", msg.Variant, msg.Experiment, msg.ID, err)
+	}
+}
+
+// Engage records an opened or clicked event for a message the user received.
+// It reports whether the message was part of an experiment sent to the user.
+func (t *Tracker) Engage(ctx context.Context, messageID, userID uuid.UUID, event string) (bool, error) {
+	var (
+		rows int64
+		err  error
+	)
+
+	switch event {
+	case EventOpened:
+		rows, err = t.store.RecordExperimentOpen(ctx, database.RecordExperimentOpenParams{MessageID: messageID, UserID: userID})
+	case EventClicked:
+		rows, err = t.store.RecordExperimentClick(ctx, database.RecordExperimentClickParams{MessageID: messageID, UserID: userID})
+	default:
+		return false, ErrInvalidEvent
+	}
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// VariantStats is the engagement of one variant
+type VariantStats struct {
+	Variant   string
+	Sent      int64
+	Opened    int64
+	Clicked   int64
+	OpenRate  float64
+	ClickRate float64
+}
+
+// Stats returns the engagement of every variant of the experiment sent so far
+func (t *Tracker) Stats(ctx context.Context, experiment string) ([]VariantStats, error) {
+	rows, err := t.store.GetExperimentStats(ctx, experiment)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]VariantStats, 0, len(rows))
+	for _, row := range rows {
+		stats = append(stats, VariantStats{
+			Variant:   row.Variant,
+			Sent:      row.Sent,
+			Opened:    row.Opened,
+			Clicked:   row.Clicked,
+			OpenRate:  rate(row.Opened, row.Sent),
+			ClickRate: rate(row.Clicked, row.Sent),
+		})
+	}
+	return stats, nil
+}
+
+// rate divides safely, an experiment without sends has a rate of zero
+func rate(n, sent int64) float64 {
+	if sent == 0 {
+		return 0
+	}
+	return float64(n) / float64(sent)
+}
@@ -18,6 +18,8 @@ type ClientInterface interface {
 // MessagingClient defines the interface for Firebase messaging operations
 type MessagingClient interface {
 	Send(ctx context.Context, message *messaging.Message) (string, error)
+	SubscribeToTopic(ctx context.Context, tokens []string, topic string) (*messaging.TopicManagementResponse, error)
+	UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) (*messaging.TopicManagementResponse, error)
 }
 
 // Client represents a Firebase client with messaging capabilities
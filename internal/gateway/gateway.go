@@ -0,0 +1,144 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/imhasandl/notification-service/internal/auth"
+	"github.com/imhasandl/notification-service/internal/hub"
+)
+
+const (
+	// HeartbeatInterval is how often idle connections are pinged to keep proxies from closing them
+	HeartbeatInterval = 25 * time.Second
+	// writeTimeout bounds a single write to a client so a stalled socket can't hold a goroutine forever
+	writeTimeout = 10 * time.Second
+)
+
+// Authenticator validates the bearer tokens of browser clients, *auth.Authenticator implements it
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (auth.Identity, error)
+}
+
+// Gateway serves live notifications to browser clients over WebSocket and Server-Sent Events
+type Gateway struct {
+	hub            *hub.Hub
+	authenticator  Authenticator
+	allowedOrigins map[string]bool
+	upgrader       websocket.Upgrader
+}
+
+// Option configures a Gateway
+type Option func(*Gateway)
+
+// WithAllowedOrigins lets pages served from the origins, e.g. "https://app.example.com", open WebSockets.
+// Pages on the gateway's own host are always allowed.
+func WithAllowedOrigins(origins ...string) Option {
+	return func(g *Gateway) {
+		for _, origin := range origins {
+			g.allowedOrigins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+		}
+	}
+}
+
+// NewGateway creates a gateway that streams events from the given hub to the users whose tokens the
+// authenticator accepts, a token's subject is the user id
+func NewGateway(h *hub.Hub, authenticator Authenticator, opts ...Option) *Gateway {
+	g := &Gateway{
+		hub:            h,
+		authenticator:  authenticator,
+		allowedOrigins: make(map[string]bool),
+	}
+	g.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     g.checkOrigin,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// checkOrigin rejects WebSocket upgrades from pages on other sites, which would otherwise ride on the
+// user's credentials. Clients that aren't browsers send no Origin and are allowed.
+func (g *Gateway) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if g.allowedOrigins[strings.ToLower(origin)] {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// Handler returns the HTTP routes served by the gateway
+func (g *Gateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ws", g.serveWebSocket)
+	mux.HandleFunc("GET /events", g.serveSSE)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+// NewHTTPServer wraps the gateway handler in an http.Server with sane timeouts.
+// No write timeout is set because streaming connections are long lived.
+func NewHTTPServer(addr string, g *Gateway) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           g.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * HeartbeatInterval,
+	}
+}
+
+// authenticate resolves the user behind the request or writes a 401 response
+func (g *Gateway) authenticate(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	token, err := bearerToken(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+
+	identity, err := g.authenticator.Authenticate(r.Context(), token)
+	if err != nil {
+		log.Printf("gateway: rejected token: %v", err)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+
+	// Services have tokens too, but only users have notifications to stream
+	userID, err := uuid.Parse(identity.Subject)
+	if err != nil {
+		http.Error(w, "token isn't issued to a user", http.StatusForbidden)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// bearerToken reads the token from the Authorization header. Browsers can't set headers on WebSocket
+// requests, so upgrades may carry it in the access_token query parameter instead; other requests
+// can't, to keep tokens out of the URLs written to access logs.
+func bearerToken(r *http.Request) (string, error) {
+	token, err := auth.BearerTokenFromRequest(r)
+	if !errors.Is(err, auth.ErrMissingToken) || !websocket.IsWebSocketUpgrade(r) {
+		return token, err
+	}
+
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		return token, nil
+	}
+	return "", err
+}
@@ -0,0 +1,88 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/imhasandl/notification-service/internal/hub"
+)
+
+// serveSSE streams the user's events as Server-Sent Events
+func (g *Gateway) serveSSE(w http.ResponseWriter, r *http.Request) {
+	userID, ok := g.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	rc, err := startEventStream(w)
+	if err != nil {
+		log.Printf("gateway: streaming unsupported: %v", err)
+		return
+	}
+
+	sub := g.hub.Subscribe(userID)
+	defer g.hub.Unsubscribe(sub)
+
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-sub.Events():
+			if err := g.writeEvent(rc, w, event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := g.writeSSE(rc, w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-sub.Done():
+			g.closeIfSlow(rc, w, sub)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// startEventStream sends the SSE response headers and flushes them so the client sees the stream open
+func startEventStream(w http.ResponseWriter) (*http.ResponseController, error) {
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	return rc, rc.Flush()
+}
+
+// writeEvent sends a notification as an SSE frame, events that can't be encoded are logged and skipped
+func (g *Gateway) writeEvent(rc *http.ResponseController, w http.ResponseWriter, event hub.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("gateway: can't marshal event: %v", err)
+		return nil
+	}
+	return g.writeSSE(rc, w, "id: %s\nevent: notification\ndata: %s\n\n", event.ID, data)
+}
+
+// closeIfSlow tells a client that was dropped for falling behind to reconnect later
+func (g *Gateway) closeIfSlow(rc *http.ResponseController, w http.ResponseWriter, sub *hub.Subscription) {
+	if sub.Slow() {
+		_ = g.writeSSE(rc, w, "event: error\ndata: client too slow\n\n")
+	}
+}
+
+// writeSSE writes a single frame with a deadline and flushes it to the client
+func (g *Gateway) writeSSE(rc *http.ResponseController, w http.ResponseWriter, format string, args ...any) error {
+	_ = rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := fmt.Fprintf(w, format, args...); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/imhasandl/notification-service/internal/hub"
+)
+
+// serveWebSocket upgrades the request and streams the user's events as JSON text frames
+func (g *Gateway) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := g.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("gateway: websocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	sub := g.hub.Subscribe(userID)
+	defer g.hub.Unsubscribe(sub)
+
+	closed := readControlFrames(conn)
+
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-sub.Events():
+			_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		case <-sub.Done():
+			closeIfSlow(conn, sub)
+			return
+		case <-closed:
+			return
+		}
+	}
+}
+
+// readControlFrames processes pongs and detects disconnects, clients only send control frames.
+// The returned channel is closed once the connection is gone or stops answering pings.
+func readControlFrames(conn *websocket.Conn) <-chan struct{} {
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * HeartbeatInterval))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * HeartbeatInterval))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	return closed
+}
+
+// closeIfSlow tells a client that was dropped for falling behind to reconnect later
+func closeIfSlow(conn *websocket.Conn, sub *hub.Subscription) {
+	if !sub.Slow() {
+		return
+	}
+	msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow")
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeTimeout))
+}
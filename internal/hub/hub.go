@@ -0,0 +1,156 @@
+package hub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultBufferSize is the number of events a subscriber may fall behind before it is dropped
+const DefaultBufferSize = 64
+
+// Event represents a live notification delivered to connected clients
+type Event struct {
+	ID             string    `json:"id"`
+	Title          string    `json:"title"`
+	SenderUsername string    `json:"sender_username"`
+	ReceiverID     string    `json:"receiver_id"`
+	Content        string    `json:"content"`
+	SentAt         time.Time `json:"sent_at"`
+}
+
+// Subscription is a single live connection listening for a user's events
+type Subscription struct {
+	UserID uuid.UUID
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+	slow   bool
+}
+
+// Events returns the channel the subscriber reads events from
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Done is closed once the subscription has been removed from the hub
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Slow reports whether the subscription was dropped for not keeping up
+func (s *Subscription) Slow() bool {
+	select {
+	case <-s.done:
+		return s.slow
+	default:
+		return false
+	}
+}
+
+// Hub fans out events to every live subscription of a user.
+// It is safe for concurrent use by the gRPC handlers and the HTTP gateway.
+type Hub struct {
+	mu         sync.RWMutex
+	subs       map[uuid.UUID]map[*Subscription]struct{}
+	bufferSize int
+}
+
+// New creates a hub whose subscribers buffer up to bufferSize events
+func New(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Hub{
+		subs:       make(map[uuid.UUID]map[*Subscription]struct{}),
+		bufferSize: bufferSize,
+	}
+}
+
+// Subscribe registers a new live subscription for the given user
+func (h *Hub) Subscribe(userID uuid.UUID) *Subscription {
+	sub := &Subscription{
+		UserID: userID,
+		events: make(chan Event, h.bufferSize),
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*Subscription]struct{})
+	}
+	h.subs[userID][sub] = struct{}{}
+
+	return sub
+}
+
+// Unsubscribe removes the subscription from the hub. It is safe to call more than once.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub, false)
+}
+
+// Publish delivers the event to every subscription of the user without blocking.
+// Subscribers whose buffer is full are dropped so that one slow client can't stall delivery.
+// It returns the number of subscriptions the event was delivered to.
+func (h *Hub) Publish(userID uuid.UUID, event Event) int {
+	h.mu.RLock()
+	var delivered int
+	var slow []*Subscription
+	for sub := range h.subs[userID] {
+		select {
+		case sub.events <- event:
+			delivered++
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) > 0 {
+		h.mu.Lock()
+		for _, sub := range slow {
+			h.remove(sub, true)
+		}
+		h.mu.Unlock()
+	}
+
+	return delivered
+}
+
+// Connections returns the number of live subscriptions across all users
+func (h *Hub) Connections() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var n int
+	for _, subs := range h.subs {
+		n += len(subs)
+	}
+	return n
+}
+
+// remove deletes the subscription; callers must hold the write lock
+func (h *Hub) remove(sub *Subscription, slow bool) {
+	subs, ok := h.subs[sub.UserID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subs, sub.UserID)
+	}
+
+	sub.once.Do(func() {
+		sub.slow = slow
+		close(sub.done)
+	})
+}
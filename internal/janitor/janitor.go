@@ -0,0 +1,164 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/database"
+)
+
+const (
+	// DefaultMaxAge is how long a device may go without a heartbeat, FCM considers tokens stale after ~270 days
+	DefaultMaxAge = 270 * 24 * time.Hour
+	// DefaultInterval is how often the janitor looks for stale devices
+	DefaultInterval = 24 * time.Hour
+)
+
+// Action is what the janitor does with stale devices
+type Action string
+
+const (
+	// ActionDisable keeps stale devices but stops sending to them until they send a heartbeat again
+	ActionDisable Action = "disable"
+	// ActionDelete removes stale devices
+	ActionDelete Action = "delete"
+)
+
+// Store defines the database operations the janitor needs
+type Store interface {
+	CountStaleDeviceTokens(ctx context.Context, lastSeenAt time.Time) (database.CountStaleDeviceTokensRow, error)
+	DisableStaleDeviceTokens(ctx context.Context, lastSeenAt time.Time) (int64, error)
+	DeleteStaleDeviceTokens(ctx context.Context, lastSeenAt time.Time) ([]database.DeleteStaleDeviceTokensRow, error)
+}
+
+// Config controls how the janitor treats stale devices
+type Config struct {
+	MaxAge   time.Duration
+	Interval time.Duration
+	Action   Action
+	DryRun   bool
+	// OnDelete is called with the tokens of each user whose stale devices were deleted
+	OnDelete func(ctx context.Context, userID uuid.UUID, tokens ...string)
+}
+
+// Report describes the result of a single cleanup run
+type Report struct {
+	Cutoff   time.Time
+	Action   Action
+	DryRun   bool
+	Stale    int64
+	Affected int64
+}
+
+// String formats the report for the logs
+func (r Report) String() string {
+	if r.DryRun {
+		return fmt.Sprintf("dry run: %d devices not seen since %s would be %sd", r.Stale, r.Cutoff.Format(time.RFC3339), r.Action)
+	}
+	return fmt.Sprintf("%sd %d of %d devices not seen since %s", r.Action, r.Affected, r.Stale, r.Cutoff.Format(time.RFC3339))
+}
+
+// Janitor periodically disables or removes devices that stopped sending heartbeats
+type Janitor struct {
+	store  Store
+	config Config
+	now    func() time.Time
+}
+
+// NewJanitor creates a janitor, filling unset config values with the defaults
+func NewJanitor(store Store, config Config) (*Janitor, error) {
+	if config.MaxAge <= 0 {
+		config.MaxAge = DefaultMaxAge
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Action == "" {
+		config.Action = ActionDisable
+	}
+	if config.Action != ActionDisable && config.Action != ActionDelete {
+		return nil, fmt.Errorf("unknown stale device action %q", config.Action)
+	}
+
+	return &Janitor{
+		store:  store,
+		config: config,
+		now:    time.Now,
+	}, nil
+}
+
+// Run cleans up stale devices immediately and then on every interval until the context is cancelled
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := j.RunOnce(ctx)
+		if err != nil {
+			log.Printf("janitor: stale device cleanup failed: %v", err)
+		} else {
+			log.Printf("janitor: %s", report)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single cleanup pass. In dry-run mode it only counts the devices it would touch.
+func (j *Janitor) RunOnce(ctx context.Context) (Report, error) {
+	report := Report{
+		Cutoff: j.now().Add(-j.config.MaxAge),
+		Action: j.config.Action,
+		DryRun: j.config.DryRun,
+	}
+
+	counts, err := j.store.CountStaleDeviceTokens(ctx, report.Cutoff)
+	if err != nil {
+		return report, err
+	}
+
+	// Disabled devices are already handled when disabling, but still count when deleting
+	report.Stale = counts.Active
+	if j.config.Action == ActionDelete {
+		report.Stale = counts.Total
+	}
+
+	if j.config.DryRun || report.Stale == 0 {
+		return report, nil
+	}
+
+	if j.config.Action == ActionDelete {
+		report.Affected, err = j.deleteStale(ctx, report.Cutoff)
+		return report, err
+	}
+
+	report.Affected, err = j.store.DisableStaleDeviceTokens(ctx, report.Cutoff)
+	return report, err
+}
+
+// deleteStale removes the stale devices and reports them per user to the OnDelete hook
+func (j *Janitor) deleteStale(ctx context.Context, cutoff time.Time) (int64, error) {
+	deleted, err := j.store.DeleteStaleDeviceTokens(ctx, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	if j.config.OnDelete != nil {
+		byUser := make(map[uuid.UUID][]string)
+		for _, device := range deleted {
+			byUser[device.UserID] = append(byUser[device.UserID], device.DeviceToken)
+		}
+		for userID, tokens := range byUser {
+			j.config.OnDelete(ctx, userID, tokens...)
+		}
+	}
+
+	return int64(len(deleted)), nil
+}
@@ -2,13 +2,14 @@ package mocks
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"firebase.google.com/go/v4/messaging"
 	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/broker"
 	"github.com/imhasandl/notification-service/internal/database"
 	"github.com/imhasandl/notification-service/internal/firebase"
-	"github.com/imhasandl/notification-service/internal/rabbitmq"
-	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -17,6 +18,9 @@ type MockQueries struct {
 	mock.Mock
 }
 
+// Ensure MockQueries implements the generated Querier interface
+var _ database.Querier = (*MockQueries)(nil)
+
 // NewMockQueries creates and returns a new mock database queries object
 func NewMockQueries() *MockQueries {
 	return &MockQueries{}
@@ -29,9 +33,9 @@ func (m *MockQueries) RegisterDeviceToken(ctx context.Context, arg database.Regi
 }
 
 // GetDeviceTokensByUserID mocks the database method for fetching device tokens
-func (m *MockQueries) GetDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) (string, error) {
+func (m *MockQueries) GetDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) (database.DeviceToken, error) {
 	args := m.Called(ctx, userID)
-	return args.String(0), args.Error(1)
+	return args.Get(0).(database.DeviceToken), args.Error(1)
 }
 
 // DeleteDeviceToken mocks the database method for deleting device tokens
@@ -46,6 +50,269 @@ func (m *MockQueries) SendNotification(ctx context.Context) error {
 	return args.Error(0)
 }
 
+// ListDeviceTokensByUserID mocks the database ListDeviceTokensByUserID method
+func (m *MockQueries) ListDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) ([]database.DeviceToken, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]database.DeviceToken), args.Error(1)
+}
+
+// DeleteAllDeviceTokens mocks the database DeleteAllDeviceTokens method
+func (m *MockQueries) DeleteAllDeviceTokens(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// UpdateDeviceToken mocks the database UpdateDeviceToken method
+func (m *MockQueries) UpdateDeviceToken(ctx context.Context, arg database.UpdateDeviceTokenParams) (database.DeviceToken, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.DeviceToken), args.Error(1)
+}
+
+// LockDeviceToken mocks the database LockDeviceToken method
+func (m *MockQueries) LockDeviceToken(ctx context.Context, deviceToken string) (database.DeviceToken, error) {
+	args := m.Called(ctx, deviceToken)
+	return args.Get(0).(database.DeviceToken), args.Error(1)
+}
+
+// ClaimDeviceToken mocks the database ClaimDeviceToken method
+func (m *MockQueries) ClaimDeviceToken(ctx context.Context, arg database.ClaimDeviceTokenParams) (database.DeviceToken, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.DeviceToken), args.Error(1)
+}
+
+// CreateDeviceTokenTransfer mocks the database CreateDeviceTokenTransfer method
+func (m *MockQueries) CreateDeviceTokenTransfer(ctx context.Context, arg database.CreateDeviceTokenTransferParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// TouchDeviceToken mocks the database TouchDeviceToken method
+func (m *MockQueries) TouchDeviceToken(ctx context.Context, arg database.TouchDeviceTokenParams) (database.DeviceToken, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.DeviceToken), args.Error(1)
+}
+
+// EvictDeviceTokens mocks the database EvictDeviceTokens method
+func (m *MockQueries) EvictDeviceTokens(ctx context.Context, arg database.EvictDeviceTokensParams) ([]database.DeviceToken, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.DeviceToken), args.Error(1)
+}
+
+// CountStaleDeviceTokens mocks the database CountStaleDeviceTokens method
+func (m *MockQueries) CountStaleDeviceTokens(ctx context.Context, lastSeenAt time.Time) (database.CountStaleDeviceTokensRow, error) {
+	args := m.Called(ctx, lastSeenAt)
+	return args.Get(0).(database.CountStaleDeviceTokensRow), args.Error(1)
+}
+
+// DisableStaleDeviceTokens mocks the database DisableStaleDeviceTokens method
+func (m *MockQueries) DisableStaleDeviceTokens(ctx context.Context, lastSeenAt time.Time) (int64, error) {
+	args := m.Called(ctx, lastSeenAt)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// DeleteStaleDeviceTokens mocks the database DeleteStaleDeviceTokens method
+func (m *MockQueries) DeleteStaleDeviceTokens(ctx context.Context, lastSeenAt time.Time) ([]database.DeleteStaleDeviceTokensRow, error) {
+	args := m.Called(ctx, lastSeenAt)
+	return args.Get(0).([]database.DeleteStaleDeviceTokensRow), args.Error(1)
+}
+
+// CreateCampaign mocks the database CreateCampaign method
+func (m *MockQueries) CreateCampaign(ctx context.Context, arg database.CreateCampaignParams) (database.Campaign, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// GetCampaign mocks the database GetCampaign method
+func (m *MockQueries) GetCampaign(ctx context.Context, id uuid.UUID) (database.Campaign, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// StartCampaign mocks the database StartCampaign method
+func (m *MockQueries) StartCampaign(ctx context.Context, id uuid.UUID) (database.Campaign, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// PauseCampaign mocks the database PauseCampaign method
+func (m *MockQueries) PauseCampaign(ctx context.Context, id uuid.UUID) (database.Campaign, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// CancelCampaign mocks the database CancelCampaign method
+func (m *MockQueries) CancelCampaign(ctx context.Context, id uuid.UUID) (database.Campaign, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// ClaimCampaign mocks the database ClaimCampaign method
+func (m *MockQueries) ClaimCampaign(ctx context.Context, arg database.ClaimCampaignParams) (database.Campaign, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// CheckpointCampaign mocks the database CheckpointCampaign method
+func (m *MockQueries) CheckpointCampaign(ctx context.Context, arg database.CheckpointCampaignParams) (database.Campaign, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// FinishCampaign mocks the database FinishCampaign method
+func (m *MockQueries) FinishCampaign(ctx context.Context, arg database.FinishCampaignParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// CreateExperimentAssignment mocks the database CreateExperimentAssignment method
+func (m *MockQueries) CreateExperimentAssignment(ctx context.Context, arg database.CreateExperimentAssignmentParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// RecordExperimentOpen mocks the database RecordExperimentOpen method
+func (m *MockQueries) RecordExperimentOpen(ctx context.Context, arg database.RecordExperimentOpenParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// RecordExperimentClick mocks the database RecordExperimentClick method
+func (m *MockQueries) RecordExperimentClick(ctx context.Context, arg database.RecordExperimentClickParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// GetExperimentStats mocks the database GetExperimentStats method
+func (m *MockQueries) GetExperimentStats(ctx context.Context, experiment string) ([]database.GetExperimentStatsRow, error) {
+	args := m.Called(ctx, experiment)
+	return args.Get(0).([]database.GetExperimentStatsRow), args.Error(1)
+}
+
+// CreateNotificationTracking mocks the database CreateNotificationTracking method
+func (m *MockQueries) CreateNotificationTracking(ctx context.Context, arg database.CreateNotificationTrackingParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// MarkNotificationOpened mocks the database MarkNotificationOpened method
+func (m *MockQueries) MarkNotificationOpened(ctx context.Context, arg database.MarkNotificationOpenedParams) (database.MarkNotificationOpenedRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.MarkNotificationOpenedRow), args.Error(1)
+}
+
+// MarkNotificationDismissed mocks the database MarkNotificationDismissed method
+func (m *MockQueries) MarkNotificationDismissed(ctx context.Context, arg database.MarkNotificationDismissedParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// GetEngagementByCategory mocks the database GetEngagementByCategory method
+func (m *MockQueries) GetEngagementByCategory(ctx context.Context, deliveredAt time.Time) ([]database.GetEngagementByCategoryRow, error) {
+	args := m.Called(ctx, deliveredAt)
+	return args.Get(0).([]database.GetEngagementByCategoryRow), args.Error(1)
+}
+
+// CreateOutboxMessage mocks the database CreateOutboxMessage method
+func (m *MockQueries) CreateOutboxMessage(ctx context.Context, arg database.CreateOutboxMessageParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// ListPendingOutboxMessages mocks the database ListPendingOutboxMessages method
+func (m *MockQueries) ListPendingOutboxMessages(ctx context.Context, limit int32) ([]database.Outbox, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]database.Outbox), args.Error(1)
+}
+
+// MarkOutboxMessagePublished mocks the database MarkOutboxMessagePublished method
+func (m *MockQueries) MarkOutboxMessagePublished(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// RecordOutboxFailure mocks the database RecordOutboxFailure method
+func (m *MockQueries) RecordOutboxFailure(ctx context.Context, arg database.RecordOutboxFailureParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// ParkOutboxMessage mocks the database ParkOutboxMessage method
+func (m *MockQueries) ParkOutboxMessage(ctx context.Context, arg database.ParkOutboxMessageParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// DeletePublishedOutboxMessages mocks the database DeletePublishedOutboxMessages method
+func (m *MockQueries) DeletePublishedOutboxMessages(ctx context.Context, publishedAt sql.NullTime) (int64, error) {
+	args := m.Called(ctx, publishedAt)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// CreateRoutingFallback mocks the database CreateRoutingFallback method
+func (m *MockQueries) CreateRoutingFallback(ctx context.Context, arg database.CreateRoutingFallbackParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// ListDueRoutingFallbacks mocks the database ListDueRoutingFallbacks method
+func (m *MockQueries) ListDueRoutingFallbacks(ctx context.Context, arg database.ListDueRoutingFallbacksParams) ([]database.RoutingFallback, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.RoutingFallback), args.Error(1)
+}
+
+// DeleteRoutingFallback mocks the database DeleteRoutingFallback method
+func (m *MockQueries) DeleteRoutingFallback(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// RetryRoutingFallback mocks the database RetryRoutingFallback method
+func (m *MockQueries) RetryRoutingFallback(ctx context.Context, arg database.RetryRoutingFallbackParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// IsNotificationSeen mocks the database IsNotificationSeen method
+func (m *MockQueries) IsNotificationSeen(ctx context.Context, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+// CountSegmentUsers mocks the database CountSegmentUsers method
+func (m *MockQueries) CountSegmentUsers(ctx context.Context, filter database.SegmentFilter) (int64, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// ListSegmentUsers mocks the database ListSegmentUsers method
+func (m *MockQueries) ListSegmentUsers(ctx context.Context, filter database.SegmentFilter, after uuid.UUID, limit int32) ([]uuid.UUID, error) {
+	args := m.Called(ctx, filter, after, limit)
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+// CreateTopicSubscription mocks the database CreateTopicSubscription method
+func (m *MockQueries) CreateTopicSubscription(ctx context.Context, arg database.CreateTopicSubscriptionParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// DeleteTopicSubscription mocks the database DeleteTopicSubscription method
+func (m *MockQueries) DeleteTopicSubscription(ctx context.Context, arg database.DeleteTopicSubscriptionParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// ListTopicsByUserID mocks the database ListTopicsByUserID method
+func (m *MockQueries) ListTopicsByUserID(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// ExecTx runs fn against the mock itself so queries made inside the transaction hit the same expectations
+func (m *MockQueries) ExecTx(_ context.Context, fn func(database.Querier) error) error {
+	return fn(m)
+}
+
 // MockFirebaseClient mocks the Firebase client for sending notifications
 type MockFirebaseClient struct {
 	mock.Mock
@@ -85,46 +352,52 @@ func (m *MockFCMClient) Send(ctx context.Context, message *messaging.Message) (s
 	return args.String(0), args.Error(1)
 }
 
+// SubscribeToTopic mocks the FCM SubscribeToTopic method
+func (m *MockFCMClient) SubscribeToTopic(ctx context.Context, tokens []string, topic string) (*messaging.TopicManagementResponse, error) {
+	args := m.Called(ctx, tokens, topic)
+	response, _ := args.Get(0).(*messaging.TopicManagementResponse)
+	return response, args.Error(1)
+}
+
+// UnsubscribeFromTopic mocks the FCM UnsubscribeFromTopic method
+func (m *MockFCMClient) UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) (*messaging.TopicManagementResponse, error) {
+	args := m.Called(ctx, tokens, topic)
+	response, _ := args.Get(0).(*messaging.TopicManagementResponse)
+	return response, args.Error(1)
+}
+
 // Update MockFCMClient to implement MessagingClient
 var _ firebase.MessagingClient = (*MockFCMClient)(nil)
 
-// MockRabbitMQ mocks the RabbitMQ client
-type MockRabbitMQ struct {
+// MockBroker mocks the message broker
+type MockBroker struct {
 	mock.Mock
-	Channel *MockChannel
 }
 
-// NewMockRabbitMQ creates a new mock RabbitMQ client
-func NewMockRabbitMQ() *MockRabbitMQ {
-	channel := &MockChannel{}
-	return &MockRabbitMQ{
-		Channel: channel,
-	}
-}
-
-// Close mocks the RabbitMQ Close method
-func (m *MockRabbitMQ) Close() {
-	m.Called()
+// NewMockBroker creates a new mock broker
+func NewMockBroker() *MockBroker {
+	return &MockBroker{}
 }
 
-// Ensure MockRabbitMQ implements the RabbitMQClient interface
-var _ rabbitmq.Client = (*MockRabbitMQ)(nil)
+// Ensure MockBroker implements the Broker interface
+var _ broker.Broker = (*MockBroker)(nil)
 
-// GetChannel returns the mock channel
-func (m *MockRabbitMQ) GetChannel() *amqp.Channel {
-	args := m.Called()
-	return args.Get(0).(*amqp.Channel)
+// Consume mocks the Broker Consume method
+func (m *MockBroker) Consume(ctx context.Context, handler broker.Handler) error {
+	args := m.Called(ctx, handler)
+	return args.Error(0)
 }
 
-// MockChannel mocks the RabbitMQ Channel
-type MockChannel struct {
-	mock.Mock
+// Publish mocks the Broker Publish method
+func (m *MockBroker) Publish(ctx context.Context, topic, key string, msg broker.Message) error {
+	args := m.Called(ctx, topic, key, msg)
+	return args.Error(0)
 }
 
-// Consume mocks the RabbitMQ Consume method
-func (m *MockChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args map[string]interface{}) (<-chan messaging.Message, error) {
-	mockArgs := m.Called(queue, consumer, autoAck, exclusive, noLocal, noWait, args)
-	return mockArgs.Get(0).(<-chan messaging.Message), mockArgs.Error(1)
+// Close mocks the Broker Close method
+func (m *MockBroker) Close() error {
+	args := m.Called()
+	return args.Error(0)
 }
 
 // MockDBQuerier is a mock for the DBQuerier interface
@@ -133,9 +406,9 @@ type MockDBQuerier struct {
 }
 
 // GetDeviceTokensByUserID mocks the DBQuerier interface GetDeviceTokensByUserID method
-func (m *MockDBQuerier) GetDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) (string, error) {
+func (m *MockDBQuerier) GetDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) (database.DeviceToken, error) {
 	args := m.Called(ctx, userID)
-	return args.String(0), args.Error(1)
+	return args.Get(0).(database.DeviceToken), args.Error(1)
 }
 
 // RegisterDeviceToken mocks the DBQuerier interface RegisterDeviceToken method
@@ -156,18 +429,265 @@ func (m *MockDBQuerier) SendNotification(ctx context.Context) error {
 	return args.Error(0)
 }
 
-// MockRabbitMQClient is a mock for the RabbitMQ Client interface
-type MockRabbitMQClient struct {
-	mock.Mock
+// ListDeviceTokensByUserID mocks the DBQuerier interface ListDeviceTokensByUserID method
+func (m *MockDBQuerier) ListDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) ([]database.DeviceToken, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]database.DeviceToken), args.Error(1)
 }
 
-// Close mocks the RabbitMQClient Close method
-func (m *MockRabbitMQClient) Close() {
-	m.Called()
+// DeleteAllDeviceTokens mocks the DBQuerier interface DeleteAllDeviceTokens method
+func (m *MockDBQuerier) DeleteAllDeviceTokens(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]string), args.Error(1)
 }
 
-// GetChannel mocks the RabbitMQClient GetChannel method
-func (m *MockRabbitMQClient) GetChannel() *amqp.Channel {
-	args := m.Called()
-	return args.Get(0).(*amqp.Channel)
+// UpdateDeviceToken mocks the DBQuerier interface UpdateDeviceToken method
+func (m *MockDBQuerier) UpdateDeviceToken(ctx context.Context, arg database.UpdateDeviceTokenParams) (database.DeviceToken, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.DeviceToken), args.Error(1)
+}
+
+// LockDeviceToken mocks the DBQuerier interface LockDeviceToken method
+func (m *MockDBQuerier) LockDeviceToken(ctx context.Context, deviceToken string) (database.DeviceToken, error) {
+	args := m.Called(ctx, deviceToken)
+	return args.Get(0).(database.DeviceToken), args.Error(1)
+}
+
+// ClaimDeviceToken mocks the DBQuerier interface ClaimDeviceToken method
+func (m *MockDBQuerier) ClaimDeviceToken(ctx context.Context, arg database.ClaimDeviceTokenParams) (database.DeviceToken, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.DeviceToken), args.Error(1)
+}
+
+// CreateDeviceTokenTransfer mocks the DBQuerier interface CreateDeviceTokenTransfer method
+func (m *MockDBQuerier) CreateDeviceTokenTransfer(ctx context.Context, arg database.CreateDeviceTokenTransferParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// TouchDeviceToken mocks the DBQuerier interface TouchDeviceToken method
+func (m *MockDBQuerier) TouchDeviceToken(ctx context.Context, arg database.TouchDeviceTokenParams) (database.DeviceToken, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.DeviceToken), args.Error(1)
+}
+
+// EvictDeviceTokens mocks the DBQuerier interface EvictDeviceTokens method
+func (m *MockDBQuerier) EvictDeviceTokens(ctx context.Context, arg database.EvictDeviceTokensParams) ([]database.DeviceToken, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.DeviceToken), args.Error(1)
+}
+
+// CountStaleDeviceTokens mocks the DBQuerier interface CountStaleDeviceTokens method
+func (m *MockDBQuerier) CountStaleDeviceTokens(ctx context.Context, lastSeenAt time.Time) (database.CountStaleDeviceTokensRow, error) {
+	args := m.Called(ctx, lastSeenAt)
+	return args.Get(0).(database.CountStaleDeviceTokensRow), args.Error(1)
+}
+
+// DisableStaleDeviceTokens mocks the DBQuerier interface DisableStaleDeviceTokens method
+func (m *MockDBQuerier) DisableStaleDeviceTokens(ctx context.Context, lastSeenAt time.Time) (int64, error) {
+	args := m.Called(ctx, lastSeenAt)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// DeleteStaleDeviceTokens mocks the DBQuerier interface DeleteStaleDeviceTokens method
+func (m *MockDBQuerier) DeleteStaleDeviceTokens(ctx context.Context, lastSeenAt time.Time) ([]database.DeleteStaleDeviceTokensRow, error) {
+	args := m.Called(ctx, lastSeenAt)
+	return args.Get(0).([]database.DeleteStaleDeviceTokensRow), args.Error(1)
+}
+
+// CreateExperimentAssignment mocks the DBQuerier interface CreateExperimentAssignment method
+func (m *MockDBQuerier) CreateExperimentAssignment(ctx context.Context, arg database.CreateExperimentAssignmentParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// RecordExperimentOpen mocks the DBQuerier interface RecordExperimentOpen method
+func (m *MockDBQuerier) RecordExperimentOpen(ctx context.Context, arg database.RecordExperimentOpenParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// RecordExperimentClick mocks the DBQuerier interface RecordExperimentClick method
+func (m *MockDBQuerier) RecordExperimentClick(ctx context.Context, arg database.RecordExperimentClickParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// GetExperimentStats mocks the DBQuerier interface GetExperimentStats method
+func (m *MockDBQuerier) GetExperimentStats(ctx context.Context, experiment string) ([]database.GetExperimentStatsRow, error) {
+	args := m.Called(ctx, experiment)
+	return args.Get(0).([]database.GetExperimentStatsRow), args.Error(1)
+}
+
+// CreateNotificationTracking mocks the DBQuerier interface CreateNotificationTracking method
+func (m *MockDBQuerier) CreateNotificationTracking(ctx context.Context, arg database.CreateNotificationTrackingParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// MarkNotificationOpened mocks the DBQuerier interface MarkNotificationOpened method
+func (m *MockDBQuerier) MarkNotificationOpened(ctx context.Context, arg database.MarkNotificationOpenedParams) (database.MarkNotificationOpenedRow, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.MarkNotificationOpenedRow), args.Error(1)
+}
+
+// MarkNotificationDismissed mocks the DBQuerier interface MarkNotificationDismissed method
+func (m *MockDBQuerier) MarkNotificationDismissed(ctx context.Context, arg database.MarkNotificationDismissedParams) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// GetEngagementByCategory mocks the DBQuerier interface GetEngagementByCategory method
+func (m *MockDBQuerier) GetEngagementByCategory(ctx context.Context, deliveredAt time.Time) ([]database.GetEngagementByCategoryRow, error) {
+	args := m.Called(ctx, deliveredAt)
+	return args.Get(0).([]database.GetEngagementByCategoryRow), args.Error(1)
+}
+
+// CreateOutboxMessage mocks the DBQuerier interface CreateOutboxMessage method
+func (m *MockDBQuerier) CreateOutboxMessage(ctx context.Context, arg database.CreateOutboxMessageParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// ListPendingOutboxMessages mocks the DBQuerier interface ListPendingOutboxMessages method
+func (m *MockDBQuerier) ListPendingOutboxMessages(ctx context.Context, limit int32) ([]database.Outbox, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]database.Outbox), args.Error(1)
+}
+
+// MarkOutboxMessagePublished mocks the DBQuerier interface MarkOutboxMessagePublished method
+func (m *MockDBQuerier) MarkOutboxMessagePublished(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// RecordOutboxFailure mocks the DBQuerier interface RecordOutboxFailure method
+func (m *MockDBQuerier) RecordOutboxFailure(ctx context.Context, arg database.RecordOutboxFailureParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// ParkOutboxMessage mocks the DBQuerier interface ParkOutboxMessage method
+func (m *MockDBQuerier) ParkOutboxMessage(ctx context.Context, arg database.ParkOutboxMessageParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// DeletePublishedOutboxMessages mocks the DBQuerier interface DeletePublishedOutboxMessages method
+func (m *MockDBQuerier) DeletePublishedOutboxMessages(ctx context.Context, publishedAt sql.NullTime) (int64, error) {
+	args := m.Called(ctx, publishedAt)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// CreateRoutingFallback mocks the DBQuerier interface CreateRoutingFallback method
+func (m *MockDBQuerier) CreateRoutingFallback(ctx context.Context, arg database.CreateRoutingFallbackParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// ListDueRoutingFallbacks mocks the DBQuerier interface ListDueRoutingFallbacks method
+func (m *MockDBQuerier) ListDueRoutingFallbacks(ctx context.Context, arg database.ListDueRoutingFallbacksParams) ([]database.RoutingFallback, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).([]database.RoutingFallback), args.Error(1)
+}
+
+// DeleteRoutingFallback mocks the DBQuerier interface DeleteRoutingFallback method
+func (m *MockDBQuerier) DeleteRoutingFallback(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// RetryRoutingFallback mocks the DBQuerier interface RetryRoutingFallback method
+func (m *MockDBQuerier) RetryRoutingFallback(ctx context.Context, arg database.RetryRoutingFallbackParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// IsNotificationSeen mocks the DBQuerier interface IsNotificationSeen method
+func (m *MockDBQuerier) IsNotificationSeen(ctx context.Context, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+// CreateCampaign mocks the DBQuerier interface CreateCampaign method
+func (m *MockDBQuerier) CreateCampaign(ctx context.Context, arg database.CreateCampaignParams) (database.Campaign, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// GetCampaign mocks the DBQuerier interface GetCampaign method
+func (m *MockDBQuerier) GetCampaign(ctx context.Context, id uuid.UUID) (database.Campaign, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// StartCampaign mocks the DBQuerier interface StartCampaign method
+func (m *MockDBQuerier) StartCampaign(ctx context.Context, id uuid.UUID) (database.Campaign, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// PauseCampaign mocks the DBQuerier interface PauseCampaign method
+func (m *MockDBQuerier) PauseCampaign(ctx context.Context, id uuid.UUID) (database.Campaign, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// CancelCampaign mocks the DBQuerier interface CancelCampaign method
+func (m *MockDBQuerier) CancelCampaign(ctx context.Context, id uuid.UUID) (database.Campaign, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// ClaimCampaign mocks the DBQuerier interface ClaimCampaign method
+func (m *MockDBQuerier) ClaimCampaign(ctx context.Context, arg database.ClaimCampaignParams) (database.Campaign, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// CheckpointCampaign mocks the DBQuerier interface CheckpointCampaign method
+func (m *MockDBQuerier) CheckpointCampaign(ctx context.Context, arg database.CheckpointCampaignParams) (database.Campaign, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(database.Campaign), args.Error(1)
+}
+
+// FinishCampaign mocks the DBQuerier interface FinishCampaign method
+func (m *MockDBQuerier) FinishCampaign(ctx context.Context, arg database.FinishCampaignParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// CountSegmentUsers mocks the DBQuerier interface CountSegmentUsers method
+func (m *MockDBQuerier) CountSegmentUsers(ctx context.Context, filter database.SegmentFilter) (int64, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// ListSegmentUsers mocks the DBQuerier interface ListSegmentUsers method
+func (m *MockDBQuerier) ListSegmentUsers(ctx context.Context, filter database.SegmentFilter, after uuid.UUID, limit int32) ([]uuid.UUID, error) {
+	args := m.Called(ctx, filter, after, limit)
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+// CreateTopicSubscription mocks the DBQuerier interface CreateTopicSubscription method
+func (m *MockDBQuerier) CreateTopicSubscription(ctx context.Context, arg database.CreateTopicSubscriptionParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// DeleteTopicSubscription mocks the DBQuerier interface DeleteTopicSubscription method
+func (m *MockDBQuerier) DeleteTopicSubscription(ctx context.Context, arg database.DeleteTopicSubscriptionParams) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
+
+// ListTopicsByUserID mocks the DBQuerier interface ListTopicsByUserID method
+func (m *MockDBQuerier) ListTopicsByUserID(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// ExecTx runs fn against the mock itself so queries made inside the transaction hit the same expectations
+func (m *MockDBQuerier) ExecTx(_ context.Context, fn func(database.Querier) error) error {
+	return fn(m)
 }
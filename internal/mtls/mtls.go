@@ -0,0 +1,160 @@
+// Package mtls serves gRPC over TLS, optionally verifying client certificates, and reloads the
+// certificates when they are rotated on disk.
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is how often the certificate files are checked for changes
+const DefaultReloadInterval = time.Minute
+
+// Config names the PEM files the server's TLS is configured with
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile holds the CAs client certificates must chain to. Setting it requires every
+	// client to present a certificate, mutual TLS; without it any client may connect.
+	ClientCAFile string
+}
+
+// Reloader holds the server certificate and client CAs, reloading them when the files change
+// so rotated certificates are used for new connections without a restart
+type Reloader struct {
+	config      Config
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	modified    time.Time
+}
+
+// NewReloader loads the certificate, key and client CAs
+func NewReloader(config Config) (*Reloader, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("mtls: certificate and key files are required")
+	}
+
+	r := &Reloader{config: config}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the files again if any of them changed since they were last loaded. A failed
+// reload, e.g. while a rotation has replaced the certificate but not yet the key, keeps the
+// certificates in use.
+func (r *Reloader) Reload() error {
+	modified, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	unchanged := modified.Equal(r.modified)
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("mtls: can't load certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(filepath.Clean(r.config.ClientCAFile))
+		if err != nil {
+			return fmt.Errorf("mtls: can't read client CAs: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("mtls: no certificates in the client CA file")
+		}
+	}
+
+	r.mu.Lock()
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.modified = modified
+	r.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the files every interval until the context is cancelled
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reload(); err != nil {
+				log.Printf("Failed to reload TLS certificates: %v", err)
+			}
+		}
+	}
+}
+
+// ServerConfig returns the TLS configuration of the server, every handshake uses the latest certificates
+func (r *Reloader) ServerConfig() *tls.Config {
+	return r.serverConfig(true)
+}
+
+// BrowserConfig returns the TLS configuration of endpoints browsers connect to. It serves the same
+// certificates but never asks for a client certificate, browser clients authenticate with tokens.
+func (r *Reloader) BrowserConfig() *tls.Config {
+	return r.serverConfig(false)
+}
+
+// serverConfig returns a TLS configuration using the latest certificates, requiring client
+// certificates from the client CAs if there are any and clientAuth is set
+func (r *Reloader) serverConfig(clientAuth bool) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.certificate},
+			}
+			if clientAuth && r.clientCAs != nil {
+				config.ClientCAs = r.clientCAs
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return config, nil
+		},
+	}
+}
+
+// lastModified returns the latest modification time of the files
+func (r *Reloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.config.CertFile, r.config.KeyFile, r.config.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, fmt.Errorf("mtls: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
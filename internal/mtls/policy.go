@@ -0,0 +1,109 @@
+package mtls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// AnyRPC in a client's RPCs lets it call every RPC
+const AnyRPC = "*"
+
+// Policy maps client identities to the RPCs they may call by name, e.g. "SendNotification".
+// A client's identities are the URI SANs of its certificate, such as SPIFFE ids, its DNS SANs
+// and its common name.
+type Policy map[string][]string
+
+// LoadPolicy reads a JSON file of the form {"clients": {"<identity>": ["<RPC>", ...]}}
+func LoadPolicy(path string) (Policy, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Clients Policy `json:"clients"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("can't parse client policy: %w", err)
+	}
+	return file.Clients, nil
+}
+
+// Allows reports whether any of the identities may call the RPC with the full name
+func (p Policy) Allows(identities []string, fullMethod string) bool {
+	name := path.Base(fullMethod)
+	for _, identity := range identities {
+		for _, rpc := range p[identity] {
+			if rpc == AnyRPC || rpc == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PeerIdentities returns the identities of the verified client certificate of a call, or nil
+// if the client didn't present one
+func PeerIdentities(ctx context.Context) []string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	leaf := info.State.VerifiedChains[0][0]
+	identities := make([]string, 0, len(leaf.URIs)+len(leaf.DNSNames)+1)
+	for _, uri := range leaf.URIs {
+		identities = append(identities, uri.String())
+	}
+	identities = append(identities, leaf.DNSNames...)
+	if leaf.Subject.CommonName != "" {
+		identities = append(identities, leaf.Subject.CommonName)
+	}
+	return identities
+}
+
+// authorize checks that the client certificate of a call allows the RPC
+func (p Policy) authorize(ctx context.Context, fullMethod string) error {
+	identities := PeerIdentities(ctx)
+	if len(identities) == 0 {
+		return rpcerr.New(codes.Unauthenticated, rpcerr.ReasonCertificateRequired, "client certificate required")
+	}
+	if !p.Allows(identities, fullMethod) {
+		return rpcerr.New(codes.PermissionDenied, rpcerr.ReasonClientNotAllowed, fmt.Sprintf("client %s may not call %s", identities[0], fullMethod))
+	}
+	return nil
+}
+
+// UnaryInterceptor rejects unary calls the client certificate doesn't allow
+func (p Policy) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := p.authorize(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor rejects streaming calls the client certificate doesn't allow
+func (p Policy) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := p.authorize(stream.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
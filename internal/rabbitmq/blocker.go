@@ -0,0 +1,73 @@
+package rabbitmq
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Blocker tracks whether the broker blocks a connection, which it does when it raises a memory or
+// disk alarm
+type Blocker struct {
+	// unblocked is closed while the broker accepts publishes and replaced when it blocks the connection
+	mu        sync.Mutex
+	unblocked chan struct{}
+}
+
+// NewBlocker creates a Blocker following the blocked and unblocked notifications of a connection,
+// it starts unblocked. The notifications channel closes with the connection.
+func NewBlocker(blockings <-chan amqp.Blocking) *Blocker {
+	b := &Blocker{unblocked: make(chan struct{})}
+	close(b.unblocked)
+	go b.watch(blockings)
+	return b
+}
+
+// watch applies the notifications until their channel closes
+func (b *Blocker) watch(blockings <-chan amqp.Blocking) {
+	for blocking := range blockings {
+		b.mu.Lock()
+		select {
+		case <-b.unblocked:
+			if blocking.Active {
+				log.Printf("rabbitmq: connection blocked by the broker: %s", blocking.Reason)
+				b.unblocked = make(chan struct{})
+			}
+		default:
+			if !blocking.Active {
+				log.Printf("rabbitmq: connection unblocked")
+				close(b.unblocked)
+			}
+		}
+		b.mu.Unlock()
+	}
+}
+
+// Blocked reports whether the broker currently blocks the connection
+func (b *Blocker) Blocked() bool {
+	select {
+	case <-b.waitChannel():
+		return false
+	default:
+		return true
+	}
+}
+
+// WaitUnblocked returns once the broker doesn't block the connection, or with the context's error
+func (b *Blocker) WaitUnblocked(ctx context.Context) error {
+	select {
+	case <-b.waitChannel():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitChannel returns the channel closed when the connection is unblocked
+func (b *Blocker) waitChannel() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.unblocked
+}
@@ -0,0 +1,122 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DefaultPrefetch is how many unacknowledged deliveries the broker sends the consumer ahead of time,
+// it also bounds what is left waiting when consumption pauses
+const DefaultPrefetch = 10
+
+// DeliveryHandler processes a delivery. Returning nil acknowledges it, an error requeues it.
+type DeliveryHandler func(ctx context.Context, delivery amqp.Delivery) error
+
+// ConsumerChannel is the part of an AMQP channel a consumer uses, *amqp.Channel implements it
+type ConsumerChannel interface {
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Cancel(consumer string, noWait bool) error
+	Close() error
+}
+
+// Consume consumes the service queue on its own channel until the context is cancelled. It pauses
+// while the broker blocks the connection, handling more requests would only pile up work the
+// service can't publish. It returns an error if the channel or connection closes underneath it.
+func (r *RabbitMQ) Consume(ctx context.Context, handler DeliveryHandler) error {
+	ch, err := r.Conn.Channel()
+	if err != nil {
+		return fmt.Errorf("can't open the consumer channel: %w", err)
+	}
+	return ConsumeChannel(ctx, ch, r.blocker, handler)
+}
+
+// ConsumeChannel consumes the service queue on the channel like Consume, holding deliveries while
+// the blocker reports the connection blocked. The channel is closed when it returns.
+func ConsumeChannel(ctx context.Context, ch ConsumerChannel, blocker *Blocker, handler DeliveryHandler) error {
+	defer ch.Close()
+
+	if err := ch.Qos(DefaultPrefetch, 0, false); err != nil {
+		return fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
+	tag := "notification-service-" + uuid.NewString()
+	deliveries, err := ch.Consume(
+		QueueName, // queue
+		tag,       // consumer
+		false,     // auto-ack
+		false,     // exclusive
+		false,     // no-local
+		false,     // no-wait
+		nil,       // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register a consumer: %w", err)
+	}
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return stopConsuming(ch, tag, deliveries)
+		case delivery, ok := <-deliveries:
+			if !ok {
+				return consumerStopped(closed)
+			}
+			if blocker.WaitUnblocked(ctx) != nil {
+				requeue(delivery)
+				return stopConsuming(ch, tag, deliveries)
+			}
+			settle(delivery, handler(ctx, delivery))
+		}
+	}
+}
+
+// consumerStopped explains why the deliveries stopped. The channel reports its close before it
+// closes the deliveries, so no close error means the broker cancelled the consumer, e.g. the
+// queue was deleted.
+func consumerStopped(closed <-chan *amqp.Error) error {
+	select {
+	case amqpErr := <-closed:
+		return fmt.Errorf("rabbitmq: consumer channel closed: %v", amqpErr)
+	default:
+		return errors.New("rabbitmq: consumer cancelled by the broker")
+	}
+}
+
+// stopConsuming cancels the consumer and requeues the deliveries prefetched but not handled yet
+func stopConsuming(ch ConsumerChannel, tag string, deliveries <-chan amqp.Delivery) error {
+	if err := ch.Cancel(tag, false); err != nil {
+		return err
+	}
+	for delivery := range deliveries {
+		requeue(delivery)
+	}
+	return nil
+}
+
+// requeue hands a delivery back to the broker without handling it
+func requeue(delivery amqp.Delivery) {
+	if err := delivery.Reject(true); err != nil {
+		log.Printf("Failed to requeue message: %v", err)
+	}
+}
+
+// settle acknowledges a handled delivery or requeues a failed one
+func settle(delivery amqp.Delivery, handlerErr error) {
+	if handlerErr != nil {
+		if err := delivery.Reject(true); err != nil {
+			log.Printf("Failed to reject message: %v", err)
+		}
+		return
+	}
+	if err := delivery.Ack(false); err != nil {
+		log.Printf("Failed to ack message: %v", err)
+	}
+}
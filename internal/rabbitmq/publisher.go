@@ -0,0 +1,203 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrUnroutable is returned when the broker returns a mandatory message no queue is bound for
+var ErrUnroutable = errors.New("rabbitmq: message was returned as unroutable")
+
+// ErrNoMessageID is returned when a message is published without a message id, which is what
+// matches a returned message to its publish
+var ErrNoMessageID = errors.New("rabbitmq: message has no message id")
+
+// errPublisherClosed is returned when the publishing channel closed during a publish
+var errPublisherClosed = errors.New("rabbitmq: publishing channel closed")
+
+// Confirmation is the broker's pending answer to a published message
+type Confirmation interface {
+	// WaitContext returns whether the broker acked the message once it answered, or the context's error
+	WaitContext(ctx context.Context) (bool, error)
+}
+
+// PublishChannel is the part of an AMQP channel in confirm mode a publisher uses
+type PublishChannel interface {
+	PublishWithConfirm(ctx context.Context, exchange, routingKey string, mandatory bool, msg amqp.Publishing) (Confirmation, error)
+	NotifyReturn(receiver chan amqp.Return) chan amqp.Return
+	Close() error
+}
+
+// amqpChannel adapts an *amqp.Channel to PublishChannel
+type amqpChannel struct {
+	*amqp.Channel
+}
+
+// PublishWithConfirm publishes the message without the immediate flag
+func (c amqpChannel) PublishWithConfirm(ctx context.Context, exchange, routingKey string, mandatory bool, msg amqp.Publishing) (Confirmation, error) {
+	confirmation, err := c.PublishWithDeferredConfirmWithContext(
+		ctx,
+		exchange,   // exchange
+		routingKey, // routing key
+		mandatory,  // mandatory
+		false,      // immediate
+		msg,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return confirmation, nil
+}
+
+// Publisher publishes on its own channel in confirm mode, so each publish knows whether the
+// broker took responsibility for its message
+type Publisher struct {
+	channel   PublishChannel
+	mandatory bool
+
+	// returns are handed over by the connection's reader, which blocks until they are received,
+	// so they are drained for as long as the channel is open
+	returns chan amqp.Return
+	// flush asks the drain to finish the return it is handling, closed is closed when it stops
+	flush  chan chan struct{}
+	closed chan struct{}
+
+	// pending holds the returns of the messages being published, by message id
+	mu      sync.Mutex
+	pending map[string]*amqp.Return
+}
+
+// PublisherOption configures a Publisher
+type PublisherOption func(*Publisher)
+
+// WithMandatory publishes with the mandatory flag, a message no queue is bound for is returned
+// by the broker and Publish fails with ErrUnroutable instead of the message being dropped
+func WithMandatory() PublisherOption {
+	return func(p *Publisher) {
+		p.mandatory = true
+	}
+}
+
+// NewPublisher opens a channel on the connection and puts it in confirm mode
+func NewPublisher(conn *amqp.Connection, opts ...PublisherOption) (*Publisher, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := channel.Confirm(false); err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+	return NewChannelPublisher(amqpChannel{channel}, opts...), nil
+}
+
+// NewChannelPublisher creates a publisher on a channel already in confirm mode and starts draining
+// the messages the broker returns on it
+func NewChannelPublisher(channel PublishChannel, opts ...PublisherOption) *Publisher {
+	p := &Publisher{
+		channel: channel,
+		returns: channel.NotifyReturn(make(chan amqp.Return)),
+		flush:   make(chan chan struct{}),
+		closed:  make(chan struct{}),
+		pending: make(map[string]*amqp.Return),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	go p.drainReturns()
+	return p
+}
+
+// Publish sends a message to the exchange with the routing key and waits until the broker
+// confirms it or the context is done. Messages are persistent unless msg sets another delivery mode.
+// Every message needs a message id, and concurrent publishes need different ones.
+func (p *Publisher) Publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	if msg.MessageId == "" {
+		return ErrNoMessageID
+	}
+	if msg.DeliveryMode == 0 {
+		msg.DeliveryMode = amqp.Persistent
+	}
+
+	p.mu.Lock()
+	if _, ok := p.pending[msg.MessageId]; ok {
+		p.mu.Unlock()
+		return fmt.Errorf("rabbitmq: message %s is already being published", msg.MessageId)
+	}
+	p.pending[msg.MessageId] = nil
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.pending, msg.MessageId)
+		p.mu.Unlock()
+	}()
+
+	confirmation, err := p.channel.PublishWithConfirm(ctx, exchange, routingKey, p.mandatory, msg)
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return ErrNotConfirmed
+	}
+	return p.returned(msg.MessageId)
+}
+
+// returned reports whether the broker returned the message. The broker sends the return before
+// the confirmation, so the drain has received it by now and only has to finish handling it.
+func (p *Publisher) returned(messageID string) error {
+	flushed := make(chan struct{})
+	select {
+	case p.flush <- flushed:
+		<-flushed
+	case <-p.closed:
+		return errPublisherClosed
+	}
+
+	p.mu.Lock()
+	returned := p.pending[messageID]
+	p.mu.Unlock()
+	if returned != nil {
+		return fmt.Errorf("%w: %d %s", ErrUnroutable, returned.ReplyCode, returned.ReplyText)
+	}
+	return nil
+}
+
+// drainReturns hands each return to the publish of its message until the channel closes. Returns
+// of messages nobody waits for anymore, e.g. publishes whose context was done, are logged and dropped.
+func (p *Publisher) drainReturns() {
+	defer close(p.closed)
+	for {
+		select {
+		case returned, ok := <-p.returns:
+			if !ok {
+				return
+			}
+			p.mu.Lock()
+			_, waiting := p.pending[returned.MessageId]
+			if waiting {
+				p.pending[returned.MessageId] = &returned
+			}
+			p.mu.Unlock()
+			if !waiting {
+				log.Printf("rabbitmq: dropping return of message %q: %d %s", returned.MessageId, returned.ReplyCode, returned.ReplyText)
+			}
+		case flushed := <-p.flush:
+			close(flushed)
+		}
+	}
+}
+
+// Close closes the publishing channel
+func (p *Publisher) Close() error {
+	return p.channel.Close()
+}
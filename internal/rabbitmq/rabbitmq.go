@@ -1,9 +1,11 @@
 package rabbitmq
 
 import (
+	"context"
+	"errors"
 	"log"
 
-	"github.com/streadway/amqp"
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 const (
@@ -11,18 +13,34 @@ const (
 	ExchangeName = "notifications.topic"
 	// QueueName is the name of the queue for processing notifications
 	QueueName = "notification_service_queue"
+	// EventsExchangeName is the topic exchange the service publishes delivery status events to
+	EventsExchangeName = "notifications.events"
+	// DeadLetterExchangeName is the topic exchange requests that can never be handled are moved to
+	DeadLetterExchangeName = "notifications.dead-letter"
+	// DeadLetterQueueName is the queue keeping dead-lettered requests for inspection
+	DeadLetterQueueName = "notification_service_queue.dead-letter"
 )
 
+// ErrNotConfirmed is returned when the broker rejects a published message
+var ErrNotConfirmed = errors.New("rabbitmq: message was not confirmed by the broker")
+
 // RabbitMQ represents a RabbitMQ client connection
 type RabbitMQ struct {
 	Conn    *amqp.Connection
 	Channel *amqp.Channel
+
+	// publisher sends the service's own messages, e.g. delivery status events
+	publisher *Publisher
+
+	// blocker tracks whether the broker blocks the connection
+	blocker *Blocker
 }
 
 // Client defines the interface for RabbitMQ operations
 type Client interface {
 	Close()
 	GetChannel() *amqp.Channel
+	Publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error
 }
 
 // Ensure RabbitMQ implements the interface
@@ -42,6 +60,27 @@ func NewRabbitMQ(url string) (*RabbitMQ, error) {
 		return nil, err
 	}
 
+	if err := declareTopology(ch); err != nil {
+		return nil, err
+	}
+
+	// Not mandatory: events nobody subscribed to yet are simply dropped
+	publisher, err := NewPublisher(conn)
+	if err != nil {
+		log.Printf("can't open the publishing channel: %v", err)
+		return nil, err
+	}
+
+	return &RabbitMQ{
+		Conn:      conn,
+		Channel:   ch,
+		publisher: publisher,
+		blocker:   NewBlocker(conn.NotifyBlocked(make(chan amqp.Blocking, 1))),
+	}, nil
+}
+
+// declareTopology declares the exchanges and the service queue
+func declareTopology(ch *amqp.Channel) error {
 	// Declare the topic exchange
 	if err := ch.ExchangeDeclare(
 		ExchangeName, // name
@@ -53,7 +92,21 @@ func NewRabbitMQ(url string) (*RabbitMQ, error) {
 		nil,          // arguments
 	); err != nil {
 		log.Printf("failed to declare exchange: %v", err)
-		return nil, err
+		return err
+	}
+
+	// Declare the exchange for the events the service publishes, consumers bind their own queues
+	if err := ch.ExchangeDeclare(
+		EventsExchangeName, // name
+		"topic",            // type
+		true,               // durable
+		false,              // auto-deleted
+		false,              // internal
+		false,              // no-wait
+		nil,                // arguments
+	); err != nil {
+		log.Printf("failed to declare events exchange: %v", err)
+		return err
 	}
 
 	// Declare the queue for the notification service
@@ -67,7 +120,7 @@ func NewRabbitMQ(url string) (*RabbitMQ, error) {
 	)
 	if err != nil {
 		log.Printf("failed to declare queue: %v", err)
-		return nil, err
+		return err
 	}
 
 	// Bind the queue to the exchange with a wildcard routing key
@@ -79,17 +132,75 @@ func NewRabbitMQ(url string) (*RabbitMQ, error) {
 		nil,          // arguments
 	); err != nil {
 		log.Printf("failed to bind queue: %v", err)
-		return nil, err
+		return err
+	}
+	return declareDeadLetters(ch)
+}
+
+// declareDeadLetters declares the dead-letter exchange with a queue keeping everything sent to it
+func declareDeadLetters(ch *amqp.Channel) error {
+	if err := ch.ExchangeDeclare(
+		DeadLetterExchangeName, // name
+		"topic",                // type
+		true,                   // durable
+		false,                  // auto-deleted
+		false,                  // internal
+		false,                  // no-wait
+		nil,                    // arguments
+	); err != nil {
+		log.Printf("failed to declare dead-letter exchange: %v", err)
+		return err
 	}
 
-	return &RabbitMQ{
-		Conn:    conn,
-		Channel: ch,
-	}, nil
+	if _, err := ch.QueueDeclare(
+		DeadLetterQueueName, // name
+		true,                // durable
+		false,               // delete when unused
+		false,               // exclusive
+		false,               // no-wait
+		nil,                 // arguments
+	); err != nil {
+		log.Printf("failed to declare dead-letter queue: %v", err)
+		return err
+	}
+
+	if err := ch.QueueBind(
+		DeadLetterQueueName,    // queue name
+		"#",                    // routing key - match all messages
+		DeadLetterExchangeName, // exchange
+		false,                  // no-wait
+		nil,                    // arguments
+	); err != nil {
+		log.Printf("failed to bind dead-letter queue: %v", err)
+		return err
+	}
+	return nil
+}
+
+// NotifyBlocked registers a listener for the connection blocked and unblocked notifications the
+// broker sends when it raises and clears resource alarms. The listener must keep receiving, the
+// connection waits for it. The channel is closed when the connection closes.
+func (r *RabbitMQ) NotifyBlocked(receiver chan amqp.Blocking) chan amqp.Blocking {
+	return r.Conn.NotifyBlocked(receiver)
 }
 
-// Close closes the RabbitMQ connection and channel
+// Blocked reports whether the broker currently blocks the connection
+func (r *RabbitMQ) Blocked() bool {
+	return r.blocker.Blocked()
+}
+
+// WaitUnblocked returns once the broker doesn't block the connection, or with the context's error
+func (r *RabbitMQ) WaitUnblocked(ctx context.Context) error {
+	return r.blocker.WaitUnblocked(ctx)
+}
+
+// Close closes the RabbitMQ connection and channels
 func (r *RabbitMQ) Close() {
+	if r.publisher != nil {
+		if err := r.publisher.Close(); err != nil {
+			log.Printf("error closing publishing channel: %v", err)
+		}
+	}
 	if r.Channel != nil {
 		if err := r.Channel.Close(); err != nil {
 			log.Printf("error closing channel: %v", err)
@@ -106,3 +217,8 @@ func (r *RabbitMQ) Close() {
 func (r *RabbitMQ) GetChannel() *amqp.Channel {
 	return r.Channel
 }
+
+// Publish sends a message to the exchange with the routing key and waits until the broker confirms it
+func (r *RabbitMQ) Publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	return r.publisher.Publish(ctx, exchange, routingKey, msg)
+}
@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrorBody is the body of every error response, the message and code of the gRPC status and its details
+type ErrorBody struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+	// Reason is the stable machine-readable cause of the error, see the rpcerr package
+	Reason          string                  `json:"reason,omitempty"`
+	Metadata        map[string]string       `json:"metadata,omitempty"`
+	FieldViolations []rpcerr.FieldViolation `json:"field_violations,omitempty"`
+	// RetryAfter is the delay before a retry may succeed, as a duration such as "1s"
+	RetryAfter string `json:"retry_after,omitempty"`
+}
+
+// httpStatus maps gRPC codes to the HTTP status of the response
+var httpStatus = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+}
+
+// HTTPStatus returns the HTTP status of a gRPC code
+func HTTPStatus(code codes.Code) int {
+	if s, ok := httpStatus[code]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// errorBody converts an error, a gRPC status or any other error, to the error body
+func errorBody(err error) (int, ErrorBody) {
+	e := rpcerr.Convert(err)
+	body := ErrorBody{
+		Error:           e.Message,
+		Code:            e.Code.String(),
+		Reason:          string(e.Reason),
+		Metadata:        e.Metadata,
+		FieldViolations: e.Violations,
+	}
+	if e.RetryDelay > 0 {
+		body.RetryAfter = e.RetryDelay.String()
+	}
+	return HTTPStatus(e.Code), body
+}
+
+// writeError writes the error body with the HTTP status of the error's gRPC code, and a
+// Retry-After header when the error may be retried
+func writeError(w http.ResponseWriter, err error) {
+	code, body := errorBody(err)
+	w.Header().Set("Content-Type", "application/json")
+	if delay := rpcerr.Convert(err).RetryDelay; delay > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+	}
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("rest: can't write error response: %v", err)
+	}
+}
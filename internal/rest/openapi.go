@@ -0,0 +1,204 @@
+package rest
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// object is a node of the OpenAPI document
+type object = map[string]interface{}
+
+var pathParam = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// wellKnown are the JSON forms of the well-known types used by the API
+var wellKnown = map[protoreflect.FullName]object{
+	"google.protobuf.Timestamp": {"type": "string", "format": "date-time"},
+	"google.protobuf.Duration":  {"type": "string"},
+	"google.protobuf.Empty":     {"type": "object"},
+}
+
+// OpenAPI describes the HTTP routes as an OpenAPI 3 document, built from the proto descriptors
+func (g *Gateway) OpenAPI() map[string]interface{} {
+	schemas := object{
+		"Error": object{
+			"type":     "object",
+			"required": []string{"error", "code"},
+			"properties": object{
+				"error":    object{"type": "string"},
+				"code":     object{"type": "string", "description": "gRPC status code name"},
+				"reason":   object{"type": "string", "description": "stable machine-readable cause"},
+				"metadata": object{"type": "object", "additionalProperties": object{"type": "string"}},
+				"field_violations": object{"type": "array", "items": object{
+					"type":       "object",
+					"properties": object{"field": object{"type": "string"}, "description": object{"type": "string"}},
+				}},
+				"retry_after": object{"type": "string", "description": "delay before a retry may succeed, e.g. 1s"},
+			},
+		},
+	}
+	paths := object{}
+	for _, route := range Routes {
+		method := g.service.Methods().ByName(protoreflect.Name(route.RPC))
+		addSchema(schemas, method.Input())
+		addSchema(schemas, method.Output())
+
+		item, ok := paths[route.Path].(object)
+		if !ok {
+			item = object{}
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation(route, method)
+	}
+
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":   string(g.service.FullName()),
+			"version": "v1",
+		},
+		"paths":      paths,
+		"components": object{"schemas": schemas, "securitySchemes": object{"bearer": object{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"}}},
+		"security":   []object{{"bearer": []string{}}},
+	}
+}
+
+// serveOpenAPI writes the OpenAPI document
+func (g *Gateway) serveOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(g.OpenAPI()); err != nil {
+		log.Printf("rest: can't write OpenAPI document: %v", err)
+	}
+}
+
+// operation describes one route, its parameters, body and responses
+func operation(route Route, method protoreflect.MethodDescriptor) object {
+	input := method.Input()
+	inPath := map[string]bool{}
+	var parameters []object
+	for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+		inPath[match[1]] = true
+		parameters = append(parameters, object{
+			"name": match[1], "in": "path", "required": true,
+			"schema": fieldSchema(input.Fields().ByName(protoreflect.Name(match[1]))),
+		})
+	}
+
+	content := "application/json"
+	if method.IsStreamingServer() {
+		content = "application/x-ndjson"
+	}
+	op := object{
+		"operationId": route.RPC,
+		"responses": object{
+			"200": object{
+				"description": "OK",
+				"content":     object{content: object{"schema": ref(method.Output())}},
+			},
+			"default": object{
+				"description": "Error",
+				"content":     object{"application/json": object{"schema": object{"$ref": "#/components/schemas/Error"}}},
+			},
+		},
+	}
+
+	if route.Body {
+		op["requestBody"] = object{
+			"required": true,
+			"content":  object{"application/json": object{"schema": ref(input)}},
+		}
+	} else {
+		for i := 0; i < input.Fields().Len(); i++ {
+			field := input.Fields().Get(i)
+			if inPath[string(field.Name())] || field.Kind() == protoreflect.MessageKind && wellKnown[field.Message().FullName()] == nil {
+				continue
+			}
+			parameters = append(parameters, object{"name": string(field.Name()), "in": "query", "schema": fieldSchema(field)})
+		}
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+	return op
+}
+
+// addSchema adds the schema of a message and of every message it references
+func addSchema(schemas object, message protoreflect.MessageDescriptor) {
+	name := schemaName(message)
+	if _, ok := schemas[name]; ok || wellKnown[message.FullName()] != nil {
+		return
+	}
+
+	properties := object{}
+	schemas[name] = object{"type": "object", "properties": properties}
+	for i := 0; i < message.Fields().Len(); i++ {
+		field := message.Fields().Get(i)
+		properties[string(field.Name())] = fieldSchema(field)
+		if field.IsMap() {
+			field = field.MapValue()
+		}
+		if field.Kind() == protoreflect.MessageKind {
+			addSchema(schemas, field.Message())
+		}
+	}
+}
+
+// fieldSchema is the schema of a field as protojson writes it
+func fieldSchema(field protoreflect.FieldDescriptor) object {
+	switch {
+	case field.IsMap():
+		return object{"type": "object", "additionalProperties": singularSchema(field.MapValue())}
+	case field.IsList():
+		return object{"type": "array", "items": singularSchema(field)}
+	}
+	return singularSchema(field)
+}
+
+func singularSchema(field protoreflect.FieldDescriptor) object {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return object{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return object{"type": "integer", "format": "int32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// protojson writes 64-bit integers as strings to keep their precision in JavaScript
+		return object{"type": "string", "format": "int64"}
+	case protoreflect.FloatKind:
+		return object{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return object{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return object{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		return enumSchema(field.Enum())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return ref(field.Message())
+	}
+	return object{"type": "string"}
+}
+
+func enumSchema(enum protoreflect.EnumDescriptor) object {
+	values := make([]string, 0, enum.Values().Len())
+	for i := 0; i < enum.Values().Len(); i++ {
+		values = append(values, string(enum.Values().Get(i).Name()))
+	}
+	return object{"type": "string", "enum": values}
+}
+
+// ref points to the schema of a message, well-known types are inlined
+func ref(message protoreflect.MessageDescriptor) object {
+	if schema, ok := wellKnown[message.FullName()]; ok {
+		return schema
+	}
+	return object{"$ref": "#/components/schemas/" + schemaName(message)}
+}
+
+func schemaName(message protoreflect.MessageDescriptor) string {
+	return strings.TrimPrefix(string(message.FullName()), string(message.ParentFile().Package())+".")
+}
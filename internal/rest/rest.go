@@ -0,0 +1,304 @@
+// Package rest serves the NotificationService over HTTP/JSON for clients that can't speak gRPC.
+// Requests are decoded into the RPC's request message and passed through the same interceptors
+// as gRPC calls, so authentication and authorization are identical on both ports.
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	pb "github.com/imhasandl/notification-service/protos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// maxBodySize bounds request bodies, notifications and segments are small JSON documents
+const maxBodySize = 1 << 20
+
+var (
+	unmarshalOptions = protojson.UnmarshalOptions{}
+	marshalOptions   = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+)
+
+// Option configures a Gateway
+type Option func(*Gateway)
+
+// WithUnaryInterceptors runs the interceptors around every unary RPC, in order
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) Option {
+	return func(g *Gateway) {
+		g.unary = append(g.unary, interceptors...)
+	}
+}
+
+// WithStreamInterceptors runs the interceptors around every streaming RPC, in order
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) Option {
+	return func(g *Gateway) {
+		g.stream = append(g.stream, interceptors...)
+	}
+}
+
+// Gateway translates HTTP/JSON requests into NotificationService calls
+type Gateway struct {
+	server  pb.NotificationServiceServer
+	service protoreflect.ServiceDescriptor
+	unary   []grpc.UnaryServerInterceptor
+	stream  []grpc.StreamServerInterceptor
+}
+
+// NewGateway creates a gateway calling the server
+func NewGateway(server pb.NotificationServiceServer, opts ...Option) *Gateway {
+	g := &Gateway{server: server}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(pb.NotificationService_ServiceDesc.ServiceName))
+	if err != nil {
+		panic(fmt.Sprintf("rest: %v", err))
+	}
+	g.service = descriptor.(protoreflect.ServiceDescriptor)
+	return g
+}
+
+// Handler returns the HTTP routes of every RPC and the OpenAPI document at /openapi.json
+func (g *Gateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, route := range Routes {
+		mux.Handle(route.Method+" "+route.Path, g.handle(route))
+	}
+	mux.HandleFunc("GET /openapi.json", g.serveOpenAPI)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, rpcerr.NotFound(rpcerr.ReasonNotFound, fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path)))
+	})
+	return mux
+}
+
+// NewHTTPServer wraps the gateway handler in an http.Server with sane timeouts
+func NewHTTPServer(addr string, g *Gateway) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           g.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+}
+
+// handle serves one route, streaming RPCs write one JSON object per line
+func (g *Gateway) handle(route Route) http.HandlerFunc {
+	method := g.service.Methods().ByName(protoreflect.Name(route.RPC))
+	if method == nil {
+		panic("rest: unknown RPC " + route.RPC)
+	}
+	fullMethod := "/" + pb.NotificationService_ServiceDesc.ServiceName + "/" + route.RPC
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := decodeRequest(r, route, method.Input())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		ctx := incomingContext(r)
+		if method.IsStreamingServer() {
+			g.serveStream(ctx, w, fullMethod, route.RPC, req)
+			return
+		}
+		g.serveUnary(ctx, w, fullMethod, route.RPC, req)
+	}
+}
+
+func (g *Gateway) serveUnary(ctx context.Context, w http.ResponseWriter, fullMethod, rpc string, req proto.Message) {
+	for _, desc := range pb.NotificationService_ServiceDesc.Methods {
+		if desc.MethodName != rpc {
+			continue
+		}
+		resp, err := desc.Handler(g.server, ctx, mergeInto(req), chainUnary(g.unary, fullMethod))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeMessage(w, resp.(proto.Message))
+		return
+	}
+	writeError(w, rpcerr.New(codes.Unimplemented, rpcerr.ReasonInternal, rpc+" is not served over HTTP"))
+}
+
+func (g *Gateway) serveStream(ctx context.Context, w http.ResponseWriter, fullMethod, rpc string, req proto.Message) {
+	for _, desc := range pb.NotificationService_ServiceDesc.Streams {
+		if desc.StreamName != rpc {
+			continue
+		}
+		stream := &responseStream{ctx: ctx, w: w, req: req}
+		info := &grpc.StreamServerInfo{FullMethod: fullMethod, IsServerStream: true}
+		if err := chainStream(g.stream)(g.server, stream, info, desc.Handler); err != nil {
+			stream.fail(err)
+		}
+		return
+	}
+	writeError(w, rpcerr.New(codes.Unimplemented, rpcerr.ReasonInternal, rpc+" is not served over HTTP"))
+}
+
+// decodeRequest builds the request message from the body or query string and the path parameters
+func decodeRequest(r *http.Request, route Route, input protoreflect.MessageDescriptor) (proto.Message, error) {
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(input.FullName())
+	if err != nil {
+		return nil, rpcerr.FromError(err, "can't create request")
+	}
+	req := mt.New().Interface()
+
+	if route.Body {
+		err = readBody(r, req)
+	} else {
+		err = readQuery(r, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < input.Fields().Len(); i++ {
+		name := string(input.Fields().Get(i).Name())
+		if value := r.PathValue(name); value != "" {
+			if err := setField(req, name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return req, nil
+}
+
+// readBody reads the request from the JSON body, an empty body leaves every field unset
+func readBody(r *http.Request, req proto.Message) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		return rpcerr.FromError(err, "can't read body")
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	if err := unmarshalOptions.Unmarshal(body, req); err != nil {
+		return rpcerr.InvalidArgument(rpcerr.ReasonMalformedJSON, "can't parse body",
+			rpcerr.FieldViolation{Field: "body", Description: err.Error()}).WithCause(err)
+	}
+	return nil
+}
+
+// readQuery sets the fields of the request named in the query string
+func readQuery(r *http.Request, req proto.Message) error {
+	for name, values := range r.URL.Query() {
+		if err := setField(req, name, values[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setField sets a field of the request from its text, read the way JSON would read it as a string
+func setField(req proto.Message, name, value string) error {
+	field := req.ProtoReflect().Descriptor().Fields().ByName(protoreflect.Name(name))
+	if field == nil {
+		return rpcerr.InvalidField(name, "unknown parameter")
+	}
+
+	data, err := json.Marshal(map[string]string{name: value})
+	if err != nil {
+		return rpcerr.FromError(err, "can't encode parameter")
+	}
+	parsed := req.ProtoReflect().New().Interface()
+	if err := unmarshalOptions.Unmarshal(data, parsed); err != nil {
+		return rpcerr.InvalidField(name, "invalid value").WithCause(err)
+	}
+	req.ProtoReflect().Set(field, parsed.ProtoReflect().Get(field))
+	return nil
+}
+
+// incomingContext carries the request's bearer token and client certificate the way a gRPC call would
+func incomingContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+	}
+
+	p := &peer.Peer{Addr: remoteAddr(r.RemoteAddr)}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+	}
+	return peer.NewContext(ctx, p)
+}
+
+// remoteAddr parses the client address of a request, falling back to an address without a port
+func remoteAddr(addr string) net.Addr {
+	if tcp, err := net.ResolveTCPAddr("tcp", addr); err == nil {
+		return tcp
+	}
+	return &net.TCPAddr{IP: net.ParseIP(addr)}
+}
+
+// mergeInto returns the decode function of a generated handler, filling its request from req
+func mergeInto(req proto.Message) func(interface{}) error {
+	return func(v interface{}) error {
+		proto.Merge(v.(proto.Message), req)
+		return nil
+	}
+}
+
+// writeMessage writes a response message as JSON
+func writeMessage(w http.ResponseWriter, m proto.Message) {
+	data, err := marshalOptions.Marshal(m)
+	if err != nil {
+		writeError(w, rpcerr.FromError(err, "can't encode response"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(data); err != nil {
+		log.Printf("rest: can't write response: %v", err)
+	}
+}
+
+// chainUnary combines the interceptors of a call into one, nil when there are none
+func chainUnary(interceptors []grpc.UnaryServerInterceptor, fullMethod string) grpc.UnaryServerInterceptor {
+	if len(interceptors) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		info := &grpc.UnaryServerInfo{FullMethod: fullMethod}
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// chainStream combines stream interceptors into one
+func chainStream(interceptors []grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(srv interface{}, stream grpc.ServerStream) error {
+				return interceptor(srv, stream, info, inner)
+			}
+		}
+		return next(srv, stream)
+	}
+}
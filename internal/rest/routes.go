@@ -0,0 +1,43 @@
+package rest
+
+import "net/http"
+
+// Route maps an HTTP method and path to a NotificationService RPC. Path parameters such as {user_id}
+// set the request field of the same name and take precedence over the body.
+type Route struct {
+	Method string
+	Path   string
+	RPC    string
+	// Body reads the request from the JSON body, otherwise its fields are read from the query string
+	Body bool
+}
+
+// Routes are the HTTP routes of every NotificationService RPC
+var Routes = []Route{
+	{Method: http.MethodPost, Path: "/v1/notifications", RPC: "SendNotification", Body: true},
+
+	{Method: http.MethodPost, Path: "/v1/users/{user_id}/devices", RPC: "RegisterDeviceToken", Body: true},
+	{Method: http.MethodGet, Path: "/v1/users/{user_id}/devices", RPC: "ListDeviceTokens"},
+	{Method: http.MethodDelete, Path: "/v1/users/{user_id}/devices", RPC: "DeleteAllDeviceTokens"},
+	{Method: http.MethodDelete, Path: "/v1/users/{user_id}/devices/{device_token}", RPC: "DeleteDeviceToken"},
+	{Method: http.MethodPatch, Path: "/v1/users/{user_id}/devices/{id}", RPC: "UpdateDeviceToken", Body: true},
+	{Method: http.MethodPost, Path: "/v1/users/{user_id}/devices/{device_token}/touch", RPC: "TouchDeviceToken"},
+
+	{Method: http.MethodPut, Path: "/v1/users/{user_id}/topics/{topic}", RPC: "SubscribeToTopic"},
+	{Method: http.MethodDelete, Path: "/v1/users/{user_id}/topics/{topic}", RPC: "UnsubscribeFromTopic"},
+	{Method: http.MethodPost, Path: "/v1/topics/{topic}/notifications", RPC: "SendToTopic", Body: true},
+	{Method: http.MethodPost, Path: "/v1/segments/notifications", RPC: "SendToSegment", Body: true},
+
+	{Method: http.MethodPost, Path: "/v1/campaigns", RPC: "CreateCampaign", Body: true},
+	{Method: http.MethodGet, Path: "/v1/campaigns/{id}", RPC: "GetCampaignStats"},
+	{Method: http.MethodPost, Path: "/v1/campaigns/{id}/start", RPC: "StartCampaign"},
+	{Method: http.MethodPost, Path: "/v1/campaigns/{id}/pause", RPC: "PauseCampaign"},
+	{Method: http.MethodPost, Path: "/v1/campaigns/{id}/cancel", RPC: "CancelCampaign"},
+
+	{Method: http.MethodPost, Path: "/v1/users/{user_id}/experiment-events", RPC: "ReportExperimentEvent", Body: true},
+	{Method: http.MethodGet, Path: "/v1/experiments/{experiment}/stats", RPC: "GetExperimentStats"},
+
+	{Method: http.MethodPost, Path: "/v1/users/{user_id}/notifications/{tracking_id}/opened", RPC: "ReportNotificationOpened"},
+	{Method: http.MethodPost, Path: "/v1/users/{user_id}/notifications/{tracking_id}/dismissed", RPC: "ReportNotificationDismissed"},
+	{Method: http.MethodGet, Path: "/v1/engagement", RPC: "GetEngagementStats"},
+}
@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// responseStream is the server stream of a streaming RPC served over HTTP. The request was read
+// from the HTTP request, every response is written as a line of newline-delimited JSON.
+type responseStream struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	req     proto.Message
+	started bool
+}
+
+func (s *responseStream) SetHeader(metadata.MD) error  { return nil }
+func (s *responseStream) SendHeader(metadata.MD) error { return nil }
+func (s *responseStream) SetTrailer(metadata.MD)       {}
+
+// Context returns the context of the HTTP request
+func (s *responseStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendMsg writes a response line and flushes it to the client
+func (s *responseStream) SendMsg(m interface{}) error {
+	data, err := marshalOptions.Marshal(m.(proto.Message))
+	if err != nil {
+		return err
+	}
+	if !s.started {
+		s.w.Header().Set("Content-Type", "application/x-ndjson")
+		s.started = true
+	}
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// RecvMsg fills the message with the request, server-streaming RPCs receive once
+func (s *responseStream) RecvMsg(m interface{}) error {
+	proto.Merge(m.(proto.Message), s.req)
+	return nil
+}
+
+// fail reports an error, as the response when nothing was sent yet and as a last line otherwise
+func (s *responseStream) fail(err error) {
+	if !s.started {
+		writeError(s.w, err)
+		return
+	}
+
+	_, body := errorBody(err)
+	if err := json.NewEncoder(s.w).Encode(body); err != nil {
+		log.Printf("rest: can't write error line: %v", err)
+	}
+}
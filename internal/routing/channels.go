@@ -0,0 +1,124 @@
+package routing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/firebase"
+	"github.com/imhasandl/notification-service/internal/hub"
+)
+
+const (
+	// ChannelPush delivers through Firebase Cloud Messaging
+	ChannelPush = "push"
+	// ChannelInApp delivers to browser clients connected to the gateway
+	ChannelInApp = "in_app"
+)
+
+// DeviceTokenLookup returns the most recently registered device of a user
+type DeviceTokenLookup interface {
+	GetDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) (database.DeviceToken, error)
+}
+
+// PushChannel sends messages to the receiver's device through FCM
+type PushChannel struct {
+	tokens   DeviceTokenLookup
+	firebase firebase.ClientInterface
+}
+
+// NewPushChannel creates a push channel backed by the device token store and Firebase
+func NewPushChannel(tokens DeviceTokenLookup, fb firebase.ClientInterface) *PushChannel {
+	return &PushChannel{
+		tokens:   tokens,
+		firebase: fb,
+	}
+}
+
+// Name returns the channel name used in policies
+func (c *PushChannel) Name() string {
+	return ChannelPush
+}
+
+// Send looks up the receiver's device and pushes the message to it in the device's locale
+func (c *PushChannel) Send(ctx context.Context, msg Message) (Outcome, string, error) {
+	device, err := c.tokens.GetDeviceTokensByUserID(ctx, msg.ReceiverID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && device.DeviceToken == "") {
+		return NoTargetFound, "no device token", nil
+	}
+	if err != nil {
+		return Failed, "", err
+	}
+
+	if ok, reason := canReceive(device, msg); !ok {
+		return NoTargetFound, reason, nil
+	}
+
+	if c.firebase == nil || c.firebase.GetMessagingClient() == nil {
+		return NoTargetFound, "firebase not initialized", nil
+	}
+
+	title, content := localize(msg, device.Locale)
+	message := &messaging.Message{
+		Notification: &messaging.Notification{
+			Title: title,
+			Body:  content,
+		},
+		Token: device.DeviceToken,
+		Data: map[string]string{
+			"title":           title,
+			"sender_username": msg.SenderUsername,
+			"receiver_id":     msg.ReceiverID.String(),
+			"content":         content,
+			"sent_at":         msg.SentAt.Format(time.RFC3339),
+			"tracking_id":     msg.ID.String(),
+		},
+	}
+	if msg.Experiment != "" {
+		message.Data["experiment"] = msg.Experiment
+		message.Data["variant"] = msg.Variant
+	}
+
+	response, err := c.firebase.GetMessagingClient().Send(ctx, message)
+	if err != nil {
+		return Failed, err.Error(), nil
+	}
+
+	return Delivered, "fcm message " + response, nil
+}
+
+// InAppChannel delivers messages to the receiver's live gateway connections
+type InAppChannel struct {
+	hub *hub.Hub
+}
+
+// NewInAppChannel creates an in-app channel publishing to the hub
+func NewInAppChannel(h *hub.Hub) *InAppChannel {
+	return &InAppChannel{hub: h}
+}
+
+// Name returns the channel name used in policies
+func (c *InAppChannel) Name() string {
+	return ChannelInApp
+}
+
+// Send publishes the message to every open connection of the receiver
+func (c *InAppChannel) Send(_ context.Context, msg Message) (Outcome, string, error) {
+	delivered := c.hub.Publish(msg.ReceiverID, hub.Event{
+		ID:             msg.ID.String(),
+		Title:          msg.Title,
+		SenderUsername: msg.SenderUsername,
+		ReceiverID:     msg.ReceiverID.String(),
+		Content:        msg.Content,
+		SentAt:         msg.SentAt,
+	})
+	if delivered == 0 {
+		return NoTargetFound, "no live connections", nil
+	}
+
+	return Delivered, "", nil
+}
@@ -0,0 +1,97 @@
+package routing
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/imhasandl/notification-service/internal/database"
+)
+
+// PushPermissionDenied is the permission status reported by devices where the user turned notifications off
+const PushPermissionDenied = "denied"
+
+// Translation is the localized content of a notification
+type Translation struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// canReceive reports whether the device can display the message, returning the reason when it can't
+func canReceive(device database.DeviceToken, msg Message) (bool, string) {
+	if device.PushPermission == PushPermissionDenied {
+		return false, "push permission denied on device"
+	}
+
+	if msg.MinAppVersion != "" && device.AppVersion != "" && compareVersions(device.AppVersion, msg.MinAppVersion) < 0 {
+		return false, "app version " + device.AppVersion + " is older than " + msg.MinAppVersion
+	}
+
+	return true, ""
+}
+
+// localize picks the title and content for the device's locale, trying the exact locale
+// ("pt-BR") before its language ("pt") and falling back to the message's own content
+func localize(msg Message, locale string) (string, string) {
+	if locale == "" || len(msg.Translations) == 0 {
+		return msg.Title, msg.Content
+	}
+
+	locale = strings.ReplaceAll(locale, "_", "-")
+	candidates := []string{locale}
+	if language, _, ok := strings.Cut(locale, "-"); ok {
+		candidates = append(candidates, language)
+	}
+
+	for _, candidate := range candidates {
+		for key, translation := range msg.Translations {
+			if strings.EqualFold(strings.ReplaceAll(key, "_", "-"), candidate) {
+				return translation.Title, translation.Content
+			}
+		}
+	}
+
+	return msg.Title, msg.Content
+}
+
+// compareVersions compares dotted numeric versions like "2.10.1", returning -1, 0 or 1.
+// Pre-release and build suffixes ("2.1.0-beta", "2.1.0+42") are ignored and missing parts count as zero.
+func compareVersions(a, b string) int {
+	partsA := versionParts(a)
+	partsB := versionParts(b)
+
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var x, y int
+		if i < len(partsA) {
+			x = partsA[i]
+		}
+		if i < len(partsB) {
+			y = partsB[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// versionParts splits a version into its numeric components
+func versionParts(version string) []int {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+ "); i >= 0 {
+		version = version[:i]
+	}
+
+	var parts []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			n = 0
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
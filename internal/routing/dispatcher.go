@@ -0,0 +1,204 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message is a notification ready to be routed to the receiver's channels
+type Message struct {
+	ID             uuid.UUID
+	Category       string
+	Title          string
+	SenderUsername string
+	ReceiverID     uuid.UUID
+	Content        string
+	SentAt         time.Time
+	// MinAppVersion skips push to devices running an older app that can't render the message
+	MinAppVersion string
+	// Translations holds localized content keyed by locale ("es", "pt-BR"), chosen per device
+	Translations map[string]Translation
+	// Experiment and Variant name the A/B test content the receiver was assigned, if any
+	Experiment string
+	Variant    string
+}
+
+// Outcome is the result of sending a message on a channel
+type Outcome string
+
+const (
+	// Delivered means the channel handed the message to at least one target
+	Delivered Outcome = "delivered"
+	// NoTargetFound means the channel had nowhere to deliver, e.g. the user has no device tokens
+	NoTargetFound Outcome = "no_target"
+	// Failed means the channel had a target but the provider rejected the message
+	Failed Outcome = "failed"
+	// Skipped means the step's condition didn't match so the channel wasn't used
+	Skipped Outcome = "skipped"
+	// Scheduled means the step will be evaluated again after its delay
+	Scheduled Outcome = "scheduled"
+)
+
+// Channel delivers messages over a single medium such as push or in-app.
+// Send returns an error only for infrastructure failures that should make the caller retry,
+// provider rejections are reported as a Failed outcome with a reason.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg Message) (Outcome, string, error)
+}
+
+// DeliveryChecker reports whether the receiver got a message by the time a delayed fallback is due,
+// e.g. because their app reported opening it
+type DeliveryChecker interface {
+	Delivered(ctx context.Context, messageID uuid.UUID) (bool, error)
+}
+
+// Observer is told about every dispatched message once its chain has run, e.g. to record
+// deliveries. Observers shouldn't block, the caller waits for them.
+type Observer interface {
+	Observe(ctx context.Context, msg Message, report *Report)
+}
+
+// Decision records what the dispatcher did for one step of a chain
+type Decision struct {
+	Channel string
+	Outcome Outcome
+	Reason  string
+}
+
+// Report is the full set of decisions taken for a message
+type Report struct {
+	MessageID uuid.UUID
+	Category  string
+	Decisions []Decision
+}
+
+// Delivered reports whether any channel delivered the message
+func (r *Report) Delivered() bool {
+	for _, d := range r.Decisions {
+		if d.Outcome == Delivered {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTarget reports whether any channel so far found a target, even if delivery failed
+func (r *Report) hasTarget() bool {
+	for _, d := range r.Decisions {
+		if d.Outcome == Delivered || d.Outcome == Failed {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatcher routes messages through the channel chain configured for their category
+type Dispatcher struct {
+	policy    *Policy
+	channels  map[string]Channel
+	fallbacks FallbackStore
+	checker   DeliveryChecker
+	observers []Observer
+}
+
+// NewDispatcher creates a dispatcher for the policy. Delayed fallbacks are stored in fallbacks
+// until they are due and the checker decides whether they still run, both may only be nil when
+// the policy has no delayed steps.
+func NewDispatcher(policy *Policy, fallbacks FallbackStore, checker DeliveryChecker, channels ...Channel) (*Dispatcher, error) {
+	byName := make(map[string]Channel, len(channels))
+	for _, ch := range channels {
+		byName[ch.Name()] = ch
+	}
+
+	if err := policy.Validate(byName); err != nil {
+		return nil, err
+	}
+	if policy.hasDelayedSteps() && (fallbacks == nil || checker == nil) {
+		return nil, errors.New("delayed fallbacks need a fallback store and a delivery checker")
+	}
+
+	return &Dispatcher{
+		policy:    policy,
+		channels:  byName,
+		fallbacks: fallbacks,
+		checker:   checker,
+	}, nil
+}
+
+// AddObserver registers an observer for every later dispatch. It must be called before the
+// dispatcher is used.
+func (d *Dispatcher) AddObserver(observer Observer) {
+	d.observers = append(d.observers, observer)
+}
+
+// Dispatch sends the message through its category's chain and logs every decision.
+// It returns an error if a channel hit an infrastructure failure and no channel delivered the
+// message. Once one did, a retry would deliver it again, so failed steps are only reported.
+func (d *Dispatcher) Dispatch(ctx context.Context, msg Message) (*Report, error) {
+	if msg.Category == "" {
+		msg.Category = DefaultCategory
+	}
+
+	report := &Report{MessageID: msg.ID, Category: msg.Category}
+	var firstErr error
+
+	for _, step := range d.policy.Chain(msg.Category) {
+		decision, err := d.runStep(ctx, step, msg, report)
+		report.Decisions = append(report.Decisions, decision)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("channel %s: %w", step.Channel, err)
+		}
+	}
+
+	d.logReport(report)
+	for _, observer := range d.observers {
+		observer.Observe(ctx, msg, report)
+	}
+	if report.Delivered() {
+		return report, nil
+	}
+	return report, firstErr
+}
+
+// runStep evaluates the step's condition against the report so far and sends if it matches
+func (d *Dispatcher) runStep(ctx context.Context, step Step, msg Message, report *Report) (Decision, error) {
+	switch step.When {
+	case NoTarget:
+		if report.hasTarget() {
+			return Decision{Channel: step.Channel, Outcome: Skipped, Reason: "earlier channel found a target"}, nil
+		}
+	case Undelivered:
+		// A provider accepting a push doesn't mean the user got it, so delayed steps wait for the
+		// delivery checker instead of trusting the earlier outcomes
+		if step.After > 0 {
+			return d.schedule(ctx, step, msg)
+		}
+		if report.Delivered() {
+			return Decision{Channel: step.Channel, Outcome: Skipped, Reason: "already delivered"}, nil
+		}
+	}
+
+	return d.send(ctx, step.Channel, msg)
+}
+
+// send delivers on a single channel and turns the result into a decision
+func (d *Dispatcher) send(ctx context.Context, channel string, msg Message) (Decision, error) {
+	outcome, reason, err := d.channels[channel].Send(ctx, msg)
+	if err != nil {
+		return Decision{Channel: channel, Outcome: Failed, Reason: err.Error()}, err
+	}
+	return Decision{Channel: channel, Outcome: outcome, Reason: reason}, nil
+}
+
+// logReport writes one line per decision so routing can be audited from the logs
+func (d *Dispatcher) logReport(report *Report) {
+	for _, decision := range report.Decisions {
+		log.Printf("routing: message=%s category=%s channel=%s outcome=%s reason=%q", report.MessageID, report.Category, decision.Channel, decision.Outcome, decision.Reason)
+	}
+}
@@ -0,0 +1,141 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/database"
+)
+
+const (
+	// DefaultFallbackInterval is how often the dispatcher looks for due fallbacks
+	DefaultFallbackInterval = 5 * time.Second
+	// fallbackBatchSize is how many due fallbacks are run per transaction
+	fallbackBatchSize = 100
+	// fallbackRetryDelay is how long a fallback waits after a failed check or send
+	fallbackRetryDelay = time.Minute
+	// maxFallbackAttempts is how often a fallback is tried before it is dropped
+	maxFallbackAttempts = 5
+)
+
+// FallbackStore defines the database operations for delayed fallbacks, which are kept in the
+// database until they are due so a restart doesn't lose them
+type FallbackStore interface {
+	ExecTx(ctx context.Context, fn func(database.Querier) error) error
+	CreateRoutingFallback(ctx context.Context, arg database.CreateRoutingFallbackParams) error
+}
+
+// schedule stores a delayed step to run once its delay elapses, if the message is still undelivered.
+// The fallback's id is derived from the message and channel, so dispatching a message again doesn't
+// schedule it twice.
+func (d *Dispatcher) schedule(ctx context.Context, step Step, msg Message) (Decision, error) {
+	body, err := json.Marshal(msg)
+	if err == nil {
+		err = d.fallbacks.CreateRoutingFallback(ctx, database.CreateRoutingFallbackParams{
+			ID:        uuid.NewSHA1(msg.ID, []byte(step.Channel)),
+			MessageID: msg.ID,
+			Channel:   step.Channel,
+			Message:   body,
+			DueAt:     time.Now().Add(time.Duration(step.After)),
+		})
+	}
+	if err != nil {
+		return Decision{Channel: step.Channel, Outcome: Failed, Reason: err.Error()}, fmt.Errorf("can't schedule fallback: %w", err)
+	}
+	return Decision{Channel: step.Channel, Outcome: Scheduled, Reason: fmt.Sprintf("fallback in %s if undelivered", time.Duration(step.After))}, nil
+}
+
+// RunFallbacks runs due fallbacks until the context is cancelled
+func (d *Dispatcher) RunFallbacks(ctx context.Context) {
+	if d.fallbacks == nil {
+		return
+	}
+
+	ticker := time.NewTicker(DefaultFallbackInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Keep going while full batches show there is a backlog
+		for {
+			ran, err := d.RunDueFallbacks(ctx, time.Now())
+			if err != nil {
+				log.Printf("routing: can't run fallbacks: %v", err)
+			}
+			if err != nil || ran < fallbackBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// RunDueFallbacks runs the fallbacks due at the given time and returns how many it handled.
+// A fallback is sent unless the checker reports its message delivered, and retried later when
+// the check or the send fails.
+func (d *Dispatcher) RunDueFallbacks(ctx context.Context, now time.Time) (int, error) {
+	ran := 0
+	err := d.fallbacks.ExecTx(ctx, func(q database.Querier) error {
+		due, err := q.ListDueRoutingFallbacks(ctx, database.ListDueRoutingFallbacksParams{DueAt: now, Limit: fallbackBatchSize})
+		if err != nil {
+			return err
+		}
+
+		for _, fallback := range due {
+			if err := d.runFallback(ctx, q, fallback, now); err != nil {
+				return err
+			}
+			ran++
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// runFallback checks and sends one fallback, removing it unless it has to be retried
+func (d *Dispatcher) runFallback(ctx context.Context, q database.Querier, fallback database.RoutingFallback, now time.Time) error {
+	var msg Message
+	if err := json.Unmarshal(fallback.Message, &msg); err != nil {
+		log.Printf("routing: dropping fallback %s with an unreadable message: %v", fallback.ID, err)
+		return q.DeleteRoutingFallback(ctx, fallback.ID)
+	}
+
+	decision, err := d.runDelayedStep(ctx, fallback.Channel, msg)
+	log.Printf("routing: message=%s category=%s channel=%s outcome=%s reason=%q", msg.ID, msg.Category, decision.Channel, decision.Outcome, decision.Reason)
+	if err == nil {
+		return q.DeleteRoutingFallback(ctx, fallback.ID)
+	}
+
+	if fallback.Attempts+1 >= maxFallbackAttempts {
+		log.Printf("routing: dropping fallback %s after %d attempts: %v", fallback.ID, fallback.Attempts+1, err)
+		return q.DeleteRoutingFallback(ctx, fallback.ID)
+	}
+	return q.RetryRoutingFallback(ctx, database.RetryRoutingFallbackParams{
+		ID:        fallback.ID,
+		LastError: err.Error(),
+		DueAt:     now.Add(fallbackRetryDelay),
+	})
+}
+
+// runDelayedStep sends on the channel unless the message was delivered in the meantime
+func (d *Dispatcher) runDelayedStep(ctx context.Context, channel string, msg Message) (Decision, error) {
+	delivered, err := d.checker.Delivered(ctx, msg.ID)
+	if err != nil {
+		return Decision{Channel: channel, Outcome: Failed, Reason: "can't check delivery: " + err.Error()}, err
+	}
+	if delivered {
+		return Decision{Channel: channel, Outcome: Skipped, Reason: "delivered before fallback"}, nil
+	}
+	if _, ok := d.channels[channel]; !ok {
+		return Decision{Channel: channel, Outcome: Skipped, Reason: "channel no longer configured"}, nil
+	}
+	return d.send(ctx, channel, msg)
+}
@@ -0,0 +1,137 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCategory is used for notifications that don't specify a category
+const DefaultCategory = "default"
+
+// Condition decides whether a step in a chain runs based on the outcome of the steps before it
+type Condition string
+
+const (
+	// Always sends on the channel regardless of earlier steps ("send on all")
+	Always Condition = "always"
+	// NoTarget falls back to the channel when no earlier channel had anywhere to deliver, e.g. no device tokens
+	NoTarget Condition = "no_target"
+	// Undelivered falls back to the channel when no earlier channel delivered the notification
+	Undelivered Condition = "undelivered"
+)
+
+// Step is a single channel in a category's chain
+type Step struct {
+	Channel string    `json:"channel"`
+	When    Condition `json:"when,omitempty"`
+	// After delays an Undelivered step, it only runs if the notification is still undelivered once it elapses
+	After Duration `json:"after,omitempty"`
+}
+
+// Policy maps notification categories to ordered channel chains
+type Policy struct {
+	Default    []Step            `json:"default"`
+	Categories map[string][]Step `json:"categories"`
+}
+
+// Duration is a time.Duration that unmarshals from strings like "10m"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// DefaultPolicy pushes to the user's device and delivers in-app for every category
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Default: []Step{
+			{Channel: ChannelPush, When: Always},
+			{Channel: ChannelInApp, When: Always},
+		},
+	}
+}
+
+// LoadPolicy reads a JSON policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("can't parse routing policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// Chain returns the steps for a category, falling back to the default chain
+func (p *Policy) Chain(category string) []Step {
+	if steps, ok := p.Categories[category]; ok {
+		return steps
+	}
+	return p.Default
+}
+
+// Validate checks that every step names a known channel and a valid condition
+func (p *Policy) Validate(channels map[string]Channel) error {
+	chains := map[string][]Step{DefaultCategory: p.Default}
+	for category, steps := range p.Categories {
+		chains[category] = steps
+	}
+
+	for category, steps := range chains {
+		for i, step := range steps {
+			if _, ok := channels[step.Channel]; !ok {
+				return fmt.Errorf("category %q step %d: unknown channel %q", category, i, step.Channel)
+			}
+			switch step.When {
+			case "", Always, NoTarget:
+				if step.After != 0 {
+					return fmt.Errorf("category %q step %d: after is only supported with %q", category, i, Undelivered)
+				}
+			case Undelivered:
+			default:
+				return fmt.Errorf("category %q step %d: unknown condition %q", category, i, step.When)
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasDelayedSteps reports whether any chain has a step with a delay
+func (p *Policy) hasDelayedSteps() bool {
+	for _, step := range p.Default {
+		if step.After > 0 {
+			return true
+		}
+	}
+	for _, steps := range p.Categories {
+		for _, step := range steps {
+			if step.After > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
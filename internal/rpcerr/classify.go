@@ -0,0 +1,166 @@
+package rpcerr
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/imhasandl/notification-service/internal/broker"
+	"github.com/imhasandl/notification-service/internal/rabbitmq"
+	"github.com/lib/pq"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/grpc/codes"
+)
+
+// classifier recognizes the errors of one dependency, returning nil for errors it doesn't know
+type classifier func(err error) *Error
+
+// classifiers are tried in order, context errors first since every dependency can return them
+var classifiers = []classifier{classifyContext, classifyDatabase, classifyFirebase, classifyBroker, classifyNetwork}
+
+// FromError maps an error of the database, FCM or the message broker to the error sent to
+// clients, temporary failures are marked retryable. Errors already in this model are returned
+// as they are and anything unrecognized is Internal.
+func FromError(err error, message string) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	for _, classify := range classifiers {
+		if e := classify(err); e != nil {
+			e.Message = message
+			return e.WithCause(err)
+		}
+	}
+	return New(codes.Internal, ReasonInternal, message).WithCause(err)
+}
+
+func classifyContext(err error) *Error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return New(codes.Canceled, ReasonCanceled, "")
+	case errors.Is(err, context.DeadlineExceeded):
+		return New(codes.DeadlineExceeded, ReasonDeadlineExceeded, "")
+	}
+	return nil
+}
+
+// classifyDatabase maps sql and Postgres errors
+func classifyDatabase(err error) *Error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return New(codes.NotFound, ReasonNotFound, "")
+	}
+	if errors.Is(err, sql.ErrConnDone) || errors.Is(err, driver.ErrBadConn) {
+		return New(codes.Unavailable, ReasonDatabaseUnavailable, "").WithRetry(DefaultRetryDelay)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return classifyPostgres(string(pqErr.Code))
+	}
+	return nil
+}
+
+// classifyPostgres maps a Postgres error code, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+func classifyPostgres(code string) *Error {
+	switch {
+	case code == "23505": // unique_violation
+		return New(codes.AlreadyExists, ReasonAlreadyExists, "")
+	case code == "40001" || code == "40P01": // serialization_failure, deadlock_detected
+		return New(codes.Aborted, ReasonConflict, "").WithRetry(DefaultRetryDelay)
+	case strings.HasPrefix(code, "08") || strings.HasPrefix(code, "53") || strings.HasPrefix(code, "57P"):
+		// connection exceptions, insufficient resources and shutdowns
+		return New(codes.Unavailable, ReasonDatabaseUnavailable, "").WithRetry(DefaultRetryDelay)
+	}
+	return New(codes.Internal, ReasonDatabaseError, "")
+}
+
+// firebaseRule maps the FCM errors matching any of its predicates
+type firebaseRule struct {
+	matches []func(error) bool
+	code    codes.Code
+	reason  Reason
+	retry   bool
+}
+
+var firebaseRules = []firebaseRule{
+	{
+		matches: []func(error) bool{messaging.IsQuotaExceeded, messaging.IsMessageRateExceeded},
+		code:    codes.ResourceExhausted, reason: ReasonPushQuotaExceeded, retry: true,
+	},
+	{
+		matches: []func(error) bool{messaging.IsUnavailable, messaging.IsServerUnavailable, messaging.IsInternal},
+		code:    codes.Unavailable, reason: ReasonPushProviderUnavailable, retry: true,
+	},
+	{
+		// the device token is gone or malformed, retrying the same token won't help
+		matches: []func(error) bool{messaging.IsUnregistered, messaging.IsRegistrationTokenNotRegistered, messaging.IsInvalidArgument},
+		code:    codes.FailedPrecondition, reason: ReasonPushTokenRejected,
+	},
+	{
+		matches: []func(error) bool{messaging.IsThirdPartyAuthError, messaging.IsSenderIDMismatch, messaging.IsMismatchedCredential, messaging.IsUnknown},
+		code:    codes.Internal, reason: ReasonPushProviderError,
+	},
+}
+
+// classifyFirebase maps FCM errors. Their predicates don't unwrap, so the whole chain is checked.
+func classifyFirebase(err error) *Error {
+	for ; err != nil; err = errors.Unwrap(err) {
+		for _, rule := range firebaseRules {
+			if rule.match(err) {
+				return rule.error()
+			}
+		}
+	}
+	return nil
+}
+
+func (r firebaseRule) match(err error) bool {
+	for _, matches := range r.matches {
+		if matches(err) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r firebaseRule) error() *Error {
+	e := New(r.code, r.reason, "")
+	if r.retry {
+		e.RetryDelay = DefaultRetryDelay
+	}
+	return e
+}
+
+// classifyBroker maps the errors of publishing to the message broker
+func classifyBroker(err error) *Error {
+	if errors.Is(err, rabbitmq.ErrNotConfirmed) || errors.Is(err, broker.ErrClosed) || errors.Is(err, amqp.ErrClosed) {
+		return New(codes.Unavailable, ReasonBrokerUnavailable, "").WithRetry(DefaultRetryDelay)
+	}
+	if errors.Is(err, rabbitmq.ErrUnroutable) {
+		return New(codes.Internal, ReasonBrokerError, "")
+	}
+
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) {
+		if amqpErr.Recover {
+			return New(codes.Unavailable, ReasonBrokerUnavailable, "").WithRetry(DefaultRetryDelay)
+		}
+		return New(codes.Internal, ReasonBrokerError, "")
+	}
+	return nil
+}
+
+// classifyNetwork maps connection failures of any dependency
+func classifyNetwork(err error) *Error {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return New(codes.Unavailable, ReasonUnavailable, "").WithRetry(DefaultRetryDelay)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+// Package rpcerr is the error model of the NotificationService. Every error carries a gRPC code,
+// a stable machine-readable reason clients can branch on, and google.rpc error details: ErrorInfo
+// always, BadRequest field violations for invalid requests and RetryInfo when retrying may succeed.
+package rpcerr
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Domain is the ErrorInfo domain of every error, the reasons are unique within it
+const Domain = "notification-service"
+
+// DefaultRetryDelay is the RetryInfo delay of temporary failures
+const DefaultRetryDelay = time.Second
+
+// Reason identifies the cause of an error, it never changes once published
+type Reason string
+
+// Request errors
+const (
+	// ReasonInvalidArgument is a request field with an invalid value, see the field violations
+	ReasonInvalidArgument Reason = "INVALID_ARGUMENT"
+	// ReasonMalformedJSON is a JSON field that can't be parsed
+	ReasonMalformedJSON Reason = "MALFORMED_JSON"
+	// ReasonInvalidNotification is a notification that parses but is incomplete or inconsistent
+	ReasonInvalidNotification Reason = "INVALID_NOTIFICATION"
+	// ReasonInvalidSegment is a segment filter that can't be compiled
+	ReasonInvalidSegment Reason = "INVALID_SEGMENT"
+	// ReasonInvalidTopic is a topic name FCM doesn't accept
+	ReasonInvalidTopic Reason = "INVALID_TOPIC"
+	// ReasonInvalidEvent is an experiment event other than opened or clicked
+	ReasonInvalidEvent Reason = "INVALID_EVENT"
+)
+
+// Resource errors
+const (
+	ReasonNotFound             Reason = "NOT_FOUND"
+	ReasonDeviceNotFound       Reason = "DEVICE_NOT_FOUND"
+	ReasonNotificationNotFound Reason = "NOTIFICATION_NOT_FOUND"
+	ReasonCampaignNotFound     Reason = "CAMPAIGN_NOT_FOUND"
+	ReasonExperimentNotFound   Reason = "EXPERIMENT_NOT_FOUND"
+	// ReasonAlreadyExists is a unique value, such as a device token, that is already taken
+	ReasonAlreadyExists Reason = "ALREADY_EXISTS"
+	// ReasonCampaignState is a campaign whose status doesn't allow the transition
+	ReasonCampaignState Reason = "CAMPAIGN_STATE"
+)
+
+// Caller errors
+const (
+	ReasonUnauthenticated  Reason = "UNAUTHENTICATED"
+	ReasonPermissionDenied Reason = "PERMISSION_DENIED"
+	// ReasonMissingToken is a call without a bearer token
+	ReasonMissingToken Reason = "MISSING_TOKEN"
+	// ReasonInvalidToken is a bearer token that is malformed, expired or signed by an unknown key
+	ReasonInvalidToken Reason = "INVALID_TOKEN"
+	// ReasonServiceOnly is a user calling an RPC reserved for other services
+	ReasonServiceOnly Reason = "SERVICE_ONLY"
+	// ReasonUserMismatch is a user acting on another user's data
+	ReasonUserMismatch Reason = "USER_MISMATCH"
+	// ReasonCertificateRequired is a call without a client certificate under mutual TLS
+	ReasonCertificateRequired Reason = "CLIENT_CERTIFICATE_REQUIRED"
+	// ReasonClientNotAllowed is a client certificate the TLS client policy doesn't allow the RPC for
+	ReasonClientNotAllowed Reason = "CLIENT_NOT_ALLOWED"
+)
+
+// Infrastructure errors
+const (
+	ReasonDatabaseError           Reason = "DATABASE_ERROR"
+	ReasonDatabaseUnavailable     Reason = "DATABASE_UNAVAILABLE"
+	ReasonConflict                Reason = "CONFLICT"
+	ReasonPushProviderError       Reason = "PUSH_PROVIDER_ERROR"
+	ReasonPushProviderUnavailable Reason = "PUSH_PROVIDER_UNAVAILABLE"
+	ReasonPushQuotaExceeded       Reason = "PUSH_QUOTA_EXCEEDED"
+	ReasonPushTokenRejected       Reason = "PUSH_TOKEN_REJECTED"
+	ReasonBrokerError             Reason = "BROKER_ERROR"
+	ReasonBrokerUnavailable       Reason = "BROKER_UNAVAILABLE"
+	ReasonUnavailable             Reason = "UNAVAILABLE"
+	ReasonCanceled                Reason = "CANCELED"
+	ReasonDeadlineExceeded        Reason = "DEADLINE_EXCEEDED"
+	ReasonInternal                Reason = "INTERNAL"
+)
+
+// FieldViolation is an invalid request field, named by its proto field path such as "user_id"
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// Error is an RPC error. The message is sent to clients, the cause is only logged.
+type Error struct {
+	Code    codes.Code
+	Reason  Reason
+	Message string
+	// Metadata adds context to the ErrorInfo detail, e.g. the status of a campaign
+	Metadata   map[string]string
+	Violations []FieldViolation
+	// RetryDelay is sent as RetryInfo when the call may succeed if retried
+	RetryDelay time.Duration
+	cause      error
+}
+
+// New creates an error
+func New(code codes.Code, reason Reason, message string) *Error {
+	return &Error{Code: code, Reason: reason, Message: message}
+}
+
+// InvalidArgument creates an InvalidArgument error with the field violations
+func InvalidArgument(reason Reason, message string, violations ...FieldViolation) *Error {
+	return &Error{Code: codes.InvalidArgument, Reason: reason, Message: message, Violations: violations}
+}
+
+// InvalidField creates an InvalidArgument error for a single field
+func InvalidField(field, description string) *Error {
+	return InvalidArgument(ReasonInvalidArgument, fmt.Sprintf("invalid %s: %s", field, description), FieldViolation{Field: field, Description: description})
+}
+
+// NotFound creates a NotFound error
+func NotFound(reason Reason, message string) *Error {
+	return New(codes.NotFound, reason, message)
+}
+
+// WithCause records the error that caused this one
+func (e *Error) WithCause(err error) *Error {
+	e.cause = err
+	return e
+}
+
+// WithMetadata adds a key to the ErrorInfo metadata
+func (e *Error) WithMetadata(key, value string) *Error {
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]string)
+	}
+	e.Metadata[key] = value
+	return e
+}
+
+// WithRetry tells clients the call may succeed if retried after the delay
+func (e *Error) WithRetry(delay time.Duration) *Error {
+	e.RetryDelay = delay
+	return e
+}
+
+// Error describes the error with its cause, for logs
+func (e *Error) Error() string {
+	if e.cause == nil {
+		return fmt.Sprintf("%s (%s): %s", e.Code, e.Reason, e.Message)
+	}
+	return fmt.Sprintf("%s (%s): %s: %v", e.Code, e.Reason, e.Message, e.cause)
+}
+
+// Unwrap returns the cause
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// GRPCStatus converts the error to the status sent to clients, status.FromError and gRPC servers use it
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.Code, e.Message)
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: string(e.Reason), Domain: Domain, Metadata: e.Metadata}}
+	if len(e.Violations) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, v := range e.Violations {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: v.Field, Description: v.Description})
+		}
+		details = append(details, badRequest)
+	}
+	if e.RetryDelay > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryDelay)})
+	}
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// FromStatus reads an error back from a status, e.g. one received by a client. Statuses without
+// an ErrorInfo of this domain get the reason of their code.
+func FromStatus(st *status.Status) *Error {
+	reason, ok := codeReasons[st.Code()]
+	if !ok {
+		reason = ReasonInternal
+	}
+	e := New(st.Code(), reason, st.Message())
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if d.GetDomain() == Domain {
+				e.Reason = Reason(d.GetReason())
+				e.Metadata = d.GetMetadata()
+			}
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				e.Violations = append(e.Violations, FieldViolation{Field: v.GetField(), Description: v.GetDescription()})
+			}
+		case *errdetails.RetryInfo:
+			e.RetryDelay = d.GetRetryDelay().AsDuration()
+		}
+	}
+	return e
+}
+
+// Convert returns the error model of any error, a status, an *Error or an unclassified error
+func Convert(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return FromStatus(status.Convert(err))
+}
+
+// ReasonOf returns the reason of an error
+func ReasonOf(err error) Reason {
+	if err == nil {
+		return ""
+	}
+	return Convert(err).Reason
+}
+
+// IsServerError reports whether the code is the server's fault rather than the caller's, the
+// codes mapped to 5XX HTTP statuses
+func IsServerError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// codeReasons are the reasons of statuses created without this package, e.g. by gRPC itself.
+// Other codes get ReasonInternal.
+var codeReasons = map[codes.Code]Reason{
+	codes.Canceled:         ReasonCanceled,
+	codes.InvalidArgument:  ReasonInvalidArgument,
+	codes.DeadlineExceeded: ReasonDeadlineExceeded,
+	codes.NotFound:         ReasonNotFound,
+	codes.AlreadyExists:    ReasonAlreadyExists,
+	codes.PermissionDenied: ReasonPermissionDenied,
+	codes.Unauthenticated:  ReasonUnauthenticated,
+	codes.Aborted:          ReasonConflict,
+	codes.Unavailable:      ReasonUnavailable,
+	codes.Unknown:          ReasonInternal,
+	codes.Internal:         ReasonInternal,
+	codes.DataLoss:         ReasonInternal,
+}
@@ -0,0 +1,143 @@
+package segments
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/database"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultBatchSize is how many users are loaded and sent to per batch
+	DefaultBatchSize = 500
+	// MaxBatchSize caps the batch size callers may ask for
+	MaxBatchSize = 5000
+	// DefaultRate is how many users per second are sent to when no rate is given
+	DefaultRate = 100
+	// MaxRate caps the rate callers may ask for, so one broadcast can't flood FCM and the database
+	MaxRate = 1000
+)
+
+// Source finds the users matching a segment
+type Source interface {
+	CountSegmentUsers(ctx context.Context, filter database.SegmentFilter) (int64, error)
+	ListSegmentUsers(ctx context.Context, filter database.SegmentFilter, after uuid.UUID, limit int32) ([]uuid.UUID, error)
+}
+
+// SendFunc sends the message to one user. It reports whether any channel delivered it,
+// an error counts the user as failed without stopping the broadcast.
+type SendFunc func(ctx context.Context, userID uuid.UUID) (bool, error)
+
+// Options controls the pace of a broadcast
+type Options struct {
+	BatchSize int32
+	// Rate is the number of users sent to per second
+	Rate int32
+	// Resume continues a broadcast from an earlier checkpoint
+	Resume Progress
+}
+
+// Progress counts what a broadcast has done so far. LastUserID is the checkpoint
+// to resume from, users are visited in id order.
+type Progress struct {
+	Total      int64
+	Processed  int64
+	Sent       int64
+	Skipped    int64
+	Failed     int64
+	LastUserID uuid.UUID
+	Done       bool
+}
+
+// ReportFunc receives progress after every batch, returning an error stops the broadcast
+type ReportFunc func(Progress) error
+
+// Broadcaster sends to every user of a segment in batches, throttled to a steady rate
+type Broadcaster struct {
+	source Source
+	send   SendFunc
+}
+
+// NewBroadcaster creates a broadcaster that loads users from the source and sends with send
+func NewBroadcaster(source Source, send SendFunc) *Broadcaster {
+	return &Broadcaster{
+		source: source,
+		send:   send,
+	}
+}
+
+// Run sends to the segment until every user is processed, the context is cancelled or
+// report returns an error. It returns the progress reached either way.
+func (b *Broadcaster) Run(ctx context.Context, segment *Segment, opts Options, report ReportFunc) (Progress, error) {
+	opts = opts.WithDefaults()
+	progress := opts.Resume
+	progress.Done = false
+
+	// A resumed broadcast keeps the total counted when it started
+	if progress.Total == 0 {
+		total, err := b.source.CountSegmentUsers(ctx, segment)
+		if err != nil {
+			return progress, err
+		}
+		progress.Total = total
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(opts.Rate), 1)
+	for {
+		users, err := b.source.ListSegmentUsers(ctx, segment, progress.LastUserID, opts.BatchSize)
+		if err != nil {
+			return progress, err
+		}
+		if len(users) == 0 {
+			progress.Done = true
+			return progress, report(progress)
+		}
+
+		if err := b.sendBatch(ctx, limiter, users, &progress); err != nil {
+			return progress, err
+		}
+		if err := report(progress); err != nil {
+			return progress, err
+		}
+	}
+}
+
+// sendBatch sends to each user of the batch at the limiter's pace, updating progress as it goes
+func (b *Broadcaster) sendBatch(ctx context.Context, limiter *rate.Limiter, users []uuid.UUID, progress *Progress) error {
+	for _, userID := range users {
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		delivered, err := b.send(ctx, userID)
+		switch {
+		case err != nil:
+			progress.Failed++
+		case delivered:
+			progress.Sent++
+		default:
+			progress.Skipped++
+		}
+		progress.Processed++
+		progress.LastUserID = userID
+	}
+	return nil
+}
+
+// WithDefaults fills unset options and caps the batch size and rate
+func (o Options) WithDefaults() Options {
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+	if o.BatchSize > MaxBatchSize {
+		o.BatchSize = MaxBatchSize
+	}
+	if o.Rate <= 0 {
+		o.Rate = DefaultRate
+	}
+	if o.Rate > MaxRate {
+		o.Rate = MaxRate
+	}
+	return o
+}
@@ -0,0 +1,262 @@
+package segments
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// maxDepth limits how deeply groups can be nested
+	maxDepth = 8
+	// maxConditions limits the number of conditions in a segment
+	maxConditions = 64
+)
+
+// Filter is a segment definition over the users table. A filter is either a group combining
+// other filters with all (AND), any (OR) or not, or a single condition on a column:
+//
+//	{"all": [
+//	  {"field": "is_verified", "op": "eq", "value": false},
+//	  {"field": "created_at", "op": "within", "value": "168h"}
+//	]}
+type Filter struct {
+	All   []Filter        `json:"all,omitempty"`
+	Any   []Filter        `json:"any,omitempty"`
+	Not   *Filter         `json:"not,omitempty"`
+	Field string          `json:"field,omitempty"`
+	Op    string          `json:"op,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// kind is the type of a users column, it decides which operators and values are allowed
+type kind int
+
+const (
+	kindBool kind = iota
+	kindText
+	kindTime
+)
+
+// fields are the users columns a segment can filter on
+var fields = map[string]kind{
+	"is_premium":  kindBool,
+	"is_verified": kindBool,
+	"username":    kindText,
+	"email":       kindText,
+	"created_at":  kindTime,
+	"updated_at":  kindTime,
+}
+
+// comparisons maps comparison operators to SQL
+var comparisons = map[string]string{
+	"eq":  "=",
+	"neq": "<>",
+	"lt":  "<",
+	"lte": "<=",
+	"gt":  ">",
+	"gte": ">=",
+}
+
+// Segment is a compiled filter, ready to be evaluated against Postgres
+type Segment struct {
+	clause string
+	args   []interface{}
+}
+
+// Clause returns the SQL condition and its arguments, it implements database.SegmentFilter
+func (s *Segment) Clause() (string, []interface{}) {
+	return s.clause, append([]interface{}{}, s.args...)
+}
+
+// Parse decodes and compiles a JSON segment definition. Relative times such as
+// "within 168h" are resolved against now, so they stay fixed for the whole send.
+func Parse(data []byte, now time.Time) (*Segment, error) {
+	var filter Filter
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&filter); err != nil {
+		return nil, fmt.Errorf("invalid segment: %w", err)
+	}
+	return Compile(filter, now)
+}
+
+// Compile turns a filter into a parameterized SQL condition
+func Compile(filter Filter, now time.Time) (*Segment, error) {
+	c := &compiler{now: now}
+	clause, err := c.compile(filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Segment{clause: clause, args: c.args}, nil
+}
+
+// compiler accumulates the positional arguments while walking a filter
+type compiler struct {
+	now        time.Time
+	args       []interface{}
+	conditions int
+}
+
+// compile dispatches on the shape of the filter
+func (c *compiler) compile(f Filter, depth int) (string, error) {
+	if depth > maxDepth {
+		return "", fmt.Errorf("segment is nested deeper than %d levels", maxDepth)
+	}
+
+	switch {
+	case f.shapes() != 1:
+		return "", errors.New("a filter needs exactly one of all, any, not or field")
+	case f.All != nil:
+		return c.group(f.All, " AND ", "TRUE", depth)
+	case f.Any != nil:
+		return c.group(f.Any, " OR ", "FALSE", depth)
+	case f.Not != nil:
+		clause, err := c.compile(*f.Not, depth+1)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + clause + ")", nil
+	default:
+		return c.condition(f)
+	}
+}
+
+// shapes counts how many of the mutually exclusive parts of a filter are set
+func (f Filter) shapes() int {
+	n := 0
+	for _, set := range []bool{f.All != nil, f.Any != nil, f.Not != nil, f.Field != ""} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// group joins the compiled children, an empty group evaluates to its identity value
+func (c *compiler) group(children []Filter, sep, empty string, depth int) (string, error) {
+	if len(children) == 0 {
+		return empty, nil
+	}
+
+	clauses := make([]string, 0, len(children))
+	for _, child := range children {
+		clause, err := c.compile(child, depth+1)
+		if err != nil {
+			return "", err
+		}
+		clauses = append(clauses, "("+clause+")")
+	}
+	return strings.Join(clauses, sep), nil
+}
+
+// condition compiles a single comparison on a whitelisted column
+func (c *compiler) condition(f Filter) (string, error) {
+	c.conditions++
+	if c.conditions > maxConditions {
+		return "", fmt.Errorf("segment has more than %d conditions", maxConditions)
+	}
+
+	k, ok := fields[f.Field]
+	if !ok {
+		return "", fmt.Errorf("unknown field %q", f.Field)
+	}
+
+	switch k {
+	case kindBool:
+		return c.boolCondition(f)
+	case kindText:
+		return c.textCondition(f)
+	default:
+		return c.timeCondition(f)
+	}
+}
+
+// boolCondition supports eq and neq against true or false
+func (c *compiler) boolCondition(f Filter) (string, error) {
+	if f.Op != "eq" && f.Op != "neq" {
+		return "", unsupported(f)
+	}
+
+	var value bool
+	if err := json.Unmarshal(f.Value, &value); err != nil {
+		return "", fmt.Errorf("field %q needs a boolean value", f.Field)
+	}
+	return c.compare(f.Field, comparisons[f.Op], value), nil
+}
+
+// textCondition supports eq, neq, in and prefix
+func (c *compiler) textCondition(f Filter) (string, error) {
+	switch f.Op {
+	case "in":
+		var values []string
+		if err := json.Unmarshal(f.Value, &values); err != nil || len(values) == 0 {
+			return "", fmt.Errorf("field %q with op in needs a non-empty list of strings", f.Field)
+		}
+		return fmt.Sprintf("%s = ANY(%s::text[])", f.Field, c.arg(pq.Array(values))), nil
+	case "eq", "neq", "prefix":
+	default:
+		return "", unsupported(f)
+	}
+
+	var value string
+	if err := json.Unmarshal(f.Value, &value); err != nil {
+		return "", fmt.Errorf("field %q needs a string value", f.Field)
+	}
+	if f.Op == "prefix" {
+		return fmt.Sprintf("starts_with(%s, %s)", f.Field, c.arg(value)), nil
+	}
+	return c.compare(f.Field, comparisons[f.Op], value), nil
+}
+
+// timeCondition supports comparisons against RFC 3339 timestamps, and within / older_than
+// a duration such as "168h" relative to the time the segment was compiled
+func (c *compiler) timeCondition(f Filter) (string, error) {
+	var value string
+	if err := json.Unmarshal(f.Value, &value); err != nil {
+		return "", fmt.Errorf("field %q needs a string value", f.Field)
+	}
+
+	if f.Op == "within" || f.Op == "older_than" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return "", fmt.Errorf("field %q with op %s needs a positive duration like \"168h\"", f.Field, f.Op)
+		}
+		op := ">="
+		if f.Op == "older_than" {
+			op = "<"
+		}
+		return c.compare(f.Field, op, c.now.Add(-d)), nil
+	}
+
+	op, ok := comparisons[f.Op]
+	if !ok {
+		return "", unsupported(f)
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", fmt.Errorf("field %q needs an RFC 3339 timestamp", f.Field)
+	}
+	return c.compare(f.Field, op, t), nil
+}
+
+// compare renders "field op $n" for a whitelisted column and operator
+func (c *compiler) compare(field, op string, value interface{}) string {
+	return fmt.Sprintf("%s %s %s", field, op, c.arg(value))
+}
+
+// arg adds a positional argument and returns its placeholder
+func (c *compiler) arg(value interface{}) string {
+	c.args = append(c.args, value)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+// unsupported reports an operator that isn't valid for the field
+func unsupported(f Filter) error {
+	return fmt.Errorf("op %q is not supported for field %q", f.Op, f.Field)
+}
@@ -0,0 +1,106 @@
+// Package signing authenticates the services publishing notification requests. A producer signs
+// each request with its own HMAC secret or Ed25519 key and the service checks the signature and
+// that the producer may send the request's category before delivering it.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Message headers carrying a request's signature
+const (
+	// HeaderProducer names the producer whose key signed the request
+	HeaderProducer = "Producer-Id"
+	// HeaderSignature is the base64 signature of the request
+	HeaderSignature = "Signature"
+)
+
+// Algorithm is how a producer signs its requests
+type Algorithm string
+
+// Signing algorithms
+const (
+	// HMACSHA256 signs with a secret shared between the producer and the service
+	HMACSHA256 Algorithm = "hmac-sha256"
+	// Ed25519 signs with the producer's private key, the service only knows the public key
+	Ed25519 Algorithm = "ed25519"
+)
+
+var (
+	// ErrUnsigned is returned for a request without a producer or signature
+	ErrUnsigned = errors.New("signing: request is not signed")
+	// ErrUnknownProducer is returned for a request signed by a producer the service doesn't know
+	ErrUnknownProducer = errors.New("signing: unknown producer")
+	// ErrInvalidSignature is returned when the signature doesn't match the request
+	ErrInvalidSignature = errors.New("signing: invalid signature")
+	// ErrForbiddenCategory is returned when the producer may not send the request's category
+	ErrForbiddenCategory = errors.New("signing: producer may not send this category")
+)
+
+// Content returns what a signature covers: the producer id, the content type and the body,
+// separated by newlines. The producer id and content type can't contain a newline, so no two
+// requests share the same content.
+func Content(producerID, contentType string, body []byte) []byte {
+	content := make([]byte, 0, len(producerID)+len(contentType)+len(body)+2)
+	content = append(content, producerID...)
+	content = append(content, '\n')
+	content = append(content, contentType...)
+	content = append(content, '\n')
+	return append(content, body...)
+}
+
+// Signer signs the requests of one producer
+type Signer struct {
+	producerID string
+	sign       func(content []byte) []byte
+}
+
+// NewHMACSigner creates a signer using HMAC-SHA256 with the producer's shared secret
+func NewHMACSigner(producerID string, secret []byte) *Signer {
+	return &Signer{
+		producerID: producerID,
+		sign: func(content []byte) []byte {
+			mac := hmac.New(sha256.New, secret)
+			mac.Write(content)
+			return mac.Sum(nil)
+		},
+	}
+}
+
+// NewEd25519Signer creates a signer using the producer's Ed25519 private key
+func NewEd25519Signer(producerID string, key ed25519.PrivateKey) *Signer {
+	return &Signer{
+		producerID: producerID,
+		sign: func(content []byte) []byte {
+			return ed25519.Sign(key, content)
+		},
+	}
+}
+
+// ProducerID returns the id sent in the Producer-Id header
+func (s *Signer) ProducerID() string {
+	return s.producerID
+}
+
+// Sign returns the Signature header of a request with the content type and body
+func (s *Signer) Sign(contentType string, body []byte) string {
+	return base64.StdEncoding.EncodeToString(s.sign(Content(s.producerID, contentType, body)))
+}
+
+// header looks a header up case-insensitively, brokers differ in how they case header names
+func header(headers map[string]string, name string) string {
+	if value, ok := headers[name]; ok {
+		return value
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
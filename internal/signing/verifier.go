@@ -0,0 +1,142 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AnyCategory in a producer's categories lets it send every category
+const AnyCategory = "*"
+
+// Producer is a service allowed to publish notification requests
+type Producer struct {
+	ID        string    `json:"id"`
+	Algorithm Algorithm `json:"algorithm"`
+	// Key is the base64 HMAC secret, or the base64 Ed25519 public key
+	Key string `json:"key"`
+	// Categories the producer may send, requests without a category are "default"
+	Categories []string `json:"categories"`
+}
+
+// LoadProducers reads a JSON file of the form {"producers": [...]}
+func LoadProducers(path string) ([]Producer, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Producers []Producer `json:"producers"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("can't parse producers: %w", err)
+	}
+	return file.Producers, nil
+}
+
+// producer is a known producer with its decoded key
+type producer struct {
+	verify     func(content, signature []byte) bool
+	categories map[string]bool
+}
+
+// Verifier checks that requests are signed by a known producer allowed to send their category.
+// Without enforcement failures are only logged, so producers can start signing one at a time.
+type Verifier struct {
+	producers map[string]producer
+	enforce   bool
+}
+
+// NewVerifier creates a verifier for the producers, rejecting requests that fail when enforce is set
+func NewVerifier(producers []Producer, enforce bool) (*Verifier, error) {
+	v := &Verifier{producers: make(map[string]producer, len(producers)), enforce: enforce}
+	for _, p := range producers {
+		if p.ID == "" || strings.Contains(p.ID, "\n") {
+			return nil, fmt.Errorf("invalid producer id %q", p.ID)
+		}
+		if _, ok := v.producers[p.ID]; ok {
+			return nil, fmt.Errorf("producer %s is listed twice", p.ID)
+		}
+
+		verify, err := verifyFunc(p.Algorithm, p.Key)
+		if err != nil {
+			return nil, fmt.Errorf("producer %s: %w", p.ID, err)
+		}
+		categories := make(map[string]bool, len(p.Categories))
+		for _, category := range p.Categories {
+			categories[category] = true
+		}
+		v.producers[p.ID] = producer{verify: verify, categories: categories}
+	}
+	return v, nil
+}
+
+// verifyFunc decodes a producer's key and returns the check of its signatures
+func verifyFunc(algorithm Algorithm, encodedKey string) (func(content, signature []byte) bool, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("invalid %s key", algorithm)
+	}
+
+	switch algorithm {
+	case HMACSHA256:
+		return func(content, signature []byte) bool {
+			mac := hmac.New(sha256.New, key)
+			mac.Write(content)
+			return hmac.Equal(mac.Sum(nil), signature)
+		}, nil
+	case Ed25519:
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid %s key", algorithm)
+		}
+		return func(content, signature []byte) bool {
+			return ed25519.Verify(ed25519.PublicKey(key), content, signature)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q", algorithm)
+	}
+}
+
+// Verify checks the signature in the headers of a request and that its producer may send the
+// category. It returns nil when the request is accepted, which without enforcement it always is.
+func (v *Verifier) Verify(headers map[string]string, contentType string, body []byte, category string) error {
+	producerID, err := v.verify(headers, contentType, body, category)
+	if err == nil {
+		return nil
+	}
+	if !v.enforce {
+		log.Printf("Accepting request from %q that would be rejected: %v", producerID, err)
+		return nil
+	}
+	return err
+}
+
+func (v *Verifier) verify(headers map[string]string, contentType string, body []byte, category string) (string, error) {
+	producerID := header(headers, HeaderProducer)
+	encoded := header(headers, HeaderSignature)
+	if producerID == "" || encoded == "" {
+		return producerID, ErrUnsigned
+	}
+
+	p, ok := v.producers[producerID]
+	if !ok {
+		return producerID, fmt.Errorf("%w: %s", ErrUnknownProducer, producerID)
+	}
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !p.verify(Content(producerID, contentType, body), signature) {
+		return producerID, ErrInvalidSignature
+	}
+
+	if !p.categories[category] && !p.categories[AnyCategory] {
+		return producerID, fmt.Errorf("%w: %s", ErrForbiddenCategory, category)
+	}
+	return producerID, nil
+}
@@ -0,0 +1,236 @@
+package topics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/firebase"
+)
+
+// maxTokensPerRequest is the most tokens FCM accepts in one topic management call
+const maxTokensPerRequest = 1000
+
+// topicPattern matches the topic names FCM accepts
+var topicPattern = regexp.MustCompile(`^[a-zA-Z0-9\-_.~%]{1,900}$`)
+
+var (
+	// ErrInvalidTopic is returned for topic names FCM would reject
+	ErrInvalidTopic = errors.New("topic must match [a-zA-Z0-9-_.~%]+")
+	// ErrFirebaseUnavailable is returned when there is no FCM client to manage topics with
+	ErrFirebaseUnavailable = errors.New("firebase not initialized")
+)
+
+// Store defines the database operations needed to keep topic subscriptions in sync
+type Store interface {
+	CreateTopicSubscription(ctx context.Context, arg database.CreateTopicSubscriptionParams) error
+	DeleteTopicSubscription(ctx context.Context, arg database.DeleteTopicSubscriptionParams) error
+	ListTopicsByUserID(ctx context.Context, userID uuid.UUID) ([]string, error)
+	ListDeviceTokensByUserID(ctx context.Context, userID uuid.UUID) ([]database.DeviceToken, error)
+}
+
+// Result summarizes a topic management call across a user's devices
+type Result struct {
+	SuccessCount int
+	FailureCount int
+	// Errors holds the FCM reason for every device that failed
+	Errors []string
+}
+
+// Manager subscribes users' devices to FCM topics and keeps the local subscription table in sync
+type Manager struct {
+	store    Store
+	firebase firebase.ClientInterface
+}
+
+// NewManager creates a topic manager backed by the subscription store and Firebase
+func NewManager(store Store, fb firebase.ClientInterface) *Manager {
+	return &Manager{
+		store:    store,
+		firebase: fb,
+	}
+}
+
+// ValidateTopic checks the topic name against the characters FCM allows
+func ValidateTopic(topic string) error {
+	if !topicPattern.MatchString(topic) {
+		return ErrInvalidTopic
+	}
+	return nil
+}
+
+// Subscribe records the user's subscription and subscribes all of their devices to the topic
+func (m *Manager) Subscribe(ctx context.Context, userID uuid.UUID, topic string) (Result, error) {
+	if err := ValidateTopic(topic); err != nil {
+		return Result{}, err
+	}
+
+	err := m.store.CreateTopicSubscription(ctx, database.CreateTopicSubscriptionParams{
+		ID:     uuid.New(),
+		UserID: userID,
+		Topic:  topic,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	tokens, err := m.deviceTokens(ctx, userID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if len(tokens) == 0 {
+		return Result{}, nil
+	}
+
+	client := m.client()
+	if client == nil {
+		return Result{}, ErrFirebaseUnavailable
+	}
+	return m.manage(ctx, client.SubscribeToTopic, tokens, topic)
+}
+
+// Unsubscribe removes the user's subscription and unsubscribes all of their devices from the topic
+func (m *Manager) Unsubscribe(ctx context.Context, userID uuid.UUID, topic string) (Result, error) {
+	if err := ValidateTopic(topic); err != nil {
+		return Result{}, err
+	}
+
+	err := m.store.DeleteTopicSubscription(ctx, database.DeleteTopicSubscriptionParams{
+		UserID: userID,
+		Topic:  topic,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	tokens, err := m.deviceTokens(ctx, userID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if len(tokens) == 0 {
+		return Result{}, nil
+	}
+
+	client := m.client()
+	if client == nil {
+		return Result{}, ErrFirebaseUnavailable
+	}
+	return m.manage(ctx, client.UnsubscribeFromTopic, tokens, topic)
+}
+
+// Send publishes the message to every device subscribed to the topic and returns the FCM message id
+func (m *Manager) Send(ctx context.Context, topic string, message *messaging.Message) (string, error) {
+	if err := ValidateTopic(topic); err != nil {
+		return "", err
+	}
+
+	client := m.client()
+	if client == nil {
+		return "", ErrFirebaseUnavailable
+	}
+
+	message.Topic = topic
+	return client.Send(ctx, message)
+}
+
+// DevicesAdded subscribes newly registered devices to the topics their user follows.
+// Failures are logged, the devices are registered either way.
+func (m *Manager) DevicesAdded(ctx context.Context, userID uuid.UUID, tokens ...string) {
+	m.sync(ctx, userID, tokens, "subscribe", func(client firebase.MessagingClient) manageFunc {
+		return client.SubscribeToTopic
+	})
+}
+
+// DevicesRemoved unsubscribes devices that no longer belong to the user from the user's topics.
+// Failures are logged, the devices are removed either way.
+func (m *Manager) DevicesRemoved(ctx context.Context, userID uuid.UUID, tokens ...string) {
+	m.sync(ctx, userID, tokens, "unsubscribe", func(client firebase.MessagingClient) manageFunc {
+		return client.UnsubscribeFromTopic
+	})
+}
+
+// manageFunc is an FCM topic management call
+type manageFunc func(ctx context.Context, tokens []string, topic string) (*messaging.TopicManagementResponse, error)
+
+// sync applies a topic management call for the tokens to every topic of the user
+func (m *Manager) sync(ctx context.Context, userID uuid.UUID, tokens []string, action string, call func(firebase.MessagingClient) manageFunc) {
+	if len(tokens) == 0 {
+		return
+	}
+
+	topics, err := m.store.ListTopicsByUserID(ctx, userID)
+	if err != nil {
+		log.Printf("topics: can't list topics of user %s: %v", userID, err)
+		return
+	}
+	if len(topics) == 0 {
+		return
+	}
+
+	client := m.client()
+	if client == nil {
+		log.Printf("topics: can't %s %d devices of user %s: %v", action, len(tokens), userID, ErrFirebaseUnavailable)
+		return
+	}
+
+	for _, topic := range topics {
+		result, err := m.manage(ctx, call(client), tokens, topic)
+		if err != nil {
+			log.Printf("topics: can't %s devices of user %s to %s: %v", action, userID, topic, err)
+			continue
+		}
+		if result.FailureCount > 0 {
+			log.Printf("topics: %s failed for %d devices of user %s on %s: %v", action, result.FailureCount, userID, topic, result.Errors)
+		}
+	}
+}
+
+// manage runs a topic management call in batches of the most tokens FCM accepts
+func (m *Manager) manage(ctx context.Context, call manageFunc, tokens []string, topic string) (Result, error) {
+	var result Result
+	for start := 0; start < len(tokens); start += maxTokensPerRequest {
+		end := min(start+maxTokensPerRequest, len(tokens))
+
+		response, err := call(ctx, tokens[start:end], topic)
+		if err != nil {
+			return result, fmt.Errorf("fcm topic management: %w", err)
+		}
+
+		result.SuccessCount += response.SuccessCount
+		result.FailureCount += response.FailureCount
+		for _, e := range response.Errors {
+			result.Errors = append(result.Errors, e.Reason)
+		}
+	}
+
+	return result, nil
+}
+
+// deviceTokens returns the token strings of every device of the user
+func (m *Manager) deviceTokens(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	devices, err := m.store.ListDeviceTokensByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]string, 0, len(devices))
+	for _, device := range devices {
+		tokens = append(tokens, device.DeviceToken)
+	}
+	return tokens, nil
+}
+
+// client returns the FCM client or nil when Firebase isn't initialized
+func (m *Manager) client() firebase.MessagingClient {
+	if m.firebase == nil {
+		return nil
+	}
+	return m.firebase.GetMessagingClient()
+}
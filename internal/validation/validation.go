@@ -0,0 +1,101 @@
+// Package validation checks request messages against the buf.validate rules declared in the proto
+// before they reach the handlers.
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+// Validator rejects requests breaking the rules of their message
+type Validator struct {
+	validator protovalidate.Validator
+}
+
+// New creates a validator, the rules of every message are compiled on first use
+func New() (*Validator, error) {
+	validator, err := protovalidate.New()
+	if err != nil {
+		return nil, fmt.Errorf("can't create validator: %w", err)
+	}
+	return &Validator{validator: validator}, nil
+}
+
+// Validate checks a message, the error is an InvalidArgument rpcerr.Error listing every broken rule.
+// Values that aren't proto messages have no rules and pass.
+func (v *Validator) Validate(msg interface{}) error {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	err := v.validator.Validate(m)
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *protovalidate.ValidationError
+	if !errors.As(err, &validationErr) {
+		return rpcerr.New(codes.Internal, rpcerr.ReasonInternal, "can't validate request: "+err.Error()).WithCause(err)
+	}
+	return invalidRequest(validationErr)
+}
+
+// UnaryInterceptor validates the request of unary calls
+func (v *Validator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := v.Validate(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor validates every message the client of a streaming call sends
+func (v *Validator) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatedStream{ServerStream: stream, validator: v})
+	}
+}
+
+// validatedStream checks the messages it receives
+type validatedStream struct {
+	grpc.ServerStream
+	validator *Validator
+}
+
+// RecvMsg receives a message and rejects it if it breaks its rules
+func (s *validatedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.validator.Validate(m)
+}
+
+// invalidRequest converts the violations into field violations, the message names the first of them
+func invalidRequest(err *protovalidate.ValidationError) *rpcerr.Error {
+	violations := make([]rpcerr.FieldViolation, 0, len(err.Violations))
+	for _, violation := range err.Violations {
+		violations = append(violations, rpcerr.FieldViolation{
+			Field:       protovalidate.FieldPathString(violation.Proto.GetField()),
+			Description: violation.Proto.GetMessage(),
+		})
+	}
+
+	message := "invalid request"
+	if len(violations) > 0 {
+		first := violations[0]
+		message = fmt.Sprintf("invalid %s: %s", first.Field, first.Description)
+		if len(violations) > 1 {
+			message += fmt.Sprintf(" (and %d more)", len(violations)-1)
+		}
+	}
+	return rpcerr.InvalidArgument(rpcerr.ReasonInvalidArgument, message, violations...).WithCause(err)
+}
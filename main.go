@@ -2,20 +2,34 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"fmt"
 	"log"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/auth"
+	"github.com/imhasandl/notification-service/internal/broker"
 	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/events"
 	"github.com/imhasandl/notification-service/internal/firebase"
-	"github.com/imhasandl/notification-service/internal/rabbitmq"
+	"github.com/imhasandl/notification-service/internal/gateway"
+	"github.com/imhasandl/notification-service/internal/janitor"
+	"github.com/imhasandl/notification-service/internal/mtls"
+	"github.com/imhasandl/notification-service/internal/rest"
+	"github.com/imhasandl/notification-service/internal/routing"
+	"github.com/imhasandl/notification-service/internal/signing"
+	"github.com/imhasandl/notification-service/internal/validation"
 	pb "github.com/imhasandl/notification-service/protos"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq" // Import the postgres driver
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -23,8 +37,24 @@ import (
 type Config struct {
 	port            string
 	dbURL           string
-	rabbitmqURL     string
+	broker          broker.Config
 	firebaseKeyPath string
+	httpPort        string
+	restPort        string
+	allowedOrigins  []string
+	routingPolicy   *routing.Policy
+	janitor         janitor.Config
+	maxDevices      int32
+	verifier        *signing.Verifier
+	authenticator   *auth.Authenticator
+	tls             tlsConfig
+}
+
+// tlsConfig holds the transport security settings of gRPC, REST and the browser gateway, which are plaintext without certificates
+type tlsConfig struct {
+	mtls           mtls.Config
+	clientPolicy   mtls.Policy
+	reloadInterval time.Duration
 }
 
 func main() {
@@ -47,11 +77,11 @@ func main() {
 	}
 	defer dbConn.Close()
 
-	rmq, err := initRabbitMQ(config.rabbitmqURL)
+	msgBroker, err := broker.Open(context.Background(), config.broker)
 	if err != nil {
-		log.Fatalf("Failed to initialize RabbitMQ: %v", err)
+		log.Fatalf("Failed to initialize message broker: %v", err)
 	}
-	defer rmq.Close()
+	defer msgBroker.Close()
 
 	fb, err := initFirebase(context.Background(), config.firebaseKeyPath)
 	if err != nil {
@@ -59,8 +89,32 @@ func main() {
 	}
 
 	// Create and start server
-	srv := server.NewServer(dbQueries, rmq, config.firebaseKeyPath, fb)
-	startServer(listener, srv)
+	srv, err := server.NewServer(dbQueries, msgBroker, config.firebaseKeyPath, fb,
+		server.WithRoutingPolicy(config.routingPolicy),
+		server.WithMaxDevicesPerUser(config.maxDevices),
+		server.WithVerifier(config.verifier),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+
+	// Disable or remove devices that stopped sending heartbeats, deleted devices leave their topics
+	config.janitor.OnDelete = srv.Topics().DevicesRemoved
+	deviceJanitor, err := janitor.NewJanitor(dbQueries, config.janitor)
+	if err != nil {
+		log.Fatalf("Failed to create stale device janitor: %v", err)
+	}
+	go deviceJanitor.Run(context.Background())
+
+	// Run scheduled and started campaigns, resuming any interrupted by a restart
+	go srv.Campaigns().Run(context.Background())
+
+	// Run the delayed routing fallbacks as they fall due, including those stored before a restart
+	go srv.Dispatcher().RunFallbacks(context.Background())
+
+	// Publish the delivery status events queued in the outbox
+	go events.NewRelay(dbQueries, msgBroker).Run(context.Background())
+	startServer(listener, srv, config)
 }
 
 // loadConfig loads configuration from environment variables
@@ -79,9 +133,9 @@ func loadConfig() (*Config, error) {
 		return nil, fmt.Errorf("DB_URL environment variable not set")
 	}
 
-	rabbitmqURL := os.Getenv("RABBITMQ_URL")
-	if rabbitmqURL == "" {
-		return nil, fmt.Errorf("RABBITMQ_URL environment variable not set")
+	brokerConfig, err := loadBrokerConfig()
+	if err != nil {
+		return nil, err
 	}
 
 	firebaseKeyPath := os.Getenv("FIREBASE_NOTIFICATION_KEY_PATH")
@@ -89,44 +143,399 @@ func loadConfig() (*Config, error) {
 		return nil, fmt.Errorf("FIREBASE_NOTIFICATION_KEY_PATH environment variable not set")
 	}
 
-	return &Config{
+	config := &Config{
 		port:            port,
 		dbURL:           dbURL,
-		rabbitmqURL:     rabbitmqURL,
+		broker:          brokerConfig,
 		firebaseKeyPath: firebaseKeyPath,
-	}, nil
+		httpPort:        os.Getenv("HTTP_PORT"),
+		restPort:        os.Getenv("REST_PORT"),
+	}
+	if origins := os.Getenv("GATEWAY_ALLOWED_ORIGINS"); origins != "" {
+		config.allowedOrigins = strings.Split(origins, ",")
+	}
+	if err := loadOptionalConfig(config); err != nil {
+		return nil, err
+	}
+
+	// The browser gateway is optional, but it can't tell which user a client is without tokens
+	if config.httpPort != "" && config.authenticator == nil {
+		return nil, fmt.Errorf("HTTP_PORT needs JWKS_SOURCE to authenticate browser clients")
+	}
+
+	return config, nil
+}
+
+// loadOptionalConfig loads the settings that fall back to defaults when unset
+func loadOptionalConfig(config *Config) error {
+	var err error
+
+	config.routingPolicy, err = loadRoutingPolicy()
+	if err != nil {
+		return err
+	}
+
+	config.janitor, err = loadJanitorConfig()
+	if err != nil {
+		return err
+	}
+
+	config.maxDevices, err = loadMaxDevicesPerUser()
+	if err != nil {
+		return err
+	}
+
+	config.verifier, err = loadVerifier()
+	if err != nil {
+		return err
+	}
+
+	config.authenticator, err = loadAuthenticator()
+	if err != nil {
+		return err
+	}
+
+	config.tls, err = loadTLSConfig()
+	return err
+}
+
+// loadBrokerConfig loads the message broker settings, RabbitMQ is used unless BROKER picks another backend
+func loadBrokerConfig() (broker.Config, error) {
+	config := broker.Config{
+		Backend:     broker.Backend(os.Getenv("BROKER")),
+		RabbitMQURL: os.Getenv("RABBITMQ_URL"),
+		NATSURL:     os.Getenv("NATS_URL"),
+	}
+	if brokers := os.Getenv("KAFKA_BROKERS"); brokers != "" {
+		config.KafkaBrokers = strings.Split(brokers, ",")
+	}
+
+	// Each backend needs its own address
+	required := map[broker.Backend]string{
+		"":                     "RABBITMQ_URL",
+		broker.RabbitMQBackend: "RABBITMQ_URL",
+		broker.NATSBackend:     "NATS_URL",
+		broker.KafkaBackend:    "KAFKA_BROKERS",
+	}
+	name, ok := required[config.Backend]
+	if !ok {
+		return config, fmt.Errorf("unknown BROKER %q", config.Backend)
+	}
+	if os.Getenv(name) == "" {
+		return config, fmt.Errorf("%s environment variable not set", name)
+	}
+	return config, nil
+}
+
+// loadRoutingPolicy loads the channel routing policy, every category pushes and delivers in-app by default
+func loadRoutingPolicy() (*routing.Policy, error) {
+	path := os.Getenv("ROUTING_POLICY_PATH")
+	if path == "" {
+		return routing.DefaultPolicy(), nil
+	}
+
+	policy, err := routing.LoadPolicy(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load routing policy: %w", err)
+	}
+	return policy, nil
+}
+
+// loadJanitorConfig loads the optional stale device cleanup settings, unset values use the janitor defaults
+func loadJanitorConfig() (janitor.Config, error) {
+	config := janitor.Config{
+		Action: janitor.Action(os.Getenv("STALE_DEVICE_ACTION")),
+	}
+
+	if maxAge := os.Getenv("STALE_DEVICE_MAX_AGE"); maxAge != "" {
+		d, err := time.ParseDuration(maxAge)
+		if err != nil {
+			return config, fmt.Errorf("invalid STALE_DEVICE_MAX_AGE: %w", err)
+		}
+		config.MaxAge = d
+	}
+
+	if interval := os.Getenv("STALE_DEVICE_CLEANUP_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return config, fmt.Errorf("invalid STALE_DEVICE_CLEANUP_INTERVAL: %w", err)
+		}
+		config.Interval = d
+	}
+
+	if dryRun := os.Getenv("STALE_DEVICE_DRY_RUN"); dryRun != "" {
+		b, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			return config, fmt.Errorf("invalid STALE_DEVICE_DRY_RUN: %w", err)
+		}
+		config.DryRun = b
+	}
+
+	return config, nil
+}
+
+// loadMaxDevicesPerUser loads the per-user device limit, 0 disables it
+func loadMaxDevicesPerUser() (int32, error) {
+	limit := os.Getenv("MAX_DEVICES_PER_USER")
+	if limit == "" {
+		return server.DefaultMaxDevicesPerUser, nil
+	}
+
+	n, err := strconv.ParseInt(limit, 10, 32)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid MAX_DEVICES_PER_USER: %q", limit)
+	}
+	return int32(n), nil
+}
+
+// loadVerifier loads the producers allowed to publish requests from SIGNING_KEYS_PATH. Signatures are
+// only checked when it is set, and failures only logged unless SIGNING_ENFORCE is true.
+func loadVerifier() (*signing.Verifier, error) {
+	enforce := false
+	if value := os.Getenv("SIGNING_ENFORCE"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SIGNING_ENFORCE: %w", err)
+		}
+		enforce = b
+	}
+
+	path := os.Getenv("SIGNING_KEYS_PATH")
+	if path == "" {
+		if enforce {
+			return nil, fmt.Errorf("SIGNING_KEYS_PATH environment variable not set")
+		}
+		return nil, nil
+	}
+
+	producers, err := signing.LoadProducers(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load producer keys: %w", err)
+	}
+	return signing.NewVerifier(producers, enforce)
+}
+
+// loadAuthenticator loads the keys gRPC bearer tokens are checked with from JWKS_SOURCE, a file path or URL.
+// It is required unless AUTH_DISABLED turns authentication off for local development.
+func loadAuthenticator() (*auth.Authenticator, error) {
+	disabled := false
+	if value := os.Getenv("AUTH_DISABLED"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_DISABLED: %w", err)
+		}
+		disabled = b
+	}
+
+	source := os.Getenv("JWKS_SOURCE")
+	if disabled {
+		if source != "" {
+			return nil, fmt.Errorf("JWKS_SOURCE and AUTH_DISABLED are both set")
+		}
+		log.Printf("AUTH_DISABLED is set, gRPC calls are not authenticated")
+		return nil, nil
+	}
+	if source == "" {
+		return nil, fmt.Errorf("JWKS_SOURCE environment variable not set, set AUTH_DISABLED=true to run without authentication")
+	}
+
+	keys, err := auth.LoadJWKS(context.Background(), source)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []auth.AuthenticatorOption
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		opts = append(opts, auth.WithIssuer(issuer))
+	}
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		opts = append(opts, auth.WithAudience(audience))
+	}
+	return auth.NewAuthenticator(keys, server.AccessPolicy(), opts...), nil
+}
+
+// loadTLSConfig loads the TLS certificate and key, and for mutual TLS the client CAs and the policy of
+// which client identities may call which RPCs
+func loadTLSConfig() (tlsConfig, error) {
+	config := tlsConfig{
+		mtls: mtls.Config{
+			CertFile:     os.Getenv("TLS_CERT_FILE"),
+			KeyFile:      os.Getenv("TLS_KEY_FILE"),
+			ClientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		},
+		reloadInterval: mtls.DefaultReloadInterval,
+	}
+	if config.mtls.CertFile == "" && config.mtls.KeyFile == "" {
+		if config.mtls.ClientCAFile != "" {
+			return config, fmt.Errorf("TLS_CERT_FILE environment variable not set")
+		}
+		log.Printf("TLS_CERT_FILE is not set, gRPC, REST and gateway traffic is not encrypted")
+		return config, nil
+	}
+
+	if interval := os.Getenv("TLS_RELOAD_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			return config, fmt.Errorf("invalid TLS_RELOAD_INTERVAL: %q", interval)
+		}
+		config.reloadInterval = d
+	}
+
+	if path := os.Getenv("TLS_CLIENT_POLICY_PATH"); path != "" {
+		if config.mtls.ClientCAFile == "" {
+			return config, fmt.Errorf("TLS_CLIENT_CA_FILE environment variable not set")
+		}
+		policy, err := mtls.LoadPolicy(path)
+		if err != nil {
+			return config, fmt.Errorf("failed to load TLS client policy: %w", err)
+		}
+		config.clientPolicy = policy
+	}
+	return config, nil
 }
 
 // initDatabase initializes the database connection
-func initDatabase(dbURL string) (*database.Queries, *sql.DB, error) {
+func initDatabase(dbURL string) (*database.Store, *sql.DB, error) {
 	dbConn, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		return nil, nil, err
 	}
-	dbQueries := database.New(dbConn)
+	dbQueries := database.NewStore(dbConn)
 	return dbQueries, dbConn, nil
 }
 
-// initRabbitMQ initializes the RabbitMQ client
-func initRabbitMQ(rabbitmqURL string) (*rabbitmq.RabbitMQ, error) {
-	return rabbitmq.NewRabbitMQ(rabbitmqURL)
-}
-
 // initFirebase initializes the Firebase client
 func initFirebase(ctx context.Context, keyPath string) (*firebase.Client, error) {
 	return firebase.InitFirebase(ctx, keyPath)
 }
 
-// startServer initializes and starts the gRPC server
-func startServer(lis net.Listener, srv *server.Server) {
-	grpcServer := grpc.NewServer()
+// security is the transport security and the interceptors authorizing and validating calls, shared
+// by the gRPC server and the REST gateway
+type security struct {
+	tls *tls.Config
+	// browserTLS serves the same certificates to the browser and REST gateways without asking for
+	// client certificates
+	browserTLS *tls.Config
+	// certUnary and certStream check client certificates, only gRPC callers have them
+	certUnary  []grpc.UnaryServerInterceptor
+	certStream []grpc.StreamServerInterceptor
+	// unary and stream authenticate and validate calls on gRPC and REST
+	unary  []grpc.UnaryServerInterceptor
+	stream []grpc.StreamServerInterceptor
+}
+
+// loadSecurity starts reloading the TLS certificates and builds the interceptors, client
+// certificates are checked before bearer tokens and requests are validated once the caller is known
+func loadSecurity(config *Config) (security, error) {
+	var sec security
+
+	if config.tls.mtls.CertFile != "" {
+		reloader, err := mtls.NewReloader(config.tls.mtls)
+		if err != nil {
+			return sec, err
+		}
+		go reloader.Watch(context.Background(), config.tls.reloadInterval)
+		sec.tls = reloader.ServerConfig()
+		sec.browserTLS = reloader.BrowserConfig()
+	}
+
+	if config.tls.clientPolicy != nil {
+		sec.certUnary = append(sec.certUnary, config.tls.clientPolicy.UnaryInterceptor())
+		sec.certStream = append(sec.certStream, config.tls.clientPolicy.StreamInterceptor())
+	}
+
+	if config.authenticator != nil {
+		sec.unary = append(sec.unary, config.authenticator.UnaryInterceptor())
+		sec.stream = append(sec.stream, config.authenticator.StreamInterceptor())
+	}
+
+	validator, err := validation.New()
+	if err != nil {
+		return sec, err
+	}
+	sec.unary = append(sec.unary, validator.UnaryInterceptor())
+	sec.stream = append(sec.stream, validator.StreamInterceptor())
+	return sec, nil
+}
+
+// serverOptions applies the security settings to the gRPC server
+func serverOptions(sec security) []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(append(sec.certUnary, sec.unary...)...),
+		grpc.ChainStreamInterceptor(append(sec.certStream, sec.stream...)...),
+	}
+	if sec.tls != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(sec.tls)))
+	}
+	return opts
+}
+
+// serveREST serves the HTTP/JSON gateway to browsers with the server certificates, calls are
+// authenticated by their bearer token and validated like gRPC calls. Client certificates are left
+// to the gRPC port.
+func serveREST(addr string, srv *server.Server, sec security) {
+	httpServer := rest.NewHTTPServer(addr, rest.NewGateway(srv,
+		rest.WithUnaryInterceptors(sec.unary...),
+		rest.WithStreamInterceptors(sec.stream...),
+	))
+	httpServer.TLSConfig = sec.browserTLS
+
+	log.Printf("REST gateway listening on %v", addr)
+	var err error
+	if sec.browserTLS != nil {
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	log.Fatalf("Failed to serve REST gateway: %v", err)
+}
+
+// serveGateway serves live notifications to browsers with the same certificates as gRPC and the
+// users authenticated like gRPC calls
+func serveGateway(srv *server.Server, config *Config, sec security) {
+	httpServer := gateway.NewHTTPServer(config.httpPort, gateway.NewGateway(srv.Hub(), config.authenticator,
+		gateway.WithAllowedOrigins(config.allowedOrigins...),
+	))
+	httpServer.TLSConfig = sec.browserTLS
+
+	log.Printf("Gateway listening on %v", config.httpPort)
+	var err error
+	if sec.browserTLS != nil {
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	log.Fatalf("Failed to serve gateway: %v", err)
+}
+
+// startServer initializes and starts the gRPC server and, if configured, the HTTP and REST gateways
+func startServer(lis net.Listener, srv *server.Server, config *Config) {
+	sec, err := loadSecurity(config)
+	if err != nil {
+		log.Fatalf("Failed to configure gRPC server: %v", err)
+	}
+	grpcServer := grpc.NewServer(serverOptions(sec)...)
 	pb.RegisterNotificationServiceServer(grpcServer, srv)
 	reflection.Register(grpcServer)
 
 	log.Printf("Server listening on %v", lis.Addr())
 
-	// Start consuming messages from notification-queue
-	go srv.Consume()
+	// Start consuming notification requests from the broker
+	go func() {
+		if err := srv.Consume(context.Background()); err != nil {
+			log.Fatalf("Failed to consume notification requests: %v", err)
+		}
+	}()
+
+	// Serve live notifications to browsers over WebSocket and SSE
+	if config.httpPort != "" {
+		go serveGateway(srv, config, sec)
+	}
+
+	// Serve the RPCs as HTTP/JSON for clients without gRPC
+	if config.restPort != "" {
+		go serveREST(config.restPort, srv, sec)
+	}
 
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve: %v", err)
@@ -0,0 +1,188 @@
+// Package producer publishes notification requests to the notification service over RabbitMQ.
+// Other services import it instead of hand-rolling AMQP publishing, so every request is
+// persistent, routable and confirmed by the broker before Send returns.
+package producer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/codec"
+	"github.com/imhasandl/notification-service/internal/rabbitmq"
+	"github.com/imhasandl/notification-service/internal/signing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// ExchangeName is the exchange the notification service consumes requests from
+	ExchangeName = rabbitmq.ExchangeName
+	// Schema is the payload schema the producer writes, sent in the Schema header
+	Schema = "notification.v2"
+	// SchemaVersion is the version of Schema, written to the schema_version field of the payload
+	SchemaVersion = 2
+)
+
+var (
+	// ErrNoReceiver is returned for a notification without a receiver
+	ErrNoReceiver = errors.New("producer: notification has no receiver")
+	// ErrUnroutable is returned when no queue is bound to the exchange, e.g. the service was never started
+	ErrUnroutable = rabbitmq.ErrUnroutable
+	// ErrNotConfirmed is returned when the broker rejects the request
+	ErrNotConfirmed = rabbitmq.ErrNotConfirmed
+)
+
+// Translation is a localized title and content
+type Translation struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// Notification is a request to notify one user, the body of the queue message
+type Notification struct {
+	ReceiverID     uuid.UUID `json:"receiver_id"`
+	Title          string    `json:"title"`
+	Content        string    `json:"content"`
+	SenderUsername string    `json:"sender_username"`
+	SentAt         time.Time `json:"sent_at"`
+	// Category picks the channel routing, notifications without one use the default chain
+	Category string `json:"category,omitempty"`
+	// MinAppVersion skips push to devices running an older app version
+	MinAppVersion string `json:"min_app_version,omitempty"`
+	// Translations holds localized title and content keyed by locale, e.g. "es" or "pt-BR"
+	Translations map[string]Translation `json:"translations,omitempty"`
+}
+
+// RoutingKey returns the routing key for notifications of the category
+func RoutingKey(category string) string {
+	if category == "" {
+		category = "default"
+	}
+	return "notification." + category
+}
+
+// Message builds the AMQP message for a notification. The correlation id is copied to the
+// delivery status events the service publishes about it, leave it empty if you don't need them.
+func Message(notification Notification, correlationID string) (amqp.Publishing, error) {
+	if notification.ReceiverID == uuid.Nil {
+		return amqp.Publishing{}, ErrNoReceiver
+	}
+	if notification.SentAt.IsZero() {
+		notification.SentAt = time.Now().UTC()
+	}
+
+	body, err := json.Marshal(struct {
+		SchemaVersion int `json:"schema_version"`
+		Notification
+	}{SchemaVersion, notification})
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	return amqp.Publishing{
+		Headers:       amqp.Table{codec.SchemaHeader: Schema},
+		ContentType:   codec.ContentTypeJSON,
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     uuid.NewString(),
+		CorrelationId: correlationID,
+		Timestamp:     notification.SentAt,
+		Body:          body,
+	}, nil
+}
+
+// Signer signs the requests of one producer with the key the notification service knows it by
+type Signer = signing.Signer
+
+// NewHMACSigner creates a signer using HMAC-SHA256 with the secret shared with the service
+func NewHMACSigner(producerID string, secret []byte) *Signer {
+	return signing.NewHMACSigner(producerID, secret)
+}
+
+// NewEd25519Signer creates a signer using the producer's Ed25519 private key, the service is
+// configured with the public key
+func NewEd25519Signer(producerID string, key ed25519.PrivateKey) *Signer {
+	return signing.NewEd25519Signer(producerID, key)
+}
+
+// Sign adds the Producer-Id and Signature headers to a message built with Message. Set every
+// other property first, the signature covers the content type and body.
+func Sign(msg *amqp.Publishing, signer *Signer) {
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+	msg.Headers[signing.HeaderProducer] = signer.ProducerID()
+	msg.Headers[signing.HeaderSignature] = signer.Sign(msg.ContentType, msg.Body)
+}
+
+// Option configures a producer
+type Option func(*Producer)
+
+// WithSigner signs every request, required when the service enforces signatures
+func WithSigner(signer *Signer) Option {
+	return func(p *Producer) {
+		p.signer = signer
+	}
+}
+
+// Producer publishes notification requests with publisher confirms and the mandatory flag
+type Producer struct {
+	conn      *amqp.Connection
+	publisher *rabbitmq.Publisher
+	signer    *Signer
+}
+
+// Dial connects to RabbitMQ at the URL, the producer owns the connection and closes it on Close
+func Dial(url string, opts ...Option) (*Producer, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := New(conn, opts...)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	p.conn = conn
+	return p, nil
+}
+
+// New creates a producer publishing on its own channel of an existing connection
+func New(conn *amqp.Connection, opts ...Option) (*Producer, error) {
+	publisher, err := rabbitmq.NewPublisher(conn, rabbitmq.WithMandatory())
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Producer{publisher: publisher}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// Send publishes the notification and returns once the broker has stored it
+func (p *Producer) Send(ctx context.Context, notification Notification, correlationID string) error {
+	msg, err := Message(notification, correlationID)
+	if err != nil {
+		return err
+	}
+	if p.signer != nil {
+		Sign(&msg, p.signer)
+	}
+	return p.publisher.Publish(ctx, ExchangeName, RoutingKey(notification.Category), msg)
+}
+
+// Close closes the publishing channel, and the connection if the producer dialed it
+func (p *Producer) Close() error {
+	err := p.publisher.Close()
+	if p.conn != nil {
+		if closeErr := p.conn.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
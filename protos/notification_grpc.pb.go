@@ -25,6 +25,24 @@ type NotificationServiceClient interface {
 	SendNotification(ctx context.Context, in *SendNotificationRequest, opts ...grpc.CallOption) (*SendNotificationResponse, error)
 	RegisterDeviceToken(ctx context.Context, in *RegisterDeviceTokenRequest, opts ...grpc.CallOption) (*RegisterDeviceTokenResponse, error)
 	DeleteDeviceToken(ctx context.Context, in *DeleteDeviceTokenRequest, opts ...grpc.CallOption) (*DeleteDeviceTokenResponse, error)
+	ListDeviceTokens(ctx context.Context, in *ListDeviceTokensRequest, opts ...grpc.CallOption) (*ListDeviceTokensResponse, error)
+	DeleteAllDeviceTokens(ctx context.Context, in *DeleteAllDeviceTokensRequest, opts ...grpc.CallOption) (*DeleteAllDeviceTokensResponse, error)
+	UpdateDeviceToken(ctx context.Context, in *UpdateDeviceTokenRequest, opts ...grpc.CallOption) (*UpdateDeviceTokenResponse, error)
+	TouchDeviceToken(ctx context.Context, in *TouchDeviceTokenRequest, opts ...grpc.CallOption) (*TouchDeviceTokenResponse, error)
+	SubscribeToTopic(ctx context.Context, in *SubscribeToTopicRequest, opts ...grpc.CallOption) (*SubscribeToTopicResponse, error)
+	UnsubscribeFromTopic(ctx context.Context, in *UnsubscribeFromTopicRequest, opts ...grpc.CallOption) (*UnsubscribeFromTopicResponse, error)
+	SendToTopic(ctx context.Context, in *SendToTopicRequest, opts ...grpc.CallOption) (*SendToTopicResponse, error)
+	SendToSegment(ctx context.Context, in *SendToSegmentRequest, opts ...grpc.CallOption) (NotificationService_SendToSegmentClient, error)
+	CreateCampaign(ctx context.Context, in *CreateCampaignRequest, opts ...grpc.CallOption) (*CreateCampaignResponse, error)
+	StartCampaign(ctx context.Context, in *StartCampaignRequest, opts ...grpc.CallOption) (*StartCampaignResponse, error)
+	PauseCampaign(ctx context.Context, in *PauseCampaignRequest, opts ...grpc.CallOption) (*PauseCampaignResponse, error)
+	CancelCampaign(ctx context.Context, in *CancelCampaignRequest, opts ...grpc.CallOption) (*CancelCampaignResponse, error)
+	GetCampaignStats(ctx context.Context, in *GetCampaignStatsRequest, opts ...grpc.CallOption) (*GetCampaignStatsResponse, error)
+	ReportExperimentEvent(ctx context.Context, in *ReportExperimentEventRequest, opts ...grpc.CallOption) (*ReportExperimentEventResponse, error)
+	GetExperimentStats(ctx context.Context, in *GetExperimentStatsRequest, opts ...grpc.CallOption) (*GetExperimentStatsResponse, error)
+	ReportNotificationOpened(ctx context.Context, in *ReportNotificationOpenedRequest, opts ...grpc.CallOption) (*ReportNotificationOpenedResponse, error)
+	ReportNotificationDismissed(ctx context.Context, in *ReportNotificationDismissedRequest, opts ...grpc.CallOption) (*ReportNotificationDismissedResponse, error)
+	GetEngagementStats(ctx context.Context, in *GetEngagementStatsRequest, opts ...grpc.CallOption) (*GetEngagementStatsResponse, error)
 }
 
 type notificationServiceClient struct {
@@ -62,6 +80,191 @@ func (c *notificationServiceClient) DeleteDeviceToken(ctx context.Context, in *D
 	return out, nil
 }
 
+func (c *notificationServiceClient) ListDeviceTokens(ctx context.Context, in *ListDeviceTokensRequest, opts ...grpc.CallOption) (*ListDeviceTokensResponse, error) {
+	out := new(ListDeviceTokensResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/ListDeviceTokens", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) DeleteAllDeviceTokens(ctx context.Context, in *DeleteAllDeviceTokensRequest, opts ...grpc.CallOption) (*DeleteAllDeviceTokensResponse, error) {
+	out := new(DeleteAllDeviceTokensResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/DeleteAllDeviceTokens", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) UpdateDeviceToken(ctx context.Context, in *UpdateDeviceTokenRequest, opts ...grpc.CallOption) (*UpdateDeviceTokenResponse, error) {
+	out := new(UpdateDeviceTokenResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/UpdateDeviceToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) TouchDeviceToken(ctx context.Context, in *TouchDeviceTokenRequest, opts ...grpc.CallOption) (*TouchDeviceTokenResponse, error) {
+	out := new(TouchDeviceTokenResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/TouchDeviceToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) SubscribeToTopic(ctx context.Context, in *SubscribeToTopicRequest, opts ...grpc.CallOption) (*SubscribeToTopicResponse, error) {
+	out := new(SubscribeToTopicResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/SubscribeToTopic", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) UnsubscribeFromTopic(ctx context.Context, in *UnsubscribeFromTopicRequest, opts ...grpc.CallOption) (*UnsubscribeFromTopicResponse, error) {
+	out := new(UnsubscribeFromTopicResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/UnsubscribeFromTopic", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) SendToTopic(ctx context.Context, in *SendToTopicRequest, opts ...grpc.CallOption) (*SendToTopicResponse, error) {
+	out := new(SendToTopicResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/SendToTopic", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) SendToSegment(ctx context.Context, in *SendToSegmentRequest, opts ...grpc.CallOption) (NotificationService_SendToSegmentClient, error) {
+	stream, err := c.cc.NewStream(ctx, &NotificationService_ServiceDesc.Streams[0], "/notification.NotificationService/SendToSegment", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &notificationServiceSendToSegmentClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type NotificationService_SendToSegmentClient interface {
+	Recv() (*SendToSegmentProgress, error)
+	grpc.ClientStream
+}
+
+type notificationServiceSendToSegmentClient struct {
+	grpc.ClientStream
+}
+
+func (x *notificationServiceSendToSegmentClient) Recv() (*SendToSegmentProgress, error) {
+	m := new(SendToSegmentProgress)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *notificationServiceClient) CreateCampaign(ctx context.Context, in *CreateCampaignRequest, opts ...grpc.CallOption) (*CreateCampaignResponse, error) {
+	out := new(CreateCampaignResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/CreateCampaign", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) StartCampaign(ctx context.Context, in *StartCampaignRequest, opts ...grpc.CallOption) (*StartCampaignResponse, error) {
+	out := new(StartCampaignResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/StartCampaign", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) PauseCampaign(ctx context.Context, in *PauseCampaignRequest, opts ...grpc.CallOption) (*PauseCampaignResponse, error) {
+	out := new(PauseCampaignResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/PauseCampaign", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) CancelCampaign(ctx context.Context, in *CancelCampaignRequest, opts ...grpc.CallOption) (*CancelCampaignResponse, error) {
+	out := new(CancelCampaignResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/CancelCampaign", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) GetCampaignStats(ctx context.Context, in *GetCampaignStatsRequest, opts ...grpc.CallOption) (*GetCampaignStatsResponse, error) {
+	out := new(GetCampaignStatsResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/GetCampaignStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) ReportExperimentEvent(ctx context.Context, in *ReportExperimentEventRequest, opts ...grpc.CallOption) (*ReportExperimentEventResponse, error) {
+	out := new(ReportExperimentEventResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/ReportExperimentEvent", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) GetExperimentStats(ctx context.Context, in *GetExperimentStatsRequest, opts ...grpc.CallOption) (*GetExperimentStatsResponse, error) {
+	out := new(GetExperimentStatsResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/GetExperimentStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) ReportNotificationOpened(ctx context.Context, in *ReportNotificationOpenedRequest, opts ...grpc.CallOption) (*ReportNotificationOpenedResponse, error) {
+	out := new(ReportNotificationOpenedResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/ReportNotificationOpened", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) ReportNotificationDismissed(ctx context.Context, in *ReportNotificationDismissedRequest, opts ...grpc.CallOption) (*ReportNotificationDismissedResponse, error) {
+	out := new(ReportNotificationDismissedResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/ReportNotificationDismissed", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) GetEngagementStats(ctx context.Context, in *GetEngagementStatsRequest, opts ...grpc.CallOption) (*GetEngagementStatsResponse, error) {
+	out := new(GetEngagementStatsResponse)
+	err := c.cc.Invoke(ctx, "/notification.NotificationService/GetEngagementStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // NotificationServiceServer is the server API for NotificationService service.
 // All implementations must embed UnimplementedNotificationServiceServer
 // for forward compatibility
@@ -69,6 +272,24 @@ type NotificationServiceServer interface {
 	SendNotification(context.Context, *SendNotificationRequest) (*SendNotificationResponse, error)
 	RegisterDeviceToken(context.Context, *RegisterDeviceTokenRequest) (*RegisterDeviceTokenResponse, error)
 	DeleteDeviceToken(context.Context, *DeleteDeviceTokenRequest) (*DeleteDeviceTokenResponse, error)
+	ListDeviceTokens(context.Context, *ListDeviceTokensRequest) (*ListDeviceTokensResponse, error)
+	DeleteAllDeviceTokens(context.Context, *DeleteAllDeviceTokensRequest) (*DeleteAllDeviceTokensResponse, error)
+	UpdateDeviceToken(context.Context, *UpdateDeviceTokenRequest) (*UpdateDeviceTokenResponse, error)
+	TouchDeviceToken(context.Context, *TouchDeviceTokenRequest) (*TouchDeviceTokenResponse, error)
+	SubscribeToTopic(context.Context, *SubscribeToTopicRequest) (*SubscribeToTopicResponse, error)
+	UnsubscribeFromTopic(context.Context, *UnsubscribeFromTopicRequest) (*UnsubscribeFromTopicResponse, error)
+	SendToTopic(context.Context, *SendToTopicRequest) (*SendToTopicResponse, error)
+	SendToSegment(*SendToSegmentRequest, NotificationService_SendToSegmentServer) error
+	CreateCampaign(context.Context, *CreateCampaignRequest) (*CreateCampaignResponse, error)
+	StartCampaign(context.Context, *StartCampaignRequest) (*StartCampaignResponse, error)
+	PauseCampaign(context.Context, *PauseCampaignRequest) (*PauseCampaignResponse, error)
+	CancelCampaign(context.Context, *CancelCampaignRequest) (*CancelCampaignResponse, error)
+	GetCampaignStats(context.Context, *GetCampaignStatsRequest) (*GetCampaignStatsResponse, error)
+	ReportExperimentEvent(context.Context, *ReportExperimentEventRequest) (*ReportExperimentEventResponse, error)
+	GetExperimentStats(context.Context, *GetExperimentStatsRequest) (*GetExperimentStatsResponse, error)
+	ReportNotificationOpened(context.Context, *ReportNotificationOpenedRequest) (*ReportNotificationOpenedResponse, error)
+	ReportNotificationDismissed(context.Context, *ReportNotificationDismissedRequest) (*ReportNotificationDismissedResponse, error)
+	GetEngagementStats(context.Context, *GetEngagementStatsRequest) (*GetEngagementStatsResponse, error)
 	mustEmbedUnimplementedNotificationServiceServer()
 }
 
@@ -85,6 +306,60 @@ func (UnimplementedNotificationServiceServer) RegisterDeviceToken(context.Contex
 func (UnimplementedNotificationServiceServer) DeleteDeviceToken(context.Context, *DeleteDeviceTokenRequest) (*DeleteDeviceTokenResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteDeviceToken not implemented")
 }
+func (UnimplementedNotificationServiceServer) ListDeviceTokens(context.Context, *ListDeviceTokensRequest) (*ListDeviceTokensResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeviceTokens not implemented")
+}
+func (UnimplementedNotificationServiceServer) DeleteAllDeviceTokens(context.Context, *DeleteAllDeviceTokensRequest) (*DeleteAllDeviceTokensResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAllDeviceTokens not implemented")
+}
+func (UnimplementedNotificationServiceServer) UpdateDeviceToken(context.Context, *UpdateDeviceTokenRequest) (*UpdateDeviceTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateDeviceToken not implemented")
+}
+func (UnimplementedNotificationServiceServer) TouchDeviceToken(context.Context, *TouchDeviceTokenRequest) (*TouchDeviceTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TouchDeviceToken not implemented")
+}
+func (UnimplementedNotificationServiceServer) SubscribeToTopic(context.Context, *SubscribeToTopicRequest) (*SubscribeToTopicResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubscribeToTopic not implemented")
+}
+func (UnimplementedNotificationServiceServer) UnsubscribeFromTopic(context.Context, *UnsubscribeFromTopicRequest) (*UnsubscribeFromTopicResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnsubscribeFromTopic not implemented")
+}
+func (UnimplementedNotificationServiceServer) SendToTopic(context.Context, *SendToTopicRequest) (*SendToTopicResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendToTopic not implemented")
+}
+func (UnimplementedNotificationServiceServer) SendToSegment(*SendToSegmentRequest, NotificationService_SendToSegmentServer) error {
+	return status.Errorf(codes.Unimplemented, "method SendToSegment not implemented")
+}
+func (UnimplementedNotificationServiceServer) CreateCampaign(context.Context, *CreateCampaignRequest) (*CreateCampaignResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCampaign not implemented")
+}
+func (UnimplementedNotificationServiceServer) StartCampaign(context.Context, *StartCampaignRequest) (*StartCampaignResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartCampaign not implemented")
+}
+func (UnimplementedNotificationServiceServer) PauseCampaign(context.Context, *PauseCampaignRequest) (*PauseCampaignResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PauseCampaign not implemented")
+}
+func (UnimplementedNotificationServiceServer) CancelCampaign(context.Context, *CancelCampaignRequest) (*CancelCampaignResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelCampaign not implemented")
+}
+func (UnimplementedNotificationServiceServer) GetCampaignStats(context.Context, *GetCampaignStatsRequest) (*GetCampaignStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCampaignStats not implemented")
+}
+func (UnimplementedNotificationServiceServer) ReportExperimentEvent(context.Context, *ReportExperimentEventRequest) (*ReportExperimentEventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportExperimentEvent not implemented")
+}
+func (UnimplementedNotificationServiceServer) GetExperimentStats(context.Context, *GetExperimentStatsRequest) (*GetExperimentStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetExperimentStats not implemented")
+}
+func (UnimplementedNotificationServiceServer) ReportNotificationOpened(context.Context, *ReportNotificationOpenedRequest) (*ReportNotificationOpenedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportNotificationOpened not implemented")
+}
+func (UnimplementedNotificationServiceServer) ReportNotificationDismissed(context.Context, *ReportNotificationDismissedRequest) (*ReportNotificationDismissedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportNotificationDismissed not implemented")
+}
+func (UnimplementedNotificationServiceServer) GetEngagementStats(context.Context, *GetEngagementStatsRequest) (*GetEngagementStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEngagementStats not implemented")
+}
 func (UnimplementedNotificationServiceServer) mustEmbedUnimplementedNotificationServiceServer() {}
 
 // UnsafeNotificationServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -152,6 +427,333 @@ func _NotificationService_DeleteDeviceToken_Handler(srv interface{}, ctx context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NotificationService_ListDeviceTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeviceTokensRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).ListDeviceTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/ListDeviceTokens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).ListDeviceTokens(ctx, req.(*ListDeviceTokensRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_DeleteAllDeviceTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAllDeviceTokensRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).DeleteAllDeviceTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/DeleteAllDeviceTokens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).DeleteAllDeviceTokens(ctx, req.(*DeleteAllDeviceTokensRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_UpdateDeviceToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateDeviceTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).UpdateDeviceToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/UpdateDeviceToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).UpdateDeviceToken(ctx, req.(*UpdateDeviceTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_TouchDeviceToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TouchDeviceTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).TouchDeviceToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/TouchDeviceToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).TouchDeviceToken(ctx, req.(*TouchDeviceTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_SubscribeToTopic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubscribeToTopicRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).SubscribeToTopic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/SubscribeToTopic",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).SubscribeToTopic(ctx, req.(*SubscribeToTopicRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_UnsubscribeFromTopic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnsubscribeFromTopicRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).UnsubscribeFromTopic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/UnsubscribeFromTopic",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).UnsubscribeFromTopic(ctx, req.(*UnsubscribeFromTopicRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_SendToTopic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendToTopicRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).SendToTopic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/SendToTopic",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).SendToTopic(ctx, req.(*SendToTopicRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_SendToSegment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SendToSegmentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NotificationServiceServer).SendToSegment(m, &notificationServiceSendToSegmentServer{stream})
+}
+
+type NotificationService_SendToSegmentServer interface {
+	Send(*SendToSegmentProgress) error
+	grpc.ServerStream
+}
+
+type notificationServiceSendToSegmentServer struct {
+	grpc.ServerStream
+}
+
+func (x *notificationServiceSendToSegmentServer) Send(m *SendToSegmentProgress) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _NotificationService_CreateCampaign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCampaignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).CreateCampaign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/CreateCampaign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).CreateCampaign(ctx, req.(*CreateCampaignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_StartCampaign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartCampaignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).StartCampaign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/StartCampaign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).StartCampaign(ctx, req.(*StartCampaignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_PauseCampaign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseCampaignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).PauseCampaign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/PauseCampaign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).PauseCampaign(ctx, req.(*PauseCampaignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_CancelCampaign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelCampaignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).CancelCampaign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/CancelCampaign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).CancelCampaign(ctx, req.(*CancelCampaignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_GetCampaignStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCampaignStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).GetCampaignStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/GetCampaignStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).GetCampaignStats(ctx, req.(*GetCampaignStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_ReportExperimentEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportExperimentEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).ReportExperimentEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/ReportExperimentEvent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).ReportExperimentEvent(ctx, req.(*ReportExperimentEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_GetExperimentStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetExperimentStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).GetExperimentStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/GetExperimentStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).GetExperimentStats(ctx, req.(*GetExperimentStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_ReportNotificationOpened_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportNotificationOpenedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).ReportNotificationOpened(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/ReportNotificationOpened",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).ReportNotificationOpened(ctx, req.(*ReportNotificationOpenedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_ReportNotificationDismissed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportNotificationDismissedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).ReportNotificationDismissed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/ReportNotificationDismissed",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).ReportNotificationDismissed(ctx, req.(*ReportNotificationDismissedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_GetEngagementStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEngagementStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).GetEngagementStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/notification.NotificationService/GetEngagementStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).GetEngagementStats(ctx, req.(*GetEngagementStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // NotificationService_ServiceDesc is the grpc.ServiceDesc for NotificationService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -171,7 +773,81 @@ var NotificationService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteDeviceToken",
 			Handler:    _NotificationService_DeleteDeviceToken_Handler,
 		},
+		{
+			MethodName: "ListDeviceTokens",
+			Handler:    _NotificationService_ListDeviceTokens_Handler,
+		},
+		{
+			MethodName: "DeleteAllDeviceTokens",
+			Handler:    _NotificationService_DeleteAllDeviceTokens_Handler,
+		},
+		{
+			MethodName: "UpdateDeviceToken",
+			Handler:    _NotificationService_UpdateDeviceToken_Handler,
+		},
+		{
+			MethodName: "TouchDeviceToken",
+			Handler:    _NotificationService_TouchDeviceToken_Handler,
+		},
+		{
+			MethodName: "SubscribeToTopic",
+			Handler:    _NotificationService_SubscribeToTopic_Handler,
+		},
+		{
+			MethodName: "UnsubscribeFromTopic",
+			Handler:    _NotificationService_UnsubscribeFromTopic_Handler,
+		},
+		{
+			MethodName: "SendToTopic",
+			Handler:    _NotificationService_SendToTopic_Handler,
+		},
+		{
+			MethodName: "CreateCampaign",
+			Handler:    _NotificationService_CreateCampaign_Handler,
+		},
+		{
+			MethodName: "StartCampaign",
+			Handler:    _NotificationService_StartCampaign_Handler,
+		},
+		{
+			MethodName: "PauseCampaign",
+			Handler:    _NotificationService_PauseCampaign_Handler,
+		},
+		{
+			MethodName: "CancelCampaign",
+			Handler:    _NotificationService_CancelCampaign_Handler,
+		},
+		{
+			MethodName: "GetCampaignStats",
+			Handler:    _NotificationService_GetCampaignStats_Handler,
+		},
+		{
+			MethodName: "ReportExperimentEvent",
+			Handler:    _NotificationService_ReportExperimentEvent_Handler,
+		},
+		{
+			MethodName: "GetExperimentStats",
+			Handler:    _NotificationService_GetExperimentStats_Handler,
+		},
+		{
+			MethodName: "ReportNotificationOpened",
+			Handler:    _NotificationService_ReportNotificationOpened_Handler,
+		},
+		{
+			MethodName: "ReportNotificationDismissed",
+			Handler:    _NotificationService_ReportNotificationDismissed_Handler,
+		},
+		{
+			MethodName: "GetEngagementStats",
+			Handler:    _NotificationService_GetEngagementStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SendToSegment",
+			Handler:       _NotificationService_SendToSegment_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "notification.proto",
 }
@@ -0,0 +1,56 @@
+-- name: CreateCampaign :one
+INSERT INTO campaigns(id, name, notification, segment, scheduled_at, rate_per_second, batch_size, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+RETURNING *;
+
+-- name: GetCampaign :one
+SELECT * FROM campaigns
+WHERE id = $1;
+
+-- name: StartCampaign :one
+UPDATE campaigns
+SET status = CASE WHEN scheduled_at > NOW() THEN 'scheduled' ELSE 'running' END,
+    lease_id = NULL, leased_until = NULL, updated_at = NOW()
+WHERE id = $1 AND status IN ('draft', 'paused')
+RETURNING *;
+
+-- name: PauseCampaign :one
+UPDATE campaigns
+SET status = 'paused', lease_id = NULL, leased_until = NULL, updated_at = NOW()
+WHERE id = $1 AND status IN ('scheduled', 'running')
+RETURNING *;
+
+-- name: CancelCampaign :one
+UPDATE campaigns
+SET status = 'cancelled', lease_id = NULL, leased_until = NULL, completed_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status IN ('draft', 'scheduled', 'running', 'paused')
+RETURNING *;
+
+-- name: ClaimCampaign :one
+UPDATE campaigns
+SET status = 'running', lease_id = $1, leased_until = $2,
+    started_at = COALESCE(started_at, NOW()),
+    segment_evaluated_at = COALESCE(segment_evaluated_at, NOW()),
+    updated_at = NOW()
+WHERE id = (
+    SELECT due.id FROM campaigns AS due
+    WHERE (due.status = 'scheduled' AND due.scheduled_at <= NOW())
+       OR (due.status = 'running' AND (due.leased_until IS NULL OR due.leased_until < NOW()))
+    ORDER BY COALESCE(due.scheduled_at, due.created_at)
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CheckpointCampaign :one
+UPDATE campaigns
+SET total = $3, processed = $4, sent = $5, skipped = $6, failed = $7,
+    last_user_id = $8, leased_until = $9, updated_at = NOW()
+WHERE id = $1 AND lease_id = $2 AND status = 'running'
+RETURNING *;
+
+-- name: FinishCampaign :exec
+UPDATE campaigns
+SET status = $3, last_error = $4, lease_id = NULL, leased_until = NULL,
+    completed_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND lease_id = $2 AND status = 'running';
@@ -1,14 +1,81 @@
 -- name: RegisterDeviceToken :one
-INSERT INTO device_tokens(id, user_id, device_token, device_type, created_at, updated_at)
-VALUES ($1, $2, $3, $4, NOW(), NOW())
-ON CONFLICT (user_id, device_token)
-DO UPDATE SET updated_at = NOW(), device_type = $3
+INSERT INTO device_tokens(id, user_id, device_token, device_type, app_version, os_version, locale, timezone, device_model, push_permission, created_at, updated_at, last_seen_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW(), NOW())
+ON CONFLICT (device_token) DO NOTHING
 RETURNING *;
 
+-- name: LockDeviceToken :one
+SELECT * FROM device_tokens
+WHERE device_token = $1
+FOR UPDATE;
+
+-- name: ClaimDeviceToken :one
+UPDATE device_tokens
+SET user_id = $2, device_type = $3, app_version = $4, os_version = $5, locale = $6,
+    timezone = $7, device_model = $8, push_permission = $9, updated_at = NOW(),
+    last_seen_at = NOW(), disabled_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: CreateDeviceTokenTransfer :exec
+INSERT INTO device_token_transfers(id, device_token, from_user_id, to_user_id, transferred_at)
+VALUES ($1, $2, $3, $4, NOW());
+
 -- name: GetDeviceTokensByUserID :one
-SELECT device_token FROM device_tokens
-WHERE user_id = $1;
+-- Pushes go to the most recently seen device, the one eviction keeps longest
+SELECT * FROM device_tokens
+WHERE user_id = $1 AND disabled_at IS NULL
+ORDER BY last_seen_at DESC NULLS LAST, updated_at DESC
+LIMIT 1;
 
 -- name: DeleteDeviceToken :exec
 DELETE FROM device_tokens
-WHERE user_id = $1 AND device_token = $2;
\ No newline at end of file
+WHERE user_id = $1 AND device_token = $2;
+
+-- name: ListDeviceTokensByUserID :many
+SELECT * FROM device_tokens
+WHERE user_id = $1
+ORDER BY updated_at DESC;
+
+-- name: DeleteAllDeviceTokens :many
+DELETE FROM device_tokens
+WHERE user_id = $1
+RETURNING device_token;
+
+-- name: UpdateDeviceToken :one
+UPDATE device_tokens
+SET device_token = $3, device_type = $4, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: TouchDeviceToken :one
+UPDATE device_tokens
+SET last_seen_at = NOW(), disabled_at = NULL
+WHERE user_id = $1 AND device_token = $2
+RETURNING *;
+
+-- name: CountStaleDeviceTokens :one
+SELECT COUNT(*) FILTER (WHERE disabled_at IS NULL) AS active, COUNT(*) AS total
+FROM device_tokens
+WHERE last_seen_at < $1;
+
+-- name: DisableStaleDeviceTokens :execrows
+UPDATE device_tokens
+SET disabled_at = NOW()
+WHERE last_seen_at < $1 AND disabled_at IS NULL;
+
+-- name: DeleteStaleDeviceTokens :many
+DELETE FROM device_tokens
+WHERE last_seen_at < $1
+RETURNING user_id, device_token;
+
+-- name: EvictDeviceTokens :many
+DELETE FROM device_tokens
+WHERE id IN (
+    SELECT evictable.id FROM device_tokens AS evictable
+    WHERE evictable.user_id = $1 AND evictable.id <> $2 AND evictable.disabled_at IS NULL
+    ORDER BY evictable.last_seen_at DESC, evictable.created_at DESC
+    OFFSET $3
+    FOR UPDATE
+)
+RETURNING *;
@@ -0,0 +1,25 @@
+-- name: CreateExperimentAssignment :exec
+INSERT INTO experiment_assignments(message_id, experiment, variant, user_id, sent_at)
+VALUES ($1, $2, $3, $4, NOW())
+ON CONFLICT (message_id) DO NOTHING;
+
+-- name: RecordExperimentOpen :execrows
+UPDATE experiment_assignments
+SET opened_at = COALESCE(opened_at, NOW())
+WHERE message_id = $1 AND user_id = $2;
+
+-- name: RecordExperimentClick :execrows
+-- A click implies the notification was opened
+UPDATE experiment_assignments
+SET clicked_at = COALESCE(clicked_at, NOW()), opened_at = COALESCE(opened_at, NOW())
+WHERE message_id = $1 AND user_id = $2;
+
+-- name: GetExperimentStats :many
+SELECT variant,
+       COUNT(*)::BIGINT AS sent,
+       COUNT(opened_at)::BIGINT AS opened,
+       COUNT(clicked_at)::BIGINT AS clicked
+FROM experiment_assignments
+WHERE experiment = $1
+GROUP BY variant
+ORDER BY variant;
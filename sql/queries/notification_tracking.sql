@@ -0,0 +1,33 @@
+-- name: CreateNotificationTracking :exec
+INSERT INTO notification_tracking(id, user_id, category, correlation_id, delivered_at)
+VALUES ($1, $2, $3, $4, NOW())
+ON CONFLICT (id) DO NOTHING;
+
+-- name: MarkNotificationOpened :one
+-- NOW() is fixed for the transaction, so first_open is only true for the report that set opened_at
+UPDATE notification_tracking
+SET opened_at = COALESCE(opened_at, NOW())
+WHERE id = $1 AND user_id = $2
+RETURNING *, (opened_at = NOW())::BOOLEAN AS first_open;
+
+-- name: MarkNotificationDismissed :execrows
+UPDATE notification_tracking
+SET dismissed_at = COALESCE(dismissed_at, NOW())
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetEngagementByCategory :many
+SELECT category,
+       COUNT(*)::BIGINT AS delivered,
+       COUNT(opened_at)::BIGINT AS opened,
+       COUNT(dismissed_at)::BIGINT AS dismissed
+FROM notification_tracking
+WHERE delivered_at >= $1
+GROUP BY category
+ORDER BY category;
+
+-- name: IsNotificationSeen :one
+-- A notification is seen once the app reported opening or dismissing it
+SELECT EXISTS (
+    SELECT 1 FROM notification_tracking
+    WHERE id = $1 AND (opened_at IS NOT NULL OR dismissed_at IS NOT NULL)
+)::BOOLEAN;
@@ -0,0 +1,31 @@
+-- name: CreateOutboxMessage :exec
+INSERT INTO outbox(id, exchange, routing_key, correlation_id, body, created_at)
+VALUES ($1, $2, $3, $4, $5, NOW());
+
+-- name: ListPendingOutboxMessages :many
+-- Locks the batch so several relays can run without publishing a row twice.
+-- Messages waiting out a backoff or parked after too many failures are left alone.
+SELECT * FROM outbox
+WHERE published_at IS NULL AND parked_at IS NULL AND next_attempt_at <= NOW()
+ORDER BY created_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxMessagePublished :exec
+UPDATE outbox
+SET published_at = NOW(), attempts = attempts + 1
+WHERE id = $1;
+
+-- name: RecordOutboxFailure :exec
+UPDATE outbox
+SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+WHERE id = $1;
+
+-- name: ParkOutboxMessage :exec
+UPDATE outbox
+SET attempts = attempts + 1, last_error = $2, parked_at = NOW()
+WHERE id = $1;
+
+-- name: DeletePublishedOutboxMessages :execrows
+DELETE FROM outbox
+WHERE published_at < $1;
@@ -0,0 +1,21 @@
+-- name: CreateRoutingFallback :exec
+INSERT INTO routing_fallbacks(id, message_id, channel, message, due_at, created_at)
+VALUES ($1, $2, $3, $4, $5, NOW())
+ON CONFLICT (id) DO NOTHING;
+
+-- name: ListDueRoutingFallbacks :many
+-- Locks the batch so several instances can run fallbacks without sending one twice
+SELECT * FROM routing_fallbacks
+WHERE due_at <= $1
+ORDER BY due_at
+LIMIT $2
+FOR UPDATE SKIP LOCKED;
+
+-- name: DeleteRoutingFallback :exec
+DELETE FROM routing_fallbacks
+WHERE id = $1;
+
+-- name: RetryRoutingFallback :exec
+UPDATE routing_fallbacks
+SET attempts = attempts + 1, last_error = $2, due_at = $3
+WHERE id = $1;
@@ -0,0 +1,13 @@
+-- name: CreateTopicSubscription :exec
+INSERT INTO topic_subscriptions(id, user_id, topic, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, topic) DO NOTHING;
+
+-- name: DeleteTopicSubscription :exec
+DELETE FROM topic_subscriptions
+WHERE user_id = $1 AND topic = $2;
+
+-- name: ListTopicsByUserID :many
+SELECT topic FROM topic_subscriptions
+WHERE user_id = $1
+ORDER BY topic;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- A device token can only belong to one user, keep the most recent registration of each token
+DELETE FROM device_tokens a
+USING device_tokens b
+WHERE a.device_token = b.device_token
+  AND (a.updated_at, a.id) < (b.updated_at, b.id);
+
+ALTER TABLE device_tokens ADD CONSTRAINT device_tokens_device_token_key UNIQUE (device_token);
+
+CREATE TABLE device_token_transfers (
+    id UUID PRIMARY KEY,
+    device_token TEXT NOT NULL,
+    from_user_id UUID NOT NULL,
+    to_user_id UUID NOT NULL,
+    transferred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_device_token_transfers_from_user_id ON device_token_transfers(from_user_id);
+CREATE INDEX idx_device_token_transfers_to_user_id ON device_token_transfers(to_user_id);
+
+-- +goose Down
+DROP TABLE device_token_transfers;
+ALTER TABLE device_tokens DROP CONSTRAINT device_tokens_device_token_key;
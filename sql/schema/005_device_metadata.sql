@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE device_tokens
+    ADD COLUMN app_version TEXT NOT NULL DEFAULT '',
+    ADD COLUMN os_version TEXT NOT NULL DEFAULT '',
+    ADD COLUMN locale TEXT NOT NULL DEFAULT '',
+    ADD COLUMN timezone TEXT NOT NULL DEFAULT '',
+    ADD COLUMN device_model TEXT NOT NULL DEFAULT '',
+    ADD COLUMN push_permission TEXT NOT NULL DEFAULT 'unknown'; -- e.g., 'granted', 'denied', 'provisional', 'unknown'
+
+-- +goose Down
+ALTER TABLE device_tokens
+    DROP COLUMN app_version,
+    DROP COLUMN os_version,
+    DROP COLUMN locale,
+    DROP COLUMN timezone,
+    DROP COLUMN device_model,
+    DROP COLUMN push_permission;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE device_tokens
+    ADD COLUMN last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN disabled_at TIMESTAMP;
+
+-- Devices registered before heartbeats existed were last seen when they last registered
+UPDATE device_tokens SET last_seen_at = updated_at;
+
+CREATE INDEX idx_device_tokens_last_seen_at ON device_tokens(last_seen_at);
+
+-- +goose Down
+DROP INDEX idx_device_tokens_last_seen_at;
+ALTER TABLE device_tokens
+    DROP COLUMN last_seen_at,
+    DROP COLUMN disabled_at;
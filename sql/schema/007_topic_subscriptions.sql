@@ -0,0 +1,16 @@
+-- +goose Up
+-- FCM keeps topic subscriptions per device token, this is the per-user record used to
+-- subscribe devices registered later and to unsubscribe devices that are removed
+CREATE TABLE topic_subscriptions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    topic TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, topic)
+);
+
+CREATE INDEX idx_topic_subscriptions_topic ON topic_subscriptions(topic);
+
+-- +goose Down
+DROP INDEX idx_topic_subscriptions_topic;
+DROP TABLE topic_subscriptions;
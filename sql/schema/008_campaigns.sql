@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE campaigns (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    notification JSONB NOT NULL,
+    segment JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'draft', -- draft, scheduled, running, paused, completed, cancelled, failed
+    scheduled_at TIMESTAMP,
+    rate_per_second INT NOT NULL,
+    batch_size INT NOT NULL,
+    -- Progress checkpoint, users are sent to in id order so last_user_id is where a run resumes
+    total BIGINT NOT NULL DEFAULT 0,
+    processed BIGINT NOT NULL DEFAULT 0,
+    sent BIGINT NOT NULL DEFAULT 0,
+    skipped BIGINT NOT NULL DEFAULT 0,
+    failed BIGINT NOT NULL DEFAULT 0,
+    last_user_id UUID,
+    -- Relative segment filters are evaluated against this time so resumed runs match the same users
+    segment_evaluated_at TIMESTAMP,
+    -- The instance running the campaign holds a lease and renews it at every checkpoint
+    lease_id UUID,
+    leased_until TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX idx_campaigns_status ON campaigns(status);
+
+-- +goose Down
+DROP INDEX idx_campaigns_status;
+DROP TABLE campaigns;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- One row per delivered message of an A/B experiment, recording the variant the user got
+-- and whether they opened or clicked it
+CREATE TABLE experiment_assignments (
+    message_id UUID PRIMARY KEY,
+    experiment TEXT NOT NULL,
+    variant TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    sent_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    opened_at TIMESTAMP,
+    clicked_at TIMESTAMP
+);
+
+CREATE INDEX idx_experiment_assignments_experiment ON experiment_assignments(experiment, variant);
+
+-- +goose Down
+DROP INDEX idx_experiment_assignments_experiment;
+DROP TABLE experiment_assignments;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- One row per delivered notification, keyed by the tracking id sent in the push data.
+-- Apps report when the user opens or dismisses it.
+CREATE TABLE notification_tracking (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category TEXT NOT NULL,
+    delivered_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    opened_at TIMESTAMP,
+    dismissed_at TIMESTAMP
+);
+
+CREATE INDEX idx_notification_tracking_delivered_at ON notification_tracking(delivered_at, category);
+
+-- +goose Down
+DROP INDEX idx_notification_tracking_delivered_at;
+DROP TABLE notification_tracking;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- The correlation id of the request that produced the notification, copied into its later events
+ALTER TABLE notification_tracking ADD COLUMN correlation_id TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE notification_tracking DROP COLUMN correlation_id;
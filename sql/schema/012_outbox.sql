@@ -0,0 +1,20 @@
+-- +goose Up
+-- Messages to publish, written in the same transaction as the state they describe.
+-- The relay publishes pending rows in order and marks them published once the broker confirms.
+CREATE TABLE outbox (
+    id UUID PRIMARY KEY,
+    exchange TEXT NOT NULL,
+    routing_key TEXT NOT NULL,
+    correlation_id TEXT NOT NULL DEFAULT '',
+    body BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_outbox_pending ON outbox(created_at) WHERE published_at IS NULL;
+
+-- +goose Down
+DROP INDEX idx_outbox_pending;
+DROP TABLE outbox;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Delayed fallback steps of routing chains, kept until they are due so a restart doesn't drop them.
+-- The message column holds the routed message as JSON.
+CREATE TABLE routing_fallbacks (
+    id UUID PRIMARY KEY,
+    message_id UUID NOT NULL,
+    channel TEXT NOT NULL,
+    message BYTEA NOT NULL,
+    due_at TIMESTAMP NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_routing_fallbacks_due_at ON routing_fallbacks(due_at);
+
+-- +goose Down
+DROP INDEX idx_routing_fallbacks_due_at;
+DROP TABLE routing_fallbacks;
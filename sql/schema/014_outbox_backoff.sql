@@ -0,0 +1,17 @@
+-- +goose Up
+-- Failed messages wait before their next attempt, and are parked once they run out of attempts
+-- so they stop holding up the rest of the outbox
+ALTER TABLE outbox
+    ADD COLUMN next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN parked_at TIMESTAMP;
+
+DROP INDEX idx_outbox_pending;
+CREATE INDEX idx_outbox_pending ON outbox(created_at) WHERE published_at IS NULL AND parked_at IS NULL;
+
+-- +goose Down
+DROP INDEX idx_outbox_pending;
+CREATE INDEX idx_outbox_pending ON outbox(created_at) WHERE published_at IS NULL;
+
+ALTER TABLE outbox
+    DROP COLUMN next_attempt_at,
+    DROP COLUMN parked_at;
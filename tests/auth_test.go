@@ -0,0 +1,262 @@
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/auth"
+	pb "github.com/imhasandl/notification-service/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// testKeys signs tokens with an Ed25519 key published in a JWKS file
+type testKeys struct {
+	private ed25519.PrivateKey
+	path    string
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kid": "key-1", "kty": "OKP", "crv": "Ed25519", "use": "sig", "x": base64.RawURLEncoding.EncodeToString(public)},
+	}})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks, 0o600))
+	return &testKeys{private: private, path: path}
+}
+
+// token signs a token for the subject with the roles, expiring in an hour
+func (k *testKeys) token(t *testing.T, subject string, roles ...string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+		"sub":   subject,
+		"roles": roles,
+		"iss":   "https://id.example.com",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString(k.private)
+	require.NoError(t, err)
+	return signed
+}
+
+// withToken returns an incoming call context carrying the bearer token
+func withToken(token string) context.Context {
+	if token == "" {
+		return context.Background()
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestAuthUnaryInterceptor(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks, err := auth.LoadJWKS(context.Background(), keys.path)
+	require.NoError(t, err)
+	interceptor := auth.NewAuthenticator(jwks, server.AccessPolicy(), auth.WithIssuer("https://id.example.com")).UnaryInterceptor()
+
+	userID := uuid.NewString()
+	expired := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": userID, "exp": time.Now().Add(-time.Minute).Unix()})
+	expired.Header["kid"] = "key-1"
+	expiredToken, err := expired.SignedString(keys.private)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		method       string
+		token        string
+		req          interface{}
+		expectedCode codes.Code
+	}{
+		{
+			name:   "User registers their own device",
+			method: "RegisterDeviceToken",
+			token:  keys.token(t, userID),
+			req:    &pb.RegisterDeviceTokenRequest{UserId: userID, DeviceToken: "token"},
+		},
+		{
+			name:         "User registers a device for someone else",
+			method:       "RegisterDeviceToken",
+			token:        keys.token(t, userID),
+			req:          &pb.RegisterDeviceTokenRequest{UserId: uuid.NewString(), DeviceToken: "token"},
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "User deletes someone else's device",
+			method:       "DeleteDeviceToken",
+			token:        keys.token(t, userID),
+			req:          &pb.DeleteDeviceTokenRequest{UserId: uuid.NewString()},
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:   "Service acts for a user",
+			method: "DeleteAllDeviceTokens",
+			token:  keys.token(t, "accounts-service", auth.ServiceRole),
+			req:    &pb.DeleteAllDeviceTokensRequest{UserId: userID},
+		},
+		{
+			name:   "Service sends a notification",
+			method: "SendNotification",
+			token:  keys.token(t, "chat-service", auth.ServiceRole),
+			req:    &pb.SendNotificationRequest{},
+		},
+		{
+			name:         "User sends a notification",
+			method:       "SendNotification",
+			token:        keys.token(t, userID),
+			req:          &pb.SendNotificationRequest{},
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "RPC missing from the policy",
+			method:       "PurgeAllDevices",
+			token:        keys.token(t, "admin-service", auth.ServiceRole),
+			req:          &pb.ListDeviceTokensRequest{},
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "Missing token",
+			method:       "ListDeviceTokens",
+			req:          &pb.ListDeviceTokensRequest{UserId: userID},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "Expired token",
+			method:       "ListDeviceTokens",
+			token:        expiredToken,
+			req:          &pb.ListDeviceTokensRequest{UserId: userID},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "Token signed with another key",
+			method:       "ListDeviceTokens",
+			token:        newTestKeys(t).token(t, userID),
+			req:          &pb.ListDeviceTokensRequest{UserId: userID},
+			expectedCode: codes.Unauthenticated,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var identity auth.Identity
+			handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+				identity, _ = auth.IdentityFromContext(ctx)
+				return "ok", nil
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: "/" + pb.NotificationService_ServiceDesc.ServiceName + "/" + tc.method}
+
+			resp, err := interceptor(withToken(tc.token), tc.req, info, handler)
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode == codes.OK {
+				assert.Equal(t, "ok", resp)
+				assert.NotEmpty(t, identity.Subject)
+			}
+		})
+	}
+}
+
+// fakeStream is a server stream receiving one request
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	req *pb.SendToSegmentRequest
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
+
+func (s *fakeStream) RecvMsg(m interface{}) error {
+	proto.Merge(m.(proto.Message), s.req)
+	return nil
+}
+
+func TestAuthStreamInterceptor(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks, err := auth.LoadJWKS(context.Background(), keys.path)
+	require.NoError(t, err)
+	interceptor := auth.NewAuthenticator(jwks, server.AccessPolicy()).StreamInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/" + pb.NotificationService_ServiceDesc.ServiceName + "/SendToSegment", IsServerStream: true}
+
+	handler := func(_ interface{}, stream grpc.ServerStream) error {
+		identity, ok := auth.IdentityFromContext(stream.Context())
+		require.True(t, ok)
+		assert.Equal(t, "campaign-service", identity.Subject)
+		return stream.RecvMsg(&pb.SendToSegmentRequest{})
+	}
+
+	stream := &fakeStream{ctx: withToken(keys.token(t, "campaign-service", auth.ServiceRole)), req: &pb.SendToSegmentRequest{}}
+	assert.NoError(t, interceptor(nil, stream, info, handler))
+
+	stream.ctx = withToken(keys.token(t, uuid.NewString()))
+	assert.Equal(t, codes.PermissionDenied, status.Code(interceptor(nil, stream, info, handler)))
+}
+
+func TestAccessPolicyCoversEveryRPC(t *testing.T) {
+	policy := server.AccessPolicy()
+	for _, method := range pb.NotificationService_ServiceDesc.Methods {
+		assert.Contains(t, policy, "/"+pb.NotificationService_ServiceDesc.ServiceName+"/"+method.MethodName)
+	}
+	for _, stream := range pb.NotificationService_ServiceDesc.Streams {
+		assert.Contains(t, policy, "/"+pb.NotificationService_ServiceDesc.ServiceName+"/"+stream.StreamName)
+	}
+}
+
+func TestLoadJWKSFromURL(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	kid := "key-1"
+	fetches := 0
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches++
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": kid,
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+		}}})
+	}))
+	defer provider.Close()
+
+	jwks, err := auth.LoadJWKS(context.Background(), provider.URL)
+	require.NoError(t, err)
+	authenticator := auth.NewAuthenticator(jwks, server.AccessPolicy())
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "chat-service", "exp": time.Now().Add(time.Hour).Unix()})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(rsaKey)
+	require.NoError(t, err)
+
+	identity, err := authenticator.Authenticate(context.Background(), signed)
+	require.NoError(t, err)
+	assert.Equal(t, "chat-service", identity.Subject)
+
+	// An unknown key id doesn't refetch the key set again right away
+	token.Header["kid"] = "key-2"
+	signed, err = token.SignedString(rsaKey)
+	require.NoError(t, err)
+	_, err = authenticator.Authenticate(context.Background(), signed)
+	assert.ErrorIs(t, err, auth.ErrUnknownKey)
+	assert.Equal(t, 1, fetches)
+}
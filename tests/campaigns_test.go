@@ -0,0 +1,346 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/campaigns"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	"github.com/imhasandl/notification-service/internal/segments"
+	pb "github.com/imhasandl/notification-service/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestCreateCampaign(t *testing.T) {
+	notification := []byte(`{"title": "Spring sale", "content": "Everything is 20% off"}`)
+	segment := []byte(`{"field": "is_premium", "op": "eq", "value": false}`)
+	scheduledAt := time.Now().Add(time.Hour).UTC()
+
+	testCases := []struct {
+		name         string
+		req          *pb.CreateCampaignRequest
+		setupMocks   func(*mocks.MockQueries)
+		expectedCode codes.Code
+	}{
+		{
+			name: "Scheduled campaign with defaults",
+			req: &pb.CreateCampaignRequest{
+				Name:         "Spring sale",
+				Notification: notification,
+				Segment:      segment,
+				ScheduledAt:  timestamppb.New(scheduledAt),
+				BatchSize:    segments.MaxBatchSize + 1,
+			},
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("CreateCampaign", mock.Anything, mock.MatchedBy(func(arg database.CreateCampaignParams) bool {
+					return arg.Name == "Spring sale" &&
+						arg.ScheduledAt.Valid && arg.ScheduledAt.Time.Equal(scheduledAt) &&
+						arg.RatePerSecond == segments.DefaultRate &&
+						arg.BatchSize == segments.MaxBatchSize
+				})).Return(database.Campaign{ID: uuid.New(), Name: "Spring sale", Status: campaigns.StatusDraft}, nil).Once()
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "Missing name",
+			req:          &pb.CreateCampaignRequest{Notification: notification, Segment: segment},
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "Invalid segment",
+			req:          &pb.CreateCampaignRequest{Name: "Spring sale", Notification: notification, Segment: []byte(`{"field": "password", "op": "eq", "value": "x"}`)},
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "Invalid notification",
+			req:          &pb.CreateCampaignRequest{Name: "Spring sale", Notification: []byte("{invalid"), Segment: segment},
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "Database error",
+			req:  &pb.CreateCampaignRequest{Name: "Spring sale", Notification: notification, Segment: segment},
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("CreateCampaign", mock.Anything, mock.Anything).Return(database.Campaign{}, errors.New("database error")).Once()
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			tc.setupMocks(mockDB)
+			srv := newTestServer(t, mockDB)
+
+			resp, err := srv.CreateCampaign(context.Background(), tc.req)
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode == codes.OK {
+				require.NotNil(t, resp.Campaign)
+				assert.Equal(t, campaigns.StatusDraft, resp.Campaign.Status)
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCampaignTransitions(t *testing.T) {
+	id := uuid.New()
+
+	testCases := []struct {
+		name         string
+		method       string
+		id           string
+		setupMocks   func(*mocks.MockQueries, string)
+		expectedCode codes.Code
+	}{
+		{
+			name:   "Start draft",
+			method: "StartCampaign",
+			id:     id.String(),
+			setupMocks: func(db *mocks.MockQueries, method string) {
+				db.On(method, mock.Anything, id).Return(database.Campaign{ID: id, Status: campaigns.StatusRunning}, nil).Once()
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:   "Pause running",
+			method: "PauseCampaign",
+			id:     id.String(),
+			setupMocks: func(db *mocks.MockQueries, method string) {
+				db.On(method, mock.Anything, id).Return(database.Campaign{ID: id, Status: campaigns.StatusPaused}, nil).Once()
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:   "Cancel completed",
+			method: "CancelCampaign",
+			id:     id.String(),
+			setupMocks: func(db *mocks.MockQueries, method string) {
+				db.On(method, mock.Anything, id).Return(database.Campaign{}, sql.ErrNoRows).Once()
+				db.On("GetCampaign", mock.Anything, id).Return(database.Campaign{ID: id, Status: campaigns.StatusCompleted}, nil).Once()
+			},
+			expectedCode: codes.FailedPrecondition,
+		},
+		{
+			name:   "Start missing",
+			method: "StartCampaign",
+			id:     id.String(),
+			setupMocks: func(db *mocks.MockQueries, method string) {
+				db.On(method, mock.Anything, id).Return(database.Campaign{}, sql.ErrNoRows).Once()
+				db.On("GetCampaign", mock.Anything, id).Return(database.Campaign{}, sql.ErrNoRows).Once()
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:         "Invalid id",
+			method:       "PauseCampaign",
+			id:           "invalid-uuid",
+			setupMocks:   func(*mocks.MockQueries, string) {},
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			tc.setupMocks(mockDB, tc.method)
+			srv := newTestServer(t, mockDB)
+
+			var err error
+			switch tc.method {
+			case "StartCampaign":
+				_, err = srv.StartCampaign(context.Background(), &pb.StartCampaignRequest{Id: tc.id})
+			case "PauseCampaign":
+				_, err = srv.PauseCampaign(context.Background(), &pb.PauseCampaignRequest{Id: tc.id})
+			case "CancelCampaign":
+				_, err = srv.CancelCampaign(context.Background(), &pb.CancelCampaignRequest{Id: tc.id})
+			}
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetCampaignStats(t *testing.T) {
+	id := uuid.New()
+	startedAt := time.Now().Add(-time.Minute)
+
+	mockDB := mocks.NewMockQueries()
+	mockDB.On("GetCampaign", mock.Anything, id).Return(database.Campaign{
+		ID:        id,
+		Status:    campaigns.StatusRunning,
+		Total:     10,
+		Processed: 4,
+		Sent:      3,
+		Skipped:   1,
+		StartedAt: sql.NullTime{Time: startedAt, Valid: true},
+	}, nil).Once()
+	srv := newTestServer(t, mockDB)
+
+	resp, err := srv.GetCampaignStats(context.Background(), &pb.GetCampaignStatsRequest{Id: id.String()})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), resp.Campaign.Total)
+	assert.Equal(t, int64(4), resp.Campaign.Processed)
+	assert.Equal(t, int64(3), resp.Campaign.Sent)
+	assert.True(t, resp.Campaign.StartedAt.AsTime().Equal(startedAt))
+	assert.Nil(t, resp.Campaign.CompletedAt)
+
+	mockDB.On("GetCampaign", mock.Anything, mock.Anything).Return(database.Campaign{}, sql.ErrNoRows).Once()
+	_, err = srv.GetCampaignStats(context.Background(), &pb.GetCampaignStatsRequest{Id: uuid.New().String()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	mockDB.AssertExpectations(t)
+}
+
+// sendAll returns a sender delivering to every user, recording who it sent to
+func sendAll(sent *[]uuid.UUID) campaigns.SenderFunc {
+	return func(notification []byte) (segments.SendFunc, error) {
+		if !json.Valid(notification) {
+			return nil, errors.New("invalid notification")
+		}
+		return func(_ context.Context, userID uuid.UUID) (bool, error) {
+			*sent = append(*sent, userID)
+			return true, nil
+		}, nil
+	}
+}
+
+func TestCampaignRunnerResumesFromCheckpoint(t *testing.T) {
+	done := uuid.New()
+	remaining := uuid.New()
+	leaseID := uuid.NullUUID{UUID: uuid.New(), Valid: true}
+
+	// A campaign interrupted after one user, with the total counted on its first run
+	campaign := database.Campaign{
+		ID:                 uuid.New(),
+		Notification:       []byte(`{"title": "Spring sale"}`),
+		Segment:            []byte(`{"all": []}`),
+		Status:             campaigns.StatusRunning,
+		RatePerSecond:      1000,
+		BatchSize:          500,
+		Total:              2,
+		Processed:          1,
+		Sent:               1,
+		LastUserID:         uuid.NullUUID{UUID: done, Valid: true},
+		SegmentEvaluatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		LeaseID:            leaseID,
+	}
+
+	mockDB := mocks.NewMockQueries()
+	mockDB.On("ClaimCampaign", mock.Anything, mock.Anything).Return(campaign, nil).Once()
+	mockDB.On("ListSegmentUsers", mock.Anything, mock.Anything, done, int32(500)).Return([]uuid.UUID{remaining}, nil).Once()
+	mockDB.On("ListSegmentUsers", mock.Anything, mock.Anything, remaining, int32(500)).Return([]uuid.UUID{}, nil).Once()
+	mockDB.On("CheckpointCampaign", mock.Anything, mock.MatchedBy(func(arg database.CheckpointCampaignParams) bool {
+		return arg.LeaseID == leaseID && arg.Total == 2 && arg.Processed == 2 && arg.Sent == 2 && arg.LastUserID.UUID == remaining
+	})).Return(campaign, nil).Twice()
+	mockDB.On("FinishCampaign", mock.Anything, database.FinishCampaignParams{
+		ID:      campaign.ID,
+		LeaseID: leaseID,
+		Status:  campaigns.StatusCompleted,
+	}).Return(nil).Once()
+
+	var sent []uuid.UUID
+	runner := campaigns.NewRunner(mockDB, sendAll(&sent))
+
+	claimed, err := runner.RunNext(context.Background())
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, []uuid.UUID{remaining}, sent)
+	mockDB.AssertExpectations(t)
+	mockDB.AssertNotCalled(t, "CountSegmentUsers", mock.Anything, mock.Anything)
+}
+
+func TestCampaignRunnerStops(t *testing.T) {
+	user := uuid.New()
+	campaign := database.Campaign{
+		ID:            uuid.New(),
+		Notification:  []byte(`{"title": "Spring sale"}`),
+		Segment:       []byte(`{"all": []}`),
+		Status:        campaigns.StatusRunning,
+		RatePerSecond: 1000,
+		BatchSize:     500,
+		LeaseID:       uuid.NullUUID{UUID: uuid.New(), Valid: true},
+	}
+
+	testCases := []struct {
+		name        string
+		setupMocks  func(*mocks.MockQueries)
+		claimed     bool
+		shouldError bool
+	}{
+		{
+			name: "Nothing due",
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("ClaimCampaign", mock.Anything, mock.Anything).Return(database.Campaign{}, sql.ErrNoRows).Once()
+			},
+		},
+		{
+			name: "Paused while running",
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("ClaimCampaign", mock.Anything, mock.Anything).Return(campaign, nil).Once()
+				db.On("CountSegmentUsers", mock.Anything, mock.Anything).Return(int64(1), nil).Once()
+				db.On("ListSegmentUsers", mock.Anything, mock.Anything, uuid.Nil, int32(500)).Return([]uuid.UUID{user}, nil).Once()
+				db.On("CheckpointCampaign", mock.Anything, mock.Anything).Return(database.Campaign{}, sql.ErrNoRows).Once()
+			},
+			claimed: true,
+		},
+		{
+			name: "Invalid notification",
+			setupMocks: func(db *mocks.MockQueries) {
+				invalid := campaign
+				invalid.Notification = []byte("{invalid")
+				db.On("ClaimCampaign", mock.Anything, mock.Anything).Return(invalid, nil).Once()
+				db.On("FinishCampaign", mock.Anything, mock.MatchedBy(func(arg database.FinishCampaignParams) bool {
+					return arg.Status == campaigns.StatusFailed && arg.LastError != ""
+				})).Return(nil).Once()
+			},
+			claimed:     true,
+			shouldError: true,
+		},
+		{
+			name: "Database error",
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("ClaimCampaign", mock.Anything, mock.Anything).Return(campaign, nil).Once()
+				db.On("CountSegmentUsers", mock.Anything, mock.Anything).Return(int64(0), errors.New("database error")).Once()
+			},
+			claimed:     true,
+			shouldError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			tc.setupMocks(mockDB)
+
+			var sent []uuid.UUID
+			runner := campaigns.NewRunner(mockDB, sendAll(&sent))
+
+			claimed, err := runner.RunNext(context.Background())
+			assert.Equal(t, tc.claimed, claimed)
+			if tc.shouldError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockDB.AssertNotCalled(t, "FinishCampaign", mock.Anything, database.FinishCampaignParams{ID: campaign.ID, LeaseID: campaign.LeaseID, Status: campaigns.StatusCompleted})
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/broker"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/events"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	"github.com/imhasandl/notification-service/producer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// consume runs the server's consumer until the test ends
+func consume(t *testing.T, srv *server.Server) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- srv.Consume(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-stopped)
+	})
+}
+
+func TestConsumeNotificationRequest(t *testing.T) {
+	receiverID := uuid.New()
+	handled := make(chan struct{})
+
+	mockDB := mocks.NewMockQueries()
+	mockDB.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(database.DeviceToken{}, nil).Once()
+	mockDB.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(arg database.CreateOutboxMessageParams) bool {
+		return arg.RoutingKey == events.TypeSuppressed && arg.CorrelationID == "chat-message-42"
+	})).Run(func(mock.Arguments) {
+		close(handled)
+	}).Return(nil).Once()
+
+	memory := broker.NewMemory()
+	srv, err := server.NewServer(mockDB, memory, "test/path", mocks.NewMockFirebaseClient())
+	require.NoError(t, err)
+	consume(t, srv)
+
+	msg, err := producer.Message(producer.Notification{ReceiverID: receiverID, Title: "New message"}, "chat-message-42")
+	require.NoError(t, err)
+	require.NoError(t, memory.Publish(context.Background(), broker.RequestsTopic, producer.RoutingKey("chat"), broker.Message{
+		ID:            msg.MessageId,
+		CorrelationID: msg.CorrelationId,
+		ContentType:   msg.ContentType,
+		Body:          msg.Body,
+	}))
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("notification request was not handled")
+	}
+	assert.Empty(t, memory.Rejected())
+}
+
+func TestConsumeRejectsInvalidRequest(t *testing.T) {
+	memory := broker.NewMemory()
+	srv, err := server.NewServer(mocks.NewMockQueries(), memory, "test/path", mocks.NewMockFirebaseClient())
+	require.NoError(t, err)
+	consume(t, srv)
+
+	require.NoError(t, memory.Publish(context.Background(), broker.RequestsTopic, "notification.default", broker.Message{
+		ID:   uuid.NewString(),
+		Body: []byte(`{"title": "New message", "receiver_id": "not-a-uuid"}`),
+	}))
+
+	assert.Eventually(t, func() bool {
+		return len(memory.DeadLettered()) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Empty(t, memory.Rejected())
+}
+
+func TestConsumeRedeliveryKeepsTrackingID(t *testing.T) {
+	receiverID := uuid.New()
+	trackingIDs := make(chan string, 3)
+
+	mockDB := mocks.NewMockQueries()
+	mockFirebase := mocks.NewMockFirebaseClient()
+	mockFCM := new(mocks.MockFCMClient)
+	mockDB.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(database.DeviceToken{DeviceToken: "token"}, nil)
+	mockFirebase.On("GetMessagingClient").Return(mockFCM)
+	mockFCM.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		trackingIDs <- args.Get(1).(*messaging.Message).Data["tracking_id"]
+	}).Return("message-id", nil)
+	expectTracking(&mockDB.Mock)
+
+	memory := broker.NewMemory()
+	srv, err := server.NewServer(mockDB, memory, "test/path", mockFirebase)
+	require.NoError(t, err)
+	consume(t, srv)
+
+	// The same request delivered twice, then another request
+	body := []byte(`{"title": "New message", "receiver_id": "` + receiverID.String() + `"}`)
+	requestID := uuid.NewString()
+	for _, id := range []string{requestID, requestID, uuid.NewString()} {
+		require.NoError(t, memory.Publish(context.Background(), broker.RequestsTopic, "notification.default", broker.Message{ID: id, Body: body}))
+	}
+
+	var received []string
+	for range 3 {
+		select {
+		case id := <-trackingIDs:
+			received = append(received, id)
+		case <-time.After(time.Second):
+			t.Fatal("notification was not sent")
+		}
+	}
+	assert.Equal(t, received[0], received[1], "a redelivered request keeps its tracking id")
+	assert.NotEqual(t, received[0], received[2])
+}
@@ -0,0 +1,287 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/broker"
+	"github.com/imhasandl/notification-service/internal/codec"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	pb "github.com/imhasandl/notification-service/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestConsumeDecodesByContentType(t *testing.T) {
+	receiverID := uuid.New()
+	jsonData := `{"title": "New message", "receiver_id": "` + receiverID.String() + `"}`
+	protoData, err := proto.Marshal(&pb.Notification{Title: "New message", ReceiverId: receiverID.String()})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name             string
+		msg              broker.Message
+		shouldDeadLetter bool
+	}{
+		{
+			name: "Legacy JSON without content type",
+			msg:  broker.Message{Body: []byte(jsonData)},
+		},
+		{
+			name: "JSON naming its schema",
+			msg: broker.Message{
+				ContentType: "application/json; charset=utf-8",
+				Headers:     map[string]string{codec.SchemaHeader: "notification.v1"},
+				Body:        []byte(jsonData),
+			},
+		},
+		{
+			name: "Protobuf",
+			msg:  broker.Message{ContentType: "application/x-protobuf", Body: protoData},
+		},
+		{
+			name: "Structured CloudEvent with JSON data",
+			msg: broker.Message{
+				ContentType: codec.ContentTypeCloudEvents,
+				Body: []byte(`{"specversion": "1.0", "id": "1", "source": "/chat", "type": "com.example.notification.requested",
+					"dataschema": "https://schemas.example.com/notification.v1", "data": ` + jsonData + `}`),
+			},
+		},
+		{
+			name: "Structured CloudEvent with protobuf data",
+			msg: broker.Message{
+				ContentType: codec.ContentTypeCloudEvents,
+				Body: []byte(`{"specversion": "1.0", "id": "1", "source": "/chat", "type": "com.example.notification.requested",
+					"datacontenttype": "application/protobuf", "data_base64": "` + base64.StdEncoding.EncodeToString(protoData) + `"}`),
+			},
+		},
+		{
+			name: "Binary CloudEvent over Kafka",
+			msg: broker.Message{
+				ContentType: codec.ContentTypeJSON,
+				Headers:     map[string]string{"ce_specversion": "1.0", "ce_id": "1", "ce_source": "/chat", "ce_type": "com.example.notification.requested"},
+				Body:        []byte(jsonData),
+			},
+		},
+		{
+			name: "Binary CloudEvent over AMQP",
+			msg: broker.Message{
+				ContentType: codec.ContentTypeProtobuf,
+				Headers: map[string]string{"cloudEvents:specversion": "1.0", "cloudEvents:id": "1", "cloudEvents:source": "/chat",
+					"cloudEvents:type": "com.example.notification.requested", "cloudEvents:time": "2025-03-10T12:00:00Z"},
+				Body: protoData,
+			},
+		},
+		{
+			name: "JSON with schema_version",
+			msg: broker.Message{
+				ContentType: codec.ContentTypeJSON,
+				Body:        []byte(`{"schema_version": 2, "title": "New message", "receiver_id": "` + receiverID.String() + `"}`),
+			},
+		},
+		{
+			name: "Protobuf naming the current schema",
+			msg: broker.Message{
+				ContentType: codec.ContentTypeProtobuf,
+				Headers:     map[string]string{codec.SchemaHeader: "notification.v2"},
+				Body:        protoData,
+			},
+		},
+		{
+			name:             "Unknown schema version",
+			msg:              broker.Message{Headers: map[string]string{codec.SchemaHeader: "notification.v9"}, Body: []byte(jsonData)},
+			shouldDeadLetter: true,
+		},
+		{
+			name:             "Unsupported content type",
+			msg:              broker.Message{ContentType: "text/plain", Body: []byte(jsonData)},
+			shouldDeadLetter: true,
+		},
+		{
+			name: "CloudEvent without source",
+			msg: broker.Message{
+				ContentType: codec.ContentTypeCloudEvents,
+				Body:        []byte(`{"specversion": "1.0", "id": "1", "type": "com.example.notification.requested", "data": ` + jsonData + `}`),
+			},
+			shouldDeadLetter: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sent := make(chan struct{})
+			mockDB := mocks.NewMockQueries()
+			mockFirebase := mocks.NewMockFirebaseClient()
+			mockFCM := new(mocks.MockFCMClient)
+			mockDB.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(database.DeviceToken{DeviceToken: "token"}, nil).Maybe()
+			mockFirebase.On("GetMessagingClient").Return(mockFCM).Maybe()
+			mockFCM.On("Send", mock.Anything, mock.MatchedBy(func(message *messaging.Message) bool {
+				return message.Notification.Title == "New message"
+			})).Run(func(mock.Arguments) {
+				close(sent)
+			}).Return("message-id", nil).Maybe()
+			expectTracking(&mockDB.Mock)
+
+			memory := broker.NewMemory()
+			srv, err := server.NewServer(mockDB, memory, "test/path", mockFirebase)
+			require.NoError(t, err)
+			consume(t, srv)
+			require.NoError(t, memory.Publish(context.Background(), broker.RequestsTopic, "notification.default", tc.msg))
+
+			if tc.shouldDeadLetter {
+				assert.Eventually(t, func() bool {
+					return len(memory.DeadLettered()) == 1
+				}, time.Second, 10*time.Millisecond)
+				assert.Empty(t, memory.Rejected())
+				mockFCM.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+				return
+			}
+
+			select {
+			case <-sent:
+			case <-time.After(time.Second):
+				t.Fatal("notification was not sent")
+			}
+		})
+	}
+}
+
+func TestConsumeValidatesSchema(t *testing.T) {
+	receiverID := uuid.New().String()
+
+	testCases := []struct {
+		name           string
+		schema         string
+		body           string
+		expectedReason string
+	}{
+		{
+			name:           "Misspelled field",
+			body:           `{"schema_version": 2, "title": "New message", "receiverId": "` + receiverID + `"}`,
+			expectedReason: "invalid payload: receiver_id: is required; receiverId: is not allowed",
+		},
+		{
+			name:           "Misspelled field in a legacy payload",
+			body:           `{"title": "New message", "receiverId": "` + receiverID + `"}`,
+			expectedReason: "invalid payload: receiver_id: is required",
+		},
+		{
+			name:           "Wrong types",
+			body:           `{"schema_version": 2, "receiver_id": "user-1", "sent_at": "yesterday", "translations": {"es": {"title": 5}}}`,
+			expectedReason: "invalid payload: receiver_id: must be a UUID; sent_at: must be an RFC 3339 date-time; translations.es.title: must be a string",
+		},
+		{
+			name: "Invalid experiment",
+			body: `{"schema_version": 2, "receiver_id": "` + receiverID + `",
+				"experiment": {"key": "copy-test", "variants": [{"name": "a", "weight": "half"}, {"weight": 1}]}}`,
+			expectedReason: "invalid payload: experiment.variants[0].weight: must be an integer; experiment.variants[1].name: is required",
+		},
+		{
+			name:           "Version doesn't match the schema header",
+			schema:         "notification.v2",
+			body:           `{"schema_version": 1, "receiver_id": "` + receiverID + `"}`,
+			expectedReason: "invalid payload: schema_version: doesn't match schema notification.v2",
+		},
+		{
+			name:           "Not an object",
+			body:           `["New message"]`,
+			expectedReason: "invalid payload: must be a JSON object",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			memory := broker.NewMemory()
+			srv, err := server.NewServer(mocks.NewMockQueries(), memory, "test/path", mocks.NewMockFirebaseClient())
+			require.NoError(t, err)
+			consume(t, srv)
+
+			msg := broker.Message{ID: uuid.NewString(), ContentType: codec.ContentTypeJSON, Body: []byte(tc.body)}
+			if tc.schema != "" {
+				msg.Headers = map[string]string{codec.SchemaHeader: tc.schema}
+			}
+			require.NoError(t, memory.Publish(context.Background(), broker.RequestsTopic, "notification.chat", msg))
+
+			require.Eventually(t, func() bool {
+				return len(memory.DeadLettered()) == 1
+			}, time.Second, 10*time.Millisecond)
+			deadLettered := memory.DeadLettered()[0]
+			assert.Equal(t, msg.ID, deadLettered.ID)
+			assert.Equal(t, tc.expectedReason, deadLettered.Headers[broker.HeaderDeadLetterReason])
+			assert.Equal(t, "notification.chat", memory.Published()[1].Key)
+			assert.Empty(t, memory.Rejected())
+		})
+	}
+}
+
+func TestConsumeUpcastsLegacyPayload(t *testing.T) {
+	receiverID := uuid.New()
+	sent := make(chan struct{})
+
+	mockDB := mocks.NewMockQueries()
+	mockFirebase := mocks.NewMockFirebaseClient()
+	mockFCM := new(mocks.MockFCMClient)
+	mockDB.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(database.DeviceToken{DeviceToken: "token"}, nil).Once()
+	mockFirebase.On("GetMessagingClient").Return(mockFCM)
+	mockFCM.On("Send", mock.Anything, mock.MatchedBy(func(message *messaging.Message) bool {
+		return message.Data["sender_username"] == "alice" && message.Data["sent_at"] == "2025-03-10T12:00:00Z" &&
+			message.Notification.Title == "New message" && message.Notification.Body == "Hi!"
+	})).Run(func(mock.Arguments) {
+		close(sent)
+	}).Return("message-id", nil).Once()
+	expectTracking(&mockDB.Mock)
+
+	memory := broker.NewMemory()
+	srv, err := server.NewServer(mockDB, memory, "test/path", mockFirebase)
+	require.NoError(t, err)
+	consume(t, srv)
+
+	// A v1 payload as producers marshal the original Notification, plus a field and a null the
+	// lenient v1 decoding ignored
+	require.NoError(t, memory.Publish(context.Background(), broker.RequestsTopic, "notification.default", broker.Message{
+		Body: []byte(`{"title": "New message", "sender_username": "alice", "receiver_id": "` + receiverID.String() +
+			`", "content": "Hi!", "sent_at": "2025-03-10T12:00:00Z", "priority": "high", "category": null}`),
+	}))
+
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("notification was not sent")
+	}
+	assert.Empty(t, memory.DeadLettered())
+}
+
+func TestParseSchema(t *testing.T) {
+	testCases := []struct {
+		input       string
+		expected    codec.Schema
+		shouldError bool
+	}{
+		{input: "notification.v1", expected: codec.Schema{Name: "notification", Version: 1}},
+		{input: "https://schemas.example.com/chat.notification.v12", expected: codec.Schema{Name: "chat.notification", Version: 12}},
+		{input: "notification", shouldError: true},
+		{input: "notification.v0", shouldError: true},
+		{input: ".v1", shouldError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			schema, err := codec.ParseSchema(tc.input)
+			if tc.shouldError {
+				assert.ErrorIs(t, err, codec.ErrInvalidSchema)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, schema)
+			assert.Equal(t, tc.input[len(tc.input)-len(schema.String()):], schema.String())
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	pb "github.com/imhasandl/notification-service/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestServer creates a server backed by the given database mock
+func newTestServer(t *testing.T, db server.DBQuerier) *server.Server {
+	t.Helper()
+	srv, err := server.NewServer(db, mocks.NewMockBroker(), "test/path", mocks.NewMockFirebaseClient())
+	require.NoError(t, err)
+	return srv
+}
+
+// expectNoTopics lets handlers look up the user's topics, finding none so FCM is never called
+func expectNoTopics(db *mocks.MockQueries) {
+	db.On("ListTopicsByUserID", mock.Anything, mock.Anything).Return([]string{}, nil).Maybe()
+}
+
+func TestListDeviceTokens(t *testing.T) {
+	validUserID := uuid.New()
+	now := time.Now()
+
+	testCases := []struct {
+		name          string
+		userID        string
+		setupMocks    func(*mocks.MockQueries)
+		expectedCode  codes.Code
+		expectedCount int
+	}{
+		{
+			name:   "Success case",
+			userID: validUserID.String(),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("ListDeviceTokensByUserID", mock.Anything, validUserID).Return([]database.DeviceToken{
+					{ID: uuid.New(), UserID: validUserID, DeviceToken: "phone", DeviceType: "ios", CreatedAt: now, UpdatedAt: now},
+					{ID: uuid.New(), UserID: validUserID, DeviceToken: "laptop", DeviceType: "web", CreatedAt: now, UpdatedAt: now},
+				}, nil).Once()
+			},
+			expectedCode:  codes.OK,
+			expectedCount: 2,
+		},
+		{
+			name:   "No devices",
+			userID: validUserID.String(),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("ListDeviceTokensByUserID", mock.Anything, validUserID).Return([]database.DeviceToken{}, nil).Once()
+			},
+			expectedCode:  codes.OK,
+			expectedCount: 0,
+		},
+		{
+			name:         "Invalid UUID",
+			userID:       "not-a-valid-uuid",
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:   "Database error",
+			userID: validUserID.String(),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("ListDeviceTokensByUserID", mock.Anything, validUserID).Return([]database.DeviceToken(nil), errors.New("database error")).Once()
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			tc.setupMocks(mockDB)
+			srv := newTestServer(t, mockDB)
+
+			response, err := srv.ListDeviceTokens(context.Background(), &pb.ListDeviceTokensRequest{UserId: tc.userID})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode == codes.OK {
+				require.NotNil(t, response)
+				assert.Len(t, response.DeviceTokens, tc.expectedCount)
+				for _, deviceToken := range response.DeviceTokens {
+					assert.Equal(t, validUserID.String(), deviceToken.UserId)
+					assert.NotNil(t, deviceToken.CreatedAt)
+				}
+			}
+
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteAllDeviceTokens(t *testing.T) {
+	validUserID := uuid.New()
+
+	testCases := []struct {
+		name         string
+		userID       string
+		setupMocks   func(*mocks.MockQueries)
+		expectedCode codes.Code
+	}{
+		{
+			name:   "Success case",
+			userID: validUserID.String(),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("DeleteAllDeviceTokens", mock.Anything, validUserID).Return([]string{"phone", "tablet", "laptop"}, nil).Once()
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "Invalid UUID",
+			userID:       "not-a-valid-uuid",
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:   "Database error",
+			userID: validUserID.String(),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("DeleteAllDeviceTokens", mock.Anything, validUserID).Return([]string(nil), errors.New("database error")).Once()
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			tc.setupMocks(mockDB)
+			expectNoTopics(mockDB)
+			srv := newTestServer(t, mockDB)
+
+			response, err := srv.DeleteAllDeviceTokens(context.Background(), &pb.DeleteAllDeviceTokensRequest{UserId: tc.userID})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode == codes.OK {
+				assert.True(t, response.Status)
+				assert.Equal(t, int64(3), response.DeletedCount)
+			}
+
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdateDeviceToken(t *testing.T) {
+	validUserID := uuid.New()
+	validID := uuid.New()
+
+	testCases := []struct {
+		name         string
+		id           string
+		userID       string
+		setupMocks   func(*mocks.MockQueries)
+		expectedCode codes.Code
+	}{
+		{
+			name:   "Success case",
+			id:     validID.String(),
+			userID: validUserID.String(),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("UpdateDeviceToken", mock.Anything, database.UpdateDeviceTokenParams{
+					ID:          validID,
+					UserID:      validUserID,
+					DeviceToken: "rotated-token",
+					DeviceType:  "android",
+				}).Return(database.DeviceToken{
+					ID:          validID,
+					UserID:      validUserID,
+					DeviceToken: "rotated-token",
+					DeviceType:  "android",
+					CreatedAt:   time.Now(),
+					UpdatedAt:   time.Now(),
+				}, nil).Once()
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "Invalid device token id",
+			id:           "not-a-valid-uuid",
+			userID:       validUserID.String(),
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "Invalid user id",
+			id:           validID.String(),
+			userID:       "not-a-valid-uuid",
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:   "Device belongs to another user",
+			id:     validID.String(),
+			userID: validUserID.String(),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("UpdateDeviceToken", mock.Anything, mock.Anything).Return(database.DeviceToken{}, sql.ErrNoRows).Once()
+			},
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			tc.setupMocks(mockDB)
+			expectNoTopics(mockDB)
+			srv := newTestServer(t, mockDB)
+
+			response, err := srv.UpdateDeviceToken(context.Background(), &pb.UpdateDeviceTokenRequest{
+				Id:          tc.id,
+				UserId:      tc.userID,
+				DeviceToken: "rotated-token",
+				DeviceType:  "android",
+			})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode == codes.OK {
+				assert.Equal(t, validID.String(), response.DeviceToken.Id)
+				assert.Equal(t, "rotated-token", response.DeviceToken.DeviceToken)
+			}
+
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTouchDeviceToken(t *testing.T) {
+	validUserID := uuid.New()
+
+	testCases := []struct {
+		name         string
+		userID       string
+		setupMocks   func(*mocks.MockQueries)
+		expectedCode codes.Code
+	}{
+		{
+			name:   "Success case",
+			userID: validUserID.String(),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("TouchDeviceToken", mock.Anything, database.TouchDeviceTokenParams{
+					UserID:      validUserID,
+					DeviceToken: "device-token-123",
+				}).Return(database.DeviceToken{
+					ID:          uuid.New(),
+					UserID:      validUserID,
+					DeviceToken: "device-token-123",
+					LastSeenAt:  time.Now(),
+				}, nil).Once()
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "Invalid UUID",
+			userID:       "not-a-valid-uuid",
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:   "Unknown device",
+			userID: validUserID.String(),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("TouchDeviceToken", mock.Anything, mock.Anything).Return(database.DeviceToken{}, sql.ErrNoRows).Once()
+			},
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			tc.setupMocks(mockDB)
+			srv := newTestServer(t, mockDB)
+
+			response, err := srv.TouchDeviceToken(context.Background(), &pb.TouchDeviceTokenRequest{
+				UserId:      tc.userID,
+				DeviceToken: "device-token-123",
+			})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode == codes.OK {
+				assert.NotNil(t, response.DeviceToken.LastSeenAt)
+				assert.Nil(t, response.DeviceToken.DisabledAt)
+			}
+
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/engagement"
+	"github.com/imhasandl/notification-service/internal/events"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	pb "github.com/imhasandl/notification-service/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// expectTracking lets the dispatcher store the notifications it tracks and queue their events
+func expectTracking(db *mock.Mock) {
+	db.On("CreateNotificationTracking", mock.Anything, mock.Anything).Return(nil).Maybe()
+	db.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
+func TestSendNotificationIsTracked(t *testing.T) {
+	receiverID := uuid.New()
+
+	mockDB := new(mocks.MockDBQuerier)
+	mockFirebase := new(mocks.MockFirebaseClient)
+	mockFCM := new(mocks.MockFCMClient)
+
+	mockDB.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(database.DeviceToken{DeviceToken: "token"}, nil)
+	mockFirebase.On("GetMessagingClient").Return(mockFCM)
+
+	var trackingID string
+	mockFCM.On("Send", mock.Anything, mock.MatchedBy(func(message *messaging.Message) bool {
+		trackingID = message.Data["tracking_id"]
+		return trackingID != ""
+	})).Return("message-id", nil).Once()
+	mockDB.On("CreateNotificationTracking", mock.Anything, mock.MatchedBy(func(arg database.CreateNotificationTrackingParams) bool {
+		return arg.ID.String() == trackingID && arg.UserID == receiverID && arg.Category == "security"
+	})).Return(nil).Once()
+	mockDB.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(arg database.CreateOutboxMessageParams) bool {
+		return arg.RoutingKey == events.TypeSent
+	})).Return(nil).Once()
+
+	srv, err := server.NewServer(mockDB, mocks.NewMockBroker(), "test-path", mockFirebase)
+	require.NoError(t, err)
+
+	_, err = srv.SendNotification(context.Background(), &pb.SendNotificationRequest{
+		Notification: []byte(`{"title": "New sign-in", "receiver_id": "` + receiverID.String() + `", "category": "security"}`),
+	})
+	require.NoError(t, err)
+
+	mockDB.AssertExpectations(t)
+	mockFCM.AssertExpectations(t)
+}
+
+func TestSendNotificationWithoutDeliveryIsNotTracked(t *testing.T) {
+	receiverID := uuid.New()
+
+	mockDB := new(mocks.MockDBQuerier)
+	mockDB.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(database.DeviceToken{}, nil)
+	mockDB.On("CreateOutboxMessage", mock.Anything, mock.MatchedBy(func(arg database.CreateOutboxMessageParams) bool {
+		return arg.RoutingKey == events.TypeSuppressed
+	})).Return(nil).Once()
+	srv := newTestServer(t, mockDB)
+
+	_, err := srv.SendNotification(context.Background(), &pb.SendNotificationRequest{
+		Notification: []byte(`{"title": "New sign-in", "receiver_id": "` + receiverID.String() + `"}`),
+	})
+	require.NoError(t, err)
+
+	mockDB.AssertExpectations(t)
+	mockDB.AssertNotCalled(t, "CreateNotificationTracking", mock.Anything, mock.Anything)
+}
+
+func TestReportNotificationOpened(t *testing.T) {
+	trackingID := uuid.New()
+	userID := uuid.New()
+	opened := database.MarkNotificationOpenedParams{ID: trackingID, UserID: userID}
+	experimentOpen := database.RecordExperimentOpenParams{MessageID: trackingID, UserID: userID}
+
+	testCases := []struct {
+		name         string
+		trackingID   string
+		setupMocks   func(*mocks.MockQueries)
+		expectedCode codes.Code
+	}{
+		{
+			name:       "Opened",
+			trackingID: trackingID.String(),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("MarkNotificationOpened", mock.Anything, opened).Return(database.MarkNotificationOpenedRow{ID: trackingID, UserID: userID, FirstOpen: true}, nil).Once()
+				db.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(nil).Once()
+				db.On("RecordExperimentOpen", mock.Anything, experimentOpen).Return(int64(0), nil).Once()
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:       "Not delivered to the user",
+			trackingID: trackingID.String(),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("MarkNotificationOpened", mock.Anything, opened).Return(database.MarkNotificationOpenedRow{}, sql.ErrNoRows).Once()
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:         "Invalid tracking id",
+			trackingID:   "invalid-uuid",
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:       "Database error",
+			trackingID: trackingID.String(),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("MarkNotificationOpened", mock.Anything, opened).Return(database.MarkNotificationOpenedRow{}, errors.New("database error")).Once()
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			tc.setupMocks(mockDB)
+			srv := newTestServer(t, mockDB)
+
+			_, err := srv.ReportNotificationOpened(context.Background(), &pb.ReportNotificationOpenedRequest{
+				TrackingId: tc.trackingID,
+				UserId:     userID.String(),
+			})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReportNotificationDismissed(t *testing.T) {
+	trackingID := uuid.New()
+	userID := uuid.New()
+
+	mockDB := mocks.NewMockQueries()
+	mockDB.On("MarkNotificationDismissed", mock.Anything, database.MarkNotificationDismissedParams{ID: trackingID, UserID: userID}).Return(int64(1), nil).Once()
+	srv := newTestServer(t, mockDB)
+
+	resp, err := srv.ReportNotificationDismissed(context.Background(), &pb.ReportNotificationDismissedRequest{
+		TrackingId: trackingID.String(),
+		UserId:     userID.String(),
+	})
+	require.NoError(t, err)
+	assert.True(t, resp.Status)
+
+	_, err = srv.ReportNotificationDismissed(context.Background(), &pb.ReportNotificationDismissedRequest{
+		TrackingId: trackingID.String(),
+		UserId:     "invalid-uuid",
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	mockDB.AssertExpectations(t)
+}
+
+func TestTrackerDeliveredForFallbacks(t *testing.T) {
+	seen := uuid.New()
+	unseen := uuid.New()
+
+	mockDB := mocks.NewMockQueries()
+	mockDB.On("IsNotificationSeen", mock.Anything, seen).Return(true, nil).Once()
+	mockDB.On("IsNotificationSeen", mock.Anything, unseen).Return(false, nil).Once()
+	tracker := engagement.NewTracker(mockDB)
+
+	delivered, err := tracker.Delivered(context.Background(), seen)
+	require.NoError(t, err)
+	assert.True(t, delivered)
+
+	delivered, err = tracker.Delivered(context.Background(), unseen)
+	require.NoError(t, err)
+	assert.False(t, delivered)
+	mockDB.AssertExpectations(t)
+}
+
+func TestGetEngagementStats(t *testing.T) {
+	since := time.Now().Add(-24 * time.Hour).UTC()
+
+	mockDB := mocks.NewMockQueries()
+	mockDB.On("GetEngagementByCategory", mock.Anything, mock.MatchedBy(since.Equal)).Return([]database.GetEngagementByCategoryRow{
+		{Category: "marketing", Delivered: 400, Opened: 20, Dismissed: 300},
+		{Category: "security", Delivered: 10, Opened: 9},
+	}, nil).Once()
+	mockDB.On("GetEngagementByCategory", mock.Anything, time.Time{}).Return([]database.GetEngagementByCategoryRow{}, nil).Once()
+	srv := newTestServer(t, mockDB)
+
+	resp, err := srv.GetEngagementStats(context.Background(), &pb.GetEngagementStatsRequest{Since: timestamppb.New(since)})
+	require.NoError(t, err)
+	require.Len(t, resp.Categories, 2)
+	assert.InDelta(t, 0.05, resp.Categories[0].OpenRate, 1e-9)
+	assert.InDelta(t, 0.75, resp.Categories[0].DismissRate, 1e-9)
+	assert.InDelta(t, 0.9, resp.Categories[1].OpenRate, 1e-9)
+
+	resp, err = srv.GetEngagementStats(context.Background(), &pb.GetEngagementStatsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Categories)
+	mockDB.AssertExpectations(t)
+}
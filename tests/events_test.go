@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/broker"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/events"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	"github.com/imhasandl/notification-service/internal/rabbitmq"
+	pb "github.com/imhasandl/notification-service/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// captureOutbox records the events the server queues in the outbox
+func captureOutbox(db *mocks.MockQueries) *[]events.Event {
+	queued := &[]events.Event{}
+	db.On("CreateOutboxMessage", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		message := args.Get(1).(database.CreateOutboxMessageParams)
+		var event events.Event
+		if err := json.Unmarshal(message.Body, &event); err == nil &&
+			message.Exchange == broker.EventsTopic &&
+			message.RoutingKey == event.Type &&
+			message.CorrelationID == event.CorrelationID {
+			*queued = append(*queued, event)
+		}
+	}).Return(nil)
+	return queued
+}
+
+func TestDeliveryStatusEvents(t *testing.T) {
+	receiverID := uuid.New()
+
+	testCases := []struct {
+		name           string
+		device         database.DeviceToken
+		expectedType   string
+		expectedReason string
+	}{
+		{
+			name:           "No device",
+			expectedType:   events.TypeSuppressed,
+			expectedReason: "no device token",
+		},
+		{
+			name:           "Push turned off",
+			device:         database.DeviceToken{DeviceToken: "token", PushPermission: "denied"},
+			expectedType:   events.TypeSuppressed,
+			expectedReason: "push permission denied on device",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			mockDB.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(tc.device, nil)
+			queued := captureOutbox(mockDB)
+			srv := newTestServer(t, mockDB)
+
+			ctx := events.WithCorrelationID(context.Background(), "chat-message-42")
+			_, err := srv.SendNotification(ctx, &pb.SendNotificationRequest{
+				Notification: []byte(`{"title": "New message", "receiver_id": "` + receiverID.String() + `", "category": "chat"}`),
+			})
+			require.NoError(t, err)
+
+			require.Len(t, *queued, 1)
+			event := (*queued)[0]
+			assert.Equal(t, tc.expectedType, event.Type)
+			assert.Equal(t, tc.expectedReason, event.Reason)
+			assert.Equal(t, "chat-message-42", event.CorrelationID)
+			assert.Equal(t, receiverID, event.ReceiverID)
+			assert.Equal(t, "chat", event.Category)
+		})
+	}
+}
+
+func TestOpenedEventIsQueuedOnce(t *testing.T) {
+	trackingID := uuid.New()
+	userID := uuid.New()
+	row := database.MarkNotificationOpenedRow{ID: trackingID, UserID: userID, Category: "chat", CorrelationID: "chat-message-42"}
+
+	mockDB := mocks.NewMockQueries()
+	first := row
+	first.FirstOpen = true
+	mockDB.On("MarkNotificationOpened", mock.Anything, mock.Anything).Return(first, nil).Once()
+	mockDB.On("MarkNotificationOpened", mock.Anything, mock.Anything).Return(row, nil).Once()
+	mockDB.On("RecordExperimentOpen", mock.Anything, mock.Anything).Return(int64(0), nil).Twice()
+	queued := captureOutbox(mockDB)
+	srv := newTestServer(t, mockDB)
+
+	for i := 0; i < 2; i++ {
+		_, err := srv.ReportNotificationOpened(context.Background(), &pb.ReportNotificationOpenedRequest{
+			TrackingId: trackingID.String(),
+			UserId:     userID.String(),
+		})
+		require.NoError(t, err)
+	}
+
+	require.Len(t, *queued, 1)
+	assert.Equal(t, events.TypeOpened, (*queued)[0].Type)
+	assert.Equal(t, trackingID, (*queued)[0].TrackingID)
+	assert.Equal(t, "chat-message-42", (*queued)[0].CorrelationID)
+	mockDB.AssertExpectations(t)
+}
+
+func TestRelayOnce(t *testing.T) {
+	pending := []database.Outbox{
+		{ID: uuid.New(), Exchange: broker.EventsTopic, RoutingKey: events.TypeSent, CorrelationID: "a", Body: []byte(`{}`)},
+		{ID: uuid.New(), Exchange: broker.EventsTopic, RoutingKey: events.TypeOpened, CorrelationID: "b", Body: []byte(`{}`)},
+	}
+	confirmed := func(message database.Outbox) interface{} {
+		return mock.MatchedBy(func(msg broker.Message) bool {
+			return msg.ID == message.ID.String() && msg.CorrelationID == message.CorrelationID && string(msg.Body) == string(message.Body)
+		})
+	}
+
+	testCases := []struct {
+		name              string
+		setupMocks        func(*mocks.MockQueries, *mocks.MockBroker)
+		expectedPublished int
+		shouldError       bool
+	}{
+		{
+			name: "Publishes in order",
+			setupMocks: func(db *mocks.MockQueries, b *mocks.MockBroker) {
+				db.On("ListPendingOutboxMessages", mock.Anything, int32(events.DefaultRelayBatchSize)).Return(pending, nil).Once()
+				for _, message := range pending {
+					b.On("Publish", mock.Anything, message.Exchange, message.RoutingKey, confirmed(message)).Return(nil).Once()
+					db.On("MarkOutboxMessagePublished", mock.Anything, message.ID).Return(nil).Once()
+				}
+			},
+			expectedPublished: 2,
+		},
+		{
+			name: "Stops at an unconfirmed message",
+			setupMocks: func(db *mocks.MockQueries, b *mocks.MockBroker) {
+				db.On("ListPendingOutboxMessages", mock.Anything, mock.Anything).Return(pending, nil).Once()
+				b.On("Publish", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(rabbitmq.ErrNotConfirmed).Once()
+				db.On("RecordOutboxFailure", mock.Anything, mock.MatchedBy(func(arg database.RecordOutboxFailureParams) bool {
+					return arg.ID == pending[0].ID && arg.LastError == rabbitmq.ErrNotConfirmed.Error() &&
+						arg.NextAttemptAt.After(time.Now())
+				})).Return(nil).Once()
+			},
+			shouldError: true,
+		},
+		{
+			name: "Parks a message out of attempts",
+			setupMocks: func(db *mocks.MockQueries, b *mocks.MockBroker) {
+				poison := pending[0]
+				poison.Attempts = events.DefaultMaxAttempts - 1
+				db.On("ListPendingOutboxMessages", mock.Anything, mock.Anything).Return([]database.Outbox{poison}, nil).Once()
+				b.On("Publish", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(rabbitmq.ErrNotConfirmed).Once()
+				db.On("ParkOutboxMessage", mock.Anything, database.ParkOutboxMessageParams{ID: poison.ID, LastError: rabbitmq.ErrNotConfirmed.Error()}).Return(nil).Once()
+			},
+			shouldError: true,
+		},
+		{
+			name: "Database error",
+			setupMocks: func(db *mocks.MockQueries, _ *mocks.MockBroker) {
+				db.On("ListPendingOutboxMessages", mock.Anything, mock.Anything).Return([]database.Outbox{}, errors.New("database error")).Once()
+			},
+			shouldError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			mockBroker := mocks.NewMockBroker()
+			tc.setupMocks(mockDB, mockBroker)
+
+			published, err := events.NewRelay(mockDB, mockBroker).RelayOnce(context.Background())
+			assert.Equal(t, tc.expectedPublished, published)
+			if tc.shouldError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockDB.AssertExpectations(t)
+			mockBroker.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, events.Backoff(1))
+	assert.Equal(t, 2*time.Second, events.Backoff(2))
+	assert.Equal(t, 8*time.Second, events.Backoff(4))
+	assert.Equal(t, 5*time.Minute, events.Backoff(events.DefaultMaxAttempts))
+}
@@ -0,0 +1,225 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/experiments"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	pb "github.com/imhasandl/notification-service/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// subjectLines is a 90/10 experiment on the title of a sale announcement
+var subjectLines = &experiments.Experiment{
+	Key: "spring-sale-title",
+	Variants: []experiments.Variant{
+		{Name: "control", Weight: 90, Title: "Spring sale", Content: "Everything is 20% off"},
+		{Name: "urgent", Weight: 10, Title: "Last day of the spring sale", Content: "Everything is 20% off"},
+	},
+}
+
+func TestAssignVariant(t *testing.T) {
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		userID := uuid.New()
+		variant := subjectLines.Assign(userID)
+		assert.Equal(t, variant, subjectLines.Assign(userID), "assignment must be stable for a user")
+		counts[variant.Name]++
+	}
+
+	assert.InDelta(t, 9000, counts["control"], 300)
+	assert.InDelta(t, 1000, counts["urgent"], 300)
+}
+
+func TestValidateExperiment(t *testing.T) {
+	testCases := []struct {
+		name       string
+		experiment experiments.Experiment
+	}{
+		{
+			name:       "Missing key",
+			experiment: experiments.Experiment{Variants: subjectLines.Variants},
+		},
+		{
+			name:       "Single variant",
+			experiment: experiments.Experiment{Key: "k", Variants: subjectLines.Variants[:1]},
+		},
+		{
+			name: "Duplicate names",
+			experiment: experiments.Experiment{Key: "k", Variants: []experiments.Variant{
+				{Name: "a", Weight: 1}, {Name: "a", Weight: 1},
+			}},
+		},
+		{
+			name: "Zero weight",
+			experiment: experiments.Experiment{Key: "k", Variants: []experiments.Variant{
+				{Name: "a", Weight: 1}, {Name: "b"},
+			}},
+		},
+	}
+
+	require.NoError(t, subjectLines.Validate())
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Error(t, tc.experiment.Validate())
+		})
+	}
+}
+
+func TestSendNotificationWithExperiment(t *testing.T) {
+	receiverID := uuid.New()
+	variant := subjectLines.Assign(receiverID)
+
+	mockDB := new(mocks.MockDBQuerier)
+	mockFirebase := new(mocks.MockFirebaseClient)
+	mockFCM := new(mocks.MockFCMClient)
+
+	mockDB.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(database.DeviceToken{DeviceToken: "token"}, nil)
+	mockFirebase.On("GetMessagingClient").Return(mockFCM)
+	expectTracking(&mockDB.Mock)
+
+	var messageID string
+	mockFCM.On("Send", mock.Anything, mock.MatchedBy(func(message *messaging.Message) bool {
+		messageID = message.Data["tracking_id"]
+		return message.Notification.Title == variant.Title &&
+			message.Data["experiment"] == subjectLines.Key &&
+			message.Data["variant"] == variant.Name
+	})).Return("message-id", nil).Once()
+	mockDB.On("CreateExperimentAssignment", mock.Anything, mock.MatchedBy(func(arg database.CreateExperimentAssignmentParams) bool {
+		return arg.MessageID.String() == messageID && arg.Variant == variant.Name && arg.UserID == receiverID
+	})).Return(nil).Once()
+
+	srv, err := server.NewServer(mockDB, mocks.NewMockBroker(), "test-path", mockFirebase)
+	require.NoError(t, err)
+
+	notificationBytes, err := json.Marshal(server.Notification{
+		Title:      "Ignored in favour of the variant",
+		ReceiverID: receiverID.String(),
+		SentAt:     time.Now(),
+		Experiment: subjectLines,
+	})
+	require.NoError(t, err)
+
+	_, err = srv.SendNotification(context.Background(), &pb.SendNotificationRequest{Notification: notificationBytes})
+	require.NoError(t, err)
+
+	mockDB.AssertExpectations(t)
+	mockFCM.AssertExpectations(t)
+}
+
+func TestSendNotificationWithInvalidExperiment(t *testing.T) {
+	srv := newTestServer(t, new(mocks.MockDBQuerier))
+
+	notificationBytes, err := json.Marshal(server.Notification{
+		ReceiverID: uuid.New().String(),
+		Experiment: &experiments.Experiment{Key: "k", Variants: subjectLines.Variants[:1]},
+	})
+	require.NoError(t, err)
+
+	_, err = srv.SendNotification(context.Background(), &pb.SendNotificationRequest{Notification: notificationBytes})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestReportExperimentEvent(t *testing.T) {
+	messageID := uuid.New()
+	userID := uuid.New()
+
+	testCases := []struct {
+		name         string
+		messageID    string
+		event        string
+		setupMocks   func(*mocks.MockQueries)
+		expectedCode codes.Code
+	}{
+		{
+			name:      "Opened",
+			messageID: messageID.String(),
+			event:     experiments.EventOpened,
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("RecordExperimentOpen", mock.Anything, database.RecordExperimentOpenParams{MessageID: messageID, UserID: userID}).Return(int64(1), nil).Once()
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:      "Clicked message of another user",
+			messageID: messageID.String(),
+			event:     experiments.EventClicked,
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("RecordExperimentClick", mock.Anything, database.RecordExperimentClickParams{MessageID: messageID, UserID: userID}).Return(int64(0), nil).Once()
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:         "Unknown event",
+			messageID:    messageID.String(),
+			event:        "shared",
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "Invalid message id",
+			messageID:    "invalid-uuid",
+			event:        experiments.EventOpened,
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:      "Database error",
+			messageID: messageID.String(),
+			event:     experiments.EventOpened,
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("RecordExperimentOpen", mock.Anything, mock.Anything).Return(int64(0), errors.New("database error")).Once()
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			tc.setupMocks(mockDB)
+			srv := newTestServer(t, mockDB)
+
+			_, err := srv.ReportExperimentEvent(context.Background(), &pb.ReportExperimentEventRequest{
+				MessageId: tc.messageID,
+				UserId:    userID.String(),
+				Event:     tc.event,
+			})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetExperimentStats(t *testing.T) {
+	mockDB := mocks.NewMockQueries()
+	mockDB.On("GetExperimentStats", mock.Anything, subjectLines.Key).Return([]database.GetExperimentStatsRow{
+		{Variant: "control", Sent: 200, Opened: 50, Clicked: 10},
+		{Variant: "urgent", Sent: 0},
+	}, nil).Once()
+	srv := newTestServer(t, mockDB)
+
+	resp, err := srv.GetExperimentStats(context.Background(), &pb.GetExperimentStatsRequest{Experiment: subjectLines.Key})
+	require.NoError(t, err)
+	require.Len(t, resp.Variants, 2)
+	assert.InDelta(t, 0.25, resp.Variants[0].OpenRate, 1e-9)
+	assert.InDelta(t, 0.05, resp.Variants[0].ClickRate, 1e-9)
+	assert.Zero(t, resp.Variants[1].OpenRate)
+
+	_, err = srv.GetExperimentStats(context.Background(), &pb.GetExperimentStatsRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	mockDB.AssertExpectations(t)
+}
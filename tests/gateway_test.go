@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/auth"
+	"github.com/imhasandl/notification-service/internal/gateway"
+	"github.com/imhasandl/notification-service/internal/hub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestGateway creates a gateway accepting the tokens the returned keys sign, like the gRPC server does
+func newTestGateway(t *testing.T, h *hub.Hub, opts ...gateway.Option) (*gateway.Gateway, *testKeys) {
+	t.Helper()
+	keys := newTestKeys(t)
+	jwks, err := auth.LoadJWKS(context.Background(), keys.path)
+	require.NoError(t, err)
+	return gateway.NewGateway(h, auth.NewAuthenticator(jwks, server.AccessPolicy()), opts...), keys
+}
+
+// waitForConnections blocks until the hub has the expected number of subscribers
+func waitForConnections(t *testing.T, h *hub.Hub, n int) {
+	t.Helper()
+	assert.Eventually(t, func() bool { return h.Connections() == n }, time.Second, 10*time.Millisecond)
+}
+
+func TestGatewayRejectsUnauthenticated(t *testing.T) {
+	h := hub.New(hub.DefaultBufferSize)
+	g, keys := newTestGateway(t, h)
+	srv := httptest.NewServer(g.Handler())
+	defer srv.Close()
+
+	// Only WebSocket upgrades may carry the token in the query string
+	userToken := keys.token(t, uuid.NewString())
+	for _, path := range []string{"/events", "/ws", "/events?access_token=garbage", "/events?access_token=" + userToken} {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, path)
+	}
+
+	testCases := []struct {
+		name         string
+		token        string
+		expectedCode int
+	}{
+		{name: "Token signed with another key", token: newTestKeys(t).token(t, uuid.NewString()), expectedCode: http.StatusUnauthorized},
+		{name: "Service token", token: keys.token(t, "chat-service", auth.ServiceRole), expectedCode: http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestGatewaySSE(t *testing.T) {
+	h := hub.New(hub.DefaultBufferSize)
+	g, keys := newTestGateway(t, h)
+	srv := httptest.NewServer(g.Handler())
+	defer srv.Close()
+
+	userID := uuid.New()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+keys.token(t, userID.String()))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	waitForConnections(t, h, 1)
+	h.Publish(userID, hub.Event{ID: "event-1", Title: "hello"})
+
+	reader := bufio.NewReader(resp.Body)
+	var frame []string
+	for len(frame) < 3 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		frame = append(frame, strings.TrimSpace(line))
+	}
+	assert.Equal(t, "id: event-1", frame[0])
+	assert.Equal(t, "event: notification", frame[1])
+	assert.Contains(t, frame[2], `"title":"hello"`)
+}
+
+func TestGatewayWebSocket(t *testing.T) {
+	h := hub.New(hub.DefaultBufferSize)
+	g, keys := newTestGateway(t, h)
+	srv := httptest.NewServer(g.Handler())
+	defer srv.Close()
+
+	userID := uuid.New()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?access_token=" + keys.token(t, userID.String())
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	waitForConnections(t, h, 1)
+	h.Publish(userID, hub.Event{ID: "event-1", Title: "hello"})
+
+	var event hub.Event
+	require.NoError(t, conn.ReadJSON(&event))
+	assert.Equal(t, "event-1", event.ID)
+	assert.Equal(t, "hello", event.Title)
+
+	// Closing the socket removes the subscription from the hub
+	conn.Close()
+	waitForConnections(t, h, 0)
+}
+
+func TestGatewayWebSocketOrigin(t *testing.T) {
+	h := hub.New(hub.DefaultBufferSize)
+	g, keys := newTestGateway(t, h, gateway.WithAllowedOrigins("https://app.example.com"))
+	srv := httptest.NewServer(g.Handler())
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?access_token=" + keys.token(t, uuid.NewString())
+	testCases := []struct {
+		name     string
+		origin   string
+		expectOK bool
+	}{
+		{name: "No origin", expectOK: true},
+		{name: "Allowed origin", origin: "https://app.example.com", expectOK: true},
+		{name: "Same host", origin: srv.URL, expectOK: true},
+		{name: "Other site", origin: "https://evil.example.com", expectOK: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			if tc.origin != "" {
+				header.Set("Origin", tc.origin)
+			}
+
+			conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+			if tc.expectOK {
+				require.NoError(t, err)
+				conn.Close()
+				return
+			}
+			require.Error(t, err)
+			require.NotNil(t, resp)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/hub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHubPublish(t *testing.T) {
+	h := hub.New(2)
+	userID := uuid.New()
+	otherUserID := uuid.New()
+
+	first := h.Subscribe(userID)
+	second := h.Subscribe(userID)
+	other := h.Subscribe(otherUserID)
+	assert.Equal(t, 3, h.Connections())
+
+	// Every subscription of the receiver gets the event, other users don't
+	delivered := h.Publish(userID, hub.Event{ID: "1", Title: "hello"})
+	assert.Equal(t, 2, delivered)
+	assert.Equal(t, "hello", (<-first.Events()).Title)
+	assert.Equal(t, "hello", (<-second.Events()).Title)
+	assert.Len(t, other.Events(), 0)
+
+	// Unsubscribed clients no longer receive events
+	h.Unsubscribe(second)
+	h.Unsubscribe(second)
+	assert.Equal(t, 1, h.Publish(userID, hub.Event{ID: "2"}))
+	assert.False(t, second.Slow())
+	assert.Equal(t, 2, h.Connections())
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	h := hub.New(1)
+	userID := uuid.New()
+	sub := h.Subscribe(userID)
+
+	assert.Equal(t, 1, h.Publish(userID, hub.Event{ID: "1"}))
+
+	// The buffer is full, so the next publish drops the subscriber instead of blocking
+	assert.Equal(t, 0, h.Publish(userID, hub.Event{ID: "2"}))
+
+	select {
+	case <-sub.Done():
+	default:
+		t.Fatal("expected slow subscriber to be removed")
+	}
+	assert.True(t, sub.Slow())
+	assert.Equal(t, 0, h.Connections())
+}
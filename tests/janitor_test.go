@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/janitor"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJanitorRunOnce(t *testing.T) {
+	counts := database.CountStaleDeviceTokensRow{Active: 4, Total: 7}
+
+	// cutoffWithin matches the cutoff passed to the store, which must be max age before now
+	cutoffWithin := func(maxAge time.Duration) interface{} {
+		return mock.MatchedBy(func(cutoff time.Time) bool {
+			return time.Since(cutoff.Add(maxAge)) < time.Minute
+		})
+	}
+
+	testCases := []struct {
+		name             string
+		config           janitor.Config
+		setupMocks       func(*mocks.MockQueries)
+		expectedStale    int64
+		expectedAffected int64
+		shouldError      bool
+	}{
+		{
+			name:   "Disables stale devices by default",
+			config: janitor.Config{},
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("CountStaleDeviceTokens", mock.Anything, cutoffWithin(janitor.DefaultMaxAge)).Return(counts, nil).Once()
+				db.On("DisableStaleDeviceTokens", mock.Anything, cutoffWithin(janitor.DefaultMaxAge)).Return(int64(4), nil).Once()
+			},
+			expectedStale:    4,
+			expectedAffected: 4,
+		},
+		{
+			name:   "Deletes stale devices including disabled ones",
+			config: janitor.Config{MaxAge: 30 * 24 * time.Hour, Action: janitor.ActionDelete},
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("CountStaleDeviceTokens", mock.Anything, cutoffWithin(30*24*time.Hour)).Return(counts, nil).Once()
+				db.On("DeleteStaleDeviceTokens", mock.Anything, mock.Anything).Return(make([]database.DeleteStaleDeviceTokensRow, 7), nil).Once()
+			},
+			expectedStale:    7,
+			expectedAffected: 7,
+		},
+		{
+			name:   "Dry run only reports",
+			config: janitor.Config{Action: janitor.ActionDelete, DryRun: true},
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("CountStaleDeviceTokens", mock.Anything, mock.Anything).Return(counts, nil).Once()
+			},
+			expectedStale:    7,
+			expectedAffected: 0,
+		},
+		{
+			name:   "Nothing stale",
+			config: janitor.Config{},
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("CountStaleDeviceTokens", mock.Anything, mock.Anything).Return(database.CountStaleDeviceTokensRow{}, nil).Once()
+			},
+		},
+		{
+			name:   "Database error",
+			config: janitor.Config{},
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("CountStaleDeviceTokens", mock.Anything, mock.Anything).Return(database.CountStaleDeviceTokensRow{}, errors.New("database error")).Once()
+			},
+			shouldError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			tc.setupMocks(mockDB)
+
+			j, err := janitor.NewJanitor(mockDB, tc.config)
+			require.NoError(t, err)
+
+			report, err := j.RunOnce(context.Background())
+			if tc.shouldError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedStale, report.Stale)
+				assert.Equal(t, tc.expectedAffected, report.Affected)
+			}
+
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestJanitorRejectsUnknownAction(t *testing.T) {
+	_, err := janitor.NewJanitor(mocks.NewMockQueries(), janitor.Config{Action: "archive"})
+	assert.Error(t, err)
+}
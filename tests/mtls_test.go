@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/imhasandl/notification-service/internal/mtls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// testCA issues certificates for the tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of a leaf with the serial number, common name and SPIFFE id
+func (ca *testCA) issue(t *testing.T, serial int64, commonName, spiffeID string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if spiffeID != "" {
+		uri, err := url.Parse(spiffeID)
+		require.NoError(t, err)
+		template.URIs = []*url.URL{uri}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeServerCert writes the server certificate and key, moving their modification time forward so a reload sees them
+func writeServerCert(t *testing.T, config mtls.Config, cert, key []byte, modified time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(config.CertFile, cert, 0o600))
+	require.NoError(t, os.WriteFile(config.KeyFile, key, 0o600))
+	require.NoError(t, os.Chtimes(config.CertFile, modified, modified))
+	require.NoError(t, os.Chtimes(config.KeyFile, modified, modified))
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	config := mtls.Config{
+		CertFile:     filepath.Join(dir, "server.pem"),
+		KeyFile:      filepath.Join(dir, "server-key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	require.NoError(t, os.WriteFile(config.ClientCAFile, ca.pem, 0o600))
+	cert, key := ca.issue(t, 10, "notification-service", "", x509.ExtKeyUsageServerAuth)
+	writeServerCert(t, config, cert, key, time.Now().Add(-time.Minute))
+
+	reloader, err := mtls.NewReloader(config)
+	require.NoError(t, err)
+	policy := mtls.Policy{"spiffe://cluster.local/ns/chat/sa/chat-service": {"Check"}, "billing": {"Watch"}}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(reloader.ServerConfig())),
+		grpc.ChainUnaryInterceptor(policy.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(policy.StreamInterceptor()),
+	)
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	defer grpcServer.Stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	check := func(clientCert, clientKey []byte) (*big.Int, error) {
+		clientTLS := &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: roots}
+		if clientCert != nil {
+			pair, err := tls.X509KeyPair(clientCert, clientKey)
+			require.NoError(t, err)
+			clientTLS.Certificates = []tls.Certificate{pair}
+		}
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var server peer.Peer
+		_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Peer(&server))
+		if info, ok := server.AuthInfo.(credentials.TLSInfo); ok && len(info.State.PeerCertificates) > 0 {
+			return info.State.PeerCertificates[0].SerialNumber, err
+		}
+		return nil, err
+	}
+
+	chatCert, chatKey := ca.issue(t, 20, "chat", "spiffe://cluster.local/ns/chat/sa/chat-service", x509.ExtKeyUsageClientAuth)
+	serial, err := check(chatCert, chatKey)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), serial.Int64())
+
+	billingCert, billingKey := ca.issue(t, 21, "billing", "", x509.ExtKeyUsageClientAuth)
+	_, err = check(billingCert, billingKey)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = check(nil, nil)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	otherCert, otherKey := newTestCA(t).issue(t, 22, "chat", "spiffe://cluster.local/ns/chat/sa/chat-service", x509.ExtKeyUsageClientAuth)
+	_, err = check(otherCert, otherKey)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// A rotated certificate is served to new connections once reloaded
+	cert, key = ca.issue(t, 11, "notification-service", "", x509.ExtKeyUsageServerAuth)
+	writeServerCert(t, config, cert, key, time.Now())
+	require.NoError(t, reloader.Reload())
+	serial, err = check(chatCert, chatKey)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), serial.Int64())
+
+	// A half-rotated key pair keeps the certificate in use
+	writeServerCert(t, config, cert, key[:len(key)/2], time.Now().Add(time.Minute))
+	assert.Error(t, reloader.Reload())
+	serial, err = check(chatCert, chatKey)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), serial.Int64())
+
+	// Browsers get the same certificate without being asked for one of their own
+	browserLis, err := tls.Listen("tcp", "127.0.0.1:0", reloader.BrowserConfig())
+	require.NoError(t, err)
+	defer browserLis.Close()
+	handshake := make(chan error, 1)
+	go func() {
+		conn, err := browserLis.Accept()
+		if err != nil {
+			handshake <- err
+			return
+		}
+		defer conn.Close()
+		handshake <- conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", browserLis.Addr().String(), &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: roots})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, <-handshake)
+	assert.Equal(t, int64(11), conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64())
+}
+
+func TestLoadClientPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"clients": {
+		"spiffe://cluster.local/ns/chat/sa/chat-service": ["SendNotification"],
+		"api-gateway": ["*"]
+	}}`), 0o600))
+
+	policy, err := mtls.LoadPolicy(path)
+	require.NoError(t, err)
+	assert.True(t, policy.Allows([]string{"spiffe://cluster.local/ns/chat/sa/chat-service"}, "/notification.NotificationService/SendNotification"))
+	assert.False(t, policy.Allows([]string{"spiffe://cluster.local/ns/chat/sa/chat-service"}, "/notification.NotificationService/SendToSegment"))
+	assert.True(t, policy.Allows([]string{"gateway.internal", "api-gateway"}, "/notification.NotificationService/RegisterDeviceToken"))
+	assert.False(t, policy.Allows(nil, "/notification.NotificationService/SendNotification"))
+}
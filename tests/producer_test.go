@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	"github.com/imhasandl/notification-service/producer"
+	pb "github.com/imhasandl/notification-service/protos"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProducerMessage(t *testing.T) {
+	receiverID := uuid.New()
+
+	msg, err := producer.Message(producer.Notification{
+		ReceiverID: receiverID,
+		Title:      "New message",
+		Content:    "Hi!",
+		Category:   "chat",
+	}, "chat-message-42")
+	require.NoError(t, err)
+
+	assert.Equal(t, "application/json", msg.ContentType)
+	assert.Equal(t, producer.Schema, msg.Headers["Schema"])
+	assert.Equal(t, amqp.Persistent, msg.DeliveryMode)
+	assert.Equal(t, "chat-message-42", msg.CorrelationId)
+	assert.NotEmpty(t, msg.MessageId)
+	assert.False(t, msg.Timestamp.IsZero())
+	assert.Contains(t, string(msg.Body), `"schema_version":2`)
+
+	// The body is what the service consumes from the queue
+	mockDB := mocks.NewMockQueries()
+	mockDB.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(database.DeviceToken{}, nil).Once()
+	expectTracking(&mockDB.Mock)
+	srv := newTestServer(t, mockDB)
+
+	_, err = srv.SendNotification(context.Background(), &pb.SendNotificationRequest{Notification: msg.Body})
+	require.NoError(t, err)
+	mockDB.AssertExpectations(t)
+}
+
+func TestProducerMessageWithoutReceiver(t *testing.T) {
+	_, err := producer.Message(producer.Notification{Title: "New message"}, "")
+	assert.ErrorIs(t, err, producer.ErrNoReceiver)
+}
+
+func TestProducerRoutingKey(t *testing.T) {
+	assert.Equal(t, "notification.chat", producer.RoutingKey("chat"))
+	assert.Equal(t, "notification.default", producer.RoutingKey(""))
+}
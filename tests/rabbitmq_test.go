@@ -0,0 +1,246 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imhasandl/notification-service/internal/rabbitmq"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsumerChannel hands out a delivery channel the test fills, cancelling closes it
+type fakeConsumerChannel struct {
+	deliveries chan amqp.Delivery
+	cancelled  bool
+	closed     bool
+}
+
+func newFakeConsumerChannel() *fakeConsumerChannel {
+	return &fakeConsumerChannel{deliveries: make(chan amqp.Delivery, rabbitmq.DefaultPrefetch)}
+}
+
+func (c *fakeConsumerChannel) Qos(int, int, bool) error { return nil }
+
+func (c *fakeConsumerChannel) Consume(string, string, bool, bool, bool, bool, amqp.Table) (<-chan amqp.Delivery, error) {
+	return c.deliveries, nil
+}
+
+func (c *fakeConsumerChannel) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	return receiver
+}
+
+func (c *fakeConsumerChannel) Cancel(string, bool) error {
+	c.cancelled = true
+	close(c.deliveries)
+	return nil
+}
+
+func (c *fakeConsumerChannel) Close() error {
+	c.closed = true
+	return nil
+}
+
+// fakeAcknowledger records how deliveries were settled
+type fakeAcknowledger struct {
+	mu       sync.Mutex
+	acked    []uint64
+	requeued []uint64
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, _ bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, _ bool, requeue bool) error {
+	return a.Reject(tag, requeue)
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if requeue {
+		a.requeued = append(a.requeued, tag)
+	}
+	return nil
+}
+
+func (a *fakeAcknowledger) settled() ([]uint64, []uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]uint64(nil), a.acked...), append([]uint64(nil), a.requeued...)
+}
+
+// blockedBlocker returns a blocker the broker has blocked and the channel unblocking it
+func blockedBlocker(t *testing.T) (*rabbitmq.Blocker, chan<- amqp.Blocking) {
+	blockings := make(chan amqp.Blocking)
+	t.Cleanup(func() { close(blockings) })
+	blocker := rabbitmq.NewBlocker(blockings)
+	require.False(t, blocker.Blocked())
+
+	blockings <- amqp.Blocking{Active: true, Reason: "low on memory"}
+	require.Eventually(t, blocker.Blocked, time.Second, 5*time.Millisecond)
+	return blocker, blockings
+}
+
+func TestConsumePausesWhileBlocked(t *testing.T) {
+	blocker, blockings := blockedBlocker(t)
+	ch := newFakeConsumerChannel()
+	acknowledger := &fakeAcknowledger{}
+
+	handled := make(chan uint64, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- rabbitmq.ConsumeChannel(ctx, ch, blocker, func(_ context.Context, delivery amqp.Delivery) error {
+			handled <- delivery.DeliveryTag
+			return nil
+		})
+	}()
+
+	ch.deliveries <- amqp.Delivery{Acknowledger: acknowledger, DeliveryTag: 1}
+	assert.Never(t, func() bool { return len(handled) > 0 }, 100*time.Millisecond, 10*time.Millisecond,
+		"deliveries must wait while the connection is blocked")
+
+	blockings <- amqp.Blocking{Active: false}
+	select {
+	case tag := <-handled:
+		assert.Equal(t, uint64(1), tag)
+	case <-time.After(time.Second):
+		t.Fatal("delivery not handled after the connection was unblocked")
+	}
+	assert.Eventually(t, func() bool {
+		acked, _ := acknowledger.settled()
+		return len(acked) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.True(t, ch.closed)
+}
+
+func TestConsumeRequeuesPrefetchedOnStop(t *testing.T) {
+	blocker, _ := blockedBlocker(t)
+	ch := newFakeConsumerChannel()
+	acknowledger := &fakeAcknowledger{}
+	for tag := uint64(1); tag <= 3; tag++ {
+		ch.deliveries <- amqp.Delivery{Acknowledger: acknowledger, DeliveryTag: tag}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- rabbitmq.ConsumeChannel(ctx, ch, blocker, func(context.Context, amqp.Delivery) error {
+			t.Error("no delivery must be handled while the connection is blocked")
+			return nil
+		})
+	}()
+
+	// The consumer holds the first delivery until it is cancelled
+	assert.Eventually(t, func() bool { return len(ch.deliveries) == 2 }, time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	acked, requeued := acknowledger.settled()
+	assert.Empty(t, acked)
+	assert.ElementsMatch(t, []uint64{1, 2, 3}, requeued)
+	assert.True(t, ch.cancelled)
+	assert.True(t, ch.closed)
+}
+
+func TestPublishWithoutMessageID(t *testing.T) {
+	err := (&rabbitmq.Publisher{}).Publish(context.Background(), rabbitmq.ExchangeName, "notification.default", amqp.Publishing{})
+	assert.ErrorIs(t, err, rabbitmq.ErrNoMessageID)
+}
+
+// fakeConfirmation is answered by the test
+type fakeConfirmation chan bool
+
+func (c fakeConfirmation) WaitContext(ctx context.Context) (bool, error) {
+	select {
+	case acked := <-c:
+		return acked, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// fakePublish is a message published on a fakePublishChannel with the confirmation to answer
+type fakePublish struct {
+	msg          amqp.Publishing
+	confirmation fakeConfirmation
+}
+
+// fakePublishChannel hands every publish to the test, which plays the connection's reader
+type fakePublishChannel struct {
+	published chan fakePublish
+	returns   chan amqp.Return
+}
+
+func (c *fakePublishChannel) PublishWithConfirm(_ context.Context, _, _ string, _ bool, msg amqp.Publishing) (rabbitmq.Confirmation, error) {
+	confirmation := make(fakeConfirmation, 1)
+	c.published <- fakePublish{msg: msg, confirmation: confirmation}
+	return confirmation, nil
+}
+
+func (c *fakePublishChannel) NotifyReturn(receiver chan amqp.Return) chan amqp.Return {
+	c.returns = receiver
+	return receiver
+}
+
+func (c *fakePublishChannel) Close() error {
+	close(c.returns)
+	return nil
+}
+
+// returnMessage hands a return over like the connection's reader, which blocks until it is received
+func (c *fakePublishChannel) returnMessage(t *testing.T, messageID string) {
+	t.Helper()
+	select {
+	case c.returns <- amqp.Return{MessageId: messageID, ReplyCode: amqp.NoRoute, ReplyText: "NO_ROUTE"}:
+	case <-time.After(time.Second):
+		t.Fatal("the publisher stopped receiving returns, the connection's reader would be stuck")
+	}
+}
+
+func TestPublishWithStaleReturn(t *testing.T) {
+	ch := &fakePublishChannel{published: make(chan fakePublish, 1)}
+	publisher := rabbitmq.NewChannelPublisher(ch, rabbitmq.WithMandatory())
+	defer publisher.Close()
+
+	publish := func(ctx context.Context, messageID string) <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			done <- publisher.Publish(ctx, rabbitmq.ExchangeName, "notification.default", amqp.Publishing{MessageId: messageID})
+		}()
+		return done
+	}
+
+	// A publish gives up before the broker answers, its return and confirmation come later
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := publish(ctx, "abandoned")
+	first := <-ch.published
+	cancel()
+	assert.ErrorIs(t, <-abandoned, context.Canceled)
+	ch.returnMessage(t, "abandoned")
+	first.confirmation <- true
+
+	// The stale return doesn't block the next unroutable publish or get blamed on a routable one
+	unroutable := publish(context.Background(), "unroutable")
+	second := <-ch.published
+	ch.returnMessage(t, "unroutable")
+	second.confirmation <- true
+	assert.ErrorIs(t, <-unroutable, rabbitmq.ErrUnroutable)
+
+	routable := publish(context.Background(), "routable")
+	third := <-ch.published
+	third.confirmation <- true
+	assert.NoError(t, <-routable)
+	assert.Equal(t, amqp.Persistent, third.msg.DeliveryMode)
+}
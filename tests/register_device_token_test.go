@@ -2,6 +2,7 @@ package tests
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"testing"
 	"time"
@@ -13,6 +14,7 @@ import (
 	pb "github.com/imhasandl/notification-service/protos"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRegisterDeviceToken(t *testing.T) {
@@ -20,6 +22,9 @@ func TestRegisterDeviceToken(t *testing.T) {
 	validUserID := uuid.New()
 	validDeviceToken := "device-token-123"
 	validDeviceType := "android"
+	previousUserID := uuid.New()
+	existingID := uuid.New()
+	now := time.Now()
 
 	// Define test cases
 	testCases := []struct {
@@ -30,6 +35,7 @@ func TestRegisterDeviceToken(t *testing.T) {
 		setupMocks        func(*mocks.MockQueries)
 		shouldReturnError bool
 		expectedResponse  *pb.DeviceToken
+		expectReassigned  bool
 	}{
 		{
 			name:        "Success case",
@@ -37,7 +43,8 @@ func TestRegisterDeviceToken(t *testing.T) {
 			deviceToken: validDeviceToken,
 			deviceType:  validDeviceType,
 			setupMocks: func(db *mocks.MockQueries) {
-				now := time.Now()
+				db.On("LockDeviceToken", mock.Anything, validDeviceToken).Return(database.DeviceToken{}, sql.ErrNoRows).Once()
+
 				returnedDeviceToken := database.DeviceToken{
 					ID:          uuid.New(),
 					UserID:      validUserID,
@@ -52,6 +59,96 @@ func TestRegisterDeviceToken(t *testing.T) {
 						params.DeviceToken == validDeviceToken &&
 						params.DeviceType == validDeviceType
 				})).Return(returnedDeviceToken, nil).Once()
+				db.On("EvictDeviceTokens", mock.Anything, database.EvictDeviceTokensParams{
+					UserID: validUserID,
+					ID:     returnedDeviceToken.ID,
+					Offset: server.DefaultMaxDevicesPerUser - 1,
+				}).Return([]database.DeviceToken{}, nil).Once()
+			},
+			shouldReturnError: false,
+			expectedResponse: &pb.DeviceToken{
+				UserId:      validUserID.String(),
+				DeviceToken: validDeviceToken,
+				DeviceType:  validDeviceType,
+			},
+		},
+		{
+			name:        "Re-registration by the same user",
+			userID:      validUserID.String(),
+			deviceToken: validDeviceToken,
+			deviceType:  validDeviceType,
+			setupMocks: func(db *mocks.MockQueries) {
+				existing := database.DeviceToken{ID: existingID, UserID: validUserID, DeviceToken: validDeviceToken, DeviceType: "ios", CreatedAt: now, UpdatedAt: now}
+				db.On("LockDeviceToken", mock.Anything, validDeviceToken).Return(existing, nil).Once()
+
+				claimed := existing
+				claimed.DeviceType = validDeviceType
+				db.On("ClaimDeviceToken", mock.Anything, database.ClaimDeviceTokenParams{
+					ID:             existingID,
+					UserID:         validUserID,
+					DeviceType:     validDeviceType,
+					PushPermission: "unknown",
+				}).Return(claimed, nil).Once()
+				db.On("EvictDeviceTokens", mock.Anything, mock.Anything).Return([]database.DeviceToken{}, nil).Once()
+			},
+			shouldReturnError: false,
+			expectedResponse: &pb.DeviceToken{
+				UserId:      validUserID.String(),
+				DeviceToken: validDeviceToken,
+				DeviceType:  validDeviceType,
+			},
+		},
+		{
+			name:        "Token taken over from another user",
+			userID:      validUserID.String(),
+			deviceToken: validDeviceToken,
+			deviceType:  validDeviceType,
+			setupMocks: func(db *mocks.MockQueries) {
+				existing := database.DeviceToken{ID: existingID, UserID: previousUserID, DeviceToken: validDeviceToken, DeviceType: validDeviceType, CreatedAt: now, UpdatedAt: now}
+				db.On("LockDeviceToken", mock.Anything, validDeviceToken).Return(existing, nil).Once()
+
+				db.On("CreateDeviceTokenTransfer", mock.Anything, mock.MatchedBy(func(params database.CreateDeviceTokenTransferParams) bool {
+					return params.FromUserID == previousUserID &&
+						params.ToUserID == validUserID &&
+						params.DeviceToken == validDeviceToken
+				})).Return(nil).Once()
+
+				claimed := existing
+				claimed.UserID = validUserID
+				db.On("ClaimDeviceToken", mock.Anything, database.ClaimDeviceTokenParams{
+					ID:             existingID,
+					UserID:         validUserID,
+					DeviceType:     validDeviceType,
+					PushPermission: "unknown",
+				}).Return(claimed, nil).Once()
+				db.On("EvictDeviceTokens", mock.Anything, mock.Anything).Return([]database.DeviceToken{}, nil).Once()
+			},
+			shouldReturnError: false,
+			expectedResponse: &pb.DeviceToken{
+				UserId:      validUserID.String(),
+				DeviceToken: validDeviceToken,
+				DeviceType:  validDeviceType,
+			},
+			expectReassigned: true,
+		},
+		{
+			name:        "Token registered concurrently by another user",
+			userID:      validUserID.String(),
+			deviceToken: validDeviceToken,
+			deviceType:  validDeviceType,
+			setupMocks: func(db *mocks.MockQueries) {
+				existing := database.DeviceToken{ID: existingID, UserID: previousUserID, DeviceToken: validDeviceToken, DeviceType: validDeviceType, CreatedAt: now, UpdatedAt: now}
+
+				// The first lock finds nothing, the insert loses the race and the second lock sees the winner
+				db.On("LockDeviceToken", mock.Anything, validDeviceToken).Return(database.DeviceToken{}, sql.ErrNoRows).Once()
+				db.On("RegisterDeviceToken", mock.Anything, mock.Anything).Return(database.DeviceToken{}, sql.ErrNoRows).Once()
+				db.On("LockDeviceToken", mock.Anything, validDeviceToken).Return(existing, nil).Once()
+				db.On("CreateDeviceTokenTransfer", mock.Anything, mock.Anything).Return(nil).Once()
+
+				claimed := existing
+				claimed.UserID = validUserID
+				db.On("ClaimDeviceToken", mock.Anything, mock.Anything).Return(claimed, nil).Once()
+				db.On("EvictDeviceTokens", mock.Anything, mock.Anything).Return([]database.DeviceToken{}, nil).Once()
 			},
 			shouldReturnError: false,
 			expectedResponse: &pb.DeviceToken{
@@ -59,6 +156,20 @@ func TestRegisterDeviceToken(t *testing.T) {
 				DeviceToken: validDeviceToken,
 				DeviceType:  validDeviceType,
 			},
+			expectReassigned: true,
+		},
+		{
+			name:        "Transfer audit fails",
+			userID:      validUserID.String(),
+			deviceToken: validDeviceToken,
+			deviceType:  validDeviceType,
+			setupMocks: func(db *mocks.MockQueries) {
+				existing := database.DeviceToken{ID: existingID, UserID: previousUserID, DeviceToken: validDeviceToken}
+				db.On("LockDeviceToken", mock.Anything, validDeviceToken).Return(existing, nil).Once()
+				db.On("CreateDeviceTokenTransfer", mock.Anything, mock.Anything).Return(errors.New("database error")).Once()
+			},
+			shouldReturnError: true,
+			expectedResponse:  nil,
 		},
 		{
 			name:              "Invalid UUID",
@@ -75,6 +186,7 @@ func TestRegisterDeviceToken(t *testing.T) {
 			deviceToken: validDeviceToken,
 			deviceType:  validDeviceType,
 			setupMocks: func(db *mocks.MockQueries) {
+				db.On("LockDeviceToken", mock.Anything, validDeviceToken).Return(database.DeviceToken{}, sql.ErrNoRows).Once()
 				db.On("RegisterDeviceToken", mock.Anything, mock.Anything).
 					Return(database.DeviceToken{}, errors.New("database error")).Once()
 			},
@@ -88,14 +200,16 @@ func TestRegisterDeviceToken(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// Create fresh mocks for each test case
 			mockDB := mocks.NewMockQueries()
-			mockRabbitmq := mocks.NewMockRabbitMQ()
+			mockBroker := mocks.NewMockBroker()
 			mockFirebase := mocks.NewMockFirebaseClient()
 
 			// Setup mocks according to test case
 			tc.setupMocks(mockDB)
+			expectNoTopics(mockDB)
 
 			// Create server with mocks
-			srv := server.NewServer(mockDB, mockRabbitmq, "test/path", mockFirebase)
+			srv, err := server.NewServer(mockDB, mockBroker, "test/path", mockFirebase)
+			require.NoError(t, err)
 
 			// Make the request
 			request := &pb.RegisterDeviceTokenRequest{
@@ -119,6 +233,7 @@ func TestRegisterDeviceToken(t *testing.T) {
 				assert.Equal(t, tc.expectedResponse.DeviceType, response.DeviceToken.DeviceType)
 				assert.NotNil(t, response.DeviceToken.CreatedAt)
 				assert.NotNil(t, response.DeviceToken.UpdatedAt)
+				assert.Equal(t, tc.expectReassigned, response.Reassigned)
 			}
 
 			// Verify all expectations were met
@@ -126,3 +241,140 @@ func TestRegisterDeviceToken(t *testing.T) {
 		})
 	}
 }
+
+func TestRegisterDeviceTokenMetadata(t *testing.T) {
+	userID := uuid.New()
+	mockDB := mocks.NewMockQueries()
+
+	mockDB.On("LockDeviceToken", mock.Anything, "device-token-123").Return(database.DeviceToken{}, sql.ErrNoRows).Once()
+	mockDB.On("RegisterDeviceToken", mock.Anything, mock.MatchedBy(func(params database.RegisterDeviceTokenParams) bool {
+		return params.AppVersion == "2.4.1" &&
+			params.OsVersion == "17.2" &&
+			params.Locale == "pt-BR" &&
+			params.Timezone == "America/Sao_Paulo" &&
+			params.DeviceModel == "iPhone15,2" &&
+			params.PushPermission == "granted"
+	})).Return(database.DeviceToken{
+		ID:             uuid.New(),
+		UserID:         userID,
+		DeviceToken:    "device-token-123",
+		DeviceType:     "ios",
+		AppVersion:     "2.4.1",
+		OsVersion:      "17.2",
+		Locale:         "pt-BR",
+		Timezone:       "America/Sao_Paulo",
+		DeviceModel:    "iPhone15,2",
+		PushPermission: "granted",
+	}, nil).Once()
+	mockDB.On("EvictDeviceTokens", mock.Anything, mock.Anything).Return([]database.DeviceToken{}, nil).Once()
+	expectNoTopics(mockDB)
+
+	srv, err := server.NewServer(mockDB, mocks.NewMockBroker(), "test/path", mocks.NewMockFirebaseClient())
+	require.NoError(t, err)
+
+	response, err := srv.RegisterDeviceToken(context.Background(), &pb.RegisterDeviceTokenRequest{
+		UserId:         userID.String(),
+		DeviceToken:    "device-token-123",
+		DeviceType:     "ios",
+		AppVersion:     "2.4.1",
+		OsVersion:      "17.2",
+		Locale:         "pt-BR",
+		Timezone:       "America/Sao_Paulo",
+		DeviceModel:    "iPhone15,2",
+		PushPermission: " Granted ",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "2.4.1", response.DeviceToken.AppVersion)
+	assert.Equal(t, "pt-BR", response.DeviceToken.Locale)
+	assert.Equal(t, "iPhone15,2", response.DeviceToken.DeviceModel)
+	assert.Equal(t, "granted", response.DeviceToken.PushPermission)
+	mockDB.AssertExpectations(t)
+}
+
+func TestRegisterDeviceTokenDeviceLimit(t *testing.T) {
+	userID := uuid.New()
+	registeredID := uuid.New()
+	evictedID := uuid.New()
+
+	testCases := []struct {
+		name            string
+		opts            []server.Option
+		setupMocks      func(*mocks.MockQueries)
+		expectedEvicted []string
+		shouldError     bool
+	}{
+		{
+			name: "Evicts the least recently seen device",
+			opts: []server.Option{server.WithMaxDevicesPerUser(3)},
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("EvictDeviceTokens", mock.Anything, database.EvictDeviceTokensParams{
+					UserID: userID,
+					ID:     registeredID,
+					Offset: 2,
+				}).Return([]database.DeviceToken{
+					{ID: evictedID, UserID: userID, DeviceToken: "old-tablet", LastSeenAt: time.Now().Add(-48 * time.Hour)},
+				}, nil).Once()
+			},
+			expectedEvicted: []string{"old-tablet"},
+		},
+		{
+			name: "Within the limit",
+			opts: []server.Option{server.WithMaxDevicesPerUser(3)},
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("EvictDeviceTokens", mock.Anything, mock.Anything).Return([]database.DeviceToken{}, nil).Once()
+			},
+			expectedEvicted: []string{},
+		},
+		{
+			name:            "Limit disabled",
+			opts:            []server.Option{server.WithMaxDevicesPerUser(0)},
+			setupMocks:      func(*mocks.MockQueries) {},
+			expectedEvicted: []string{},
+		},
+		{
+			name: "Eviction fails",
+			opts: []server.Option{server.WithMaxDevicesPerUser(3)},
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("EvictDeviceTokens", mock.Anything, mock.Anything).Return([]database.DeviceToken(nil), errors.New("database error")).Once()
+			},
+			shouldError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			mockDB.On("LockDeviceToken", mock.Anything, "new-phone").Return(database.DeviceToken{}, sql.ErrNoRows).Once()
+			mockDB.On("RegisterDeviceToken", mock.Anything, mock.Anything).Return(database.DeviceToken{
+				ID:          registeredID,
+				UserID:      userID,
+				DeviceToken: "new-phone",
+			}, nil).Once()
+			tc.setupMocks(mockDB)
+			expectNoTopics(mockDB)
+
+			srv, err := server.NewServer(mockDB, mocks.NewMockBroker(), "test/path", mocks.NewMockFirebaseClient(), tc.opts...)
+			require.NoError(t, err)
+
+			response, err := srv.RegisterDeviceToken(context.Background(), &pb.RegisterDeviceTokenRequest{
+				UserId:      userID.String(),
+				DeviceToken: "new-phone",
+				DeviceType:  "ios",
+			})
+
+			if tc.shouldError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				evicted := []string{}
+				for _, deviceToken := range response.EvictedDevices {
+					evicted = append(evicted, deviceToken.DeviceToken)
+				}
+				assert.Equal(t, tc.expectedEvicted, evicted)
+			}
+
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
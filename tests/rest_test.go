@@ -0,0 +1,256 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/auth"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	"github.com/imhasandl/notification-service/internal/rest"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// restRequest sends a request to the gateway, with the bearer token when one is given
+func restRequest(t *testing.T, handler http.Handler, method, path, body, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRESTGateway(t *testing.T) {
+	userID := uuid.New()
+	since := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		setupMocks     func(*mocks.MockQueries)
+		expectedStatus int
+		expectedCode   string
+		expectedReason rpcerr.Reason
+		expectedBody   string
+	}{
+		{
+			name:   "Path parameters",
+			method: http.MethodGet,
+			path:   "/v1/users/" + userID.String() + "/devices",
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("ListDeviceTokensByUserID", mock.Anything, userID).Return([]database.DeviceToken{
+					{ID: uuid.New(), UserID: userID, DeviceToken: "phone", DeviceType: "ios"},
+				}, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"device_token":"phone"`,
+		},
+		{
+			name:   "Query parameters",
+			method: http.MethodGet,
+			path:   "/v1/engagement?since=" + since.Format(time.RFC3339),
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("GetEngagementByCategory", mock.Anything, mock.MatchedBy(since.Equal)).Return([]database.GetEngagementByCategoryRow{
+					{Category: "chat", Delivered: 10, Opened: 5},
+				}, nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"open_rate":0.5`,
+		},
+		{
+			name:   "JSON body",
+			method: http.MethodPost,
+			path:   "/v1/notifications",
+			body:   `{"notification": "` + base64.StdEncoding.EncodeToString([]byte(`{"title": "Hi", "receiver_id": "`+userID.String()+`"}`)) + `"}`,
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("GetDeviceTokensByUserID", mock.Anything, userID).Return(database.DeviceToken{}, nil).Once()
+				db.On("CreateOutboxMessage", mock.Anything, mock.Anything).Return(nil).Once()
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"status":true`,
+		},
+		{
+			name:           "Unknown body field",
+			method:         http.MethodPost,
+			path:           "/v1/notifications",
+			body:           `{"receiver": "someone"}`,
+			setupMocks:     func(*mocks.MockQueries) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "InvalidArgument",
+			expectedReason: rpcerr.ReasonMalformedJSON,
+		},
+		{
+			name:           "Invalid argument",
+			method:         http.MethodGet,
+			path:           "/v1/users/not-a-uuid/devices",
+			setupMocks:     func(*mocks.MockQueries) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "InvalidArgument",
+			expectedReason: rpcerr.ReasonInvalidArgument,
+			expectedBody:   `"field_violations":[{"field":"user_id","description":"must be a UUID"}]`,
+		},
+		{
+			name:   "Internal error",
+			method: http.MethodGet,
+			path:   "/v1/users/" + userID.String() + "/devices",
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("ListDeviceTokensByUserID", mock.Anything, userID).Return([]database.DeviceToken{}, errors.New("database error")).Once()
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   "Internal",
+			expectedReason: rpcerr.ReasonInternal,
+		},
+		{
+			name:   "Retryable error",
+			method: http.MethodGet,
+			path:   "/v1/users/" + userID.String() + "/devices",
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("ListDeviceTokensByUserID", mock.Anything, userID).Return([]database.DeviceToken{}, driver.ErrBadConn).Once()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   "Unavailable",
+			expectedReason: rpcerr.ReasonDatabaseUnavailable,
+			expectedBody:   `"retry_after":"1s"`,
+		},
+		{
+			name:           "Unknown route",
+			method:         http.MethodGet,
+			path:           "/v1/unknown",
+			setupMocks:     func(*mocks.MockQueries) {},
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "NotFound",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			tc.setupMocks(mockDB)
+			handler := rest.NewGateway(newTestServer(t, mockDB)).Handler()
+
+			rec := restRequest(t, handler, tc.method, tc.path, tc.body, "")
+			assert.Equal(t, tc.expectedStatus, rec.Code, rec.Body.String())
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.Contains(t, rec.Body.String(), tc.expectedBody)
+			if tc.expectedCode != "" {
+				var body rest.ErrorBody
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, tc.expectedCode, body.Code)
+				assert.NotEmpty(t, body.Error)
+				if tc.expectedReason != "" {
+					assert.Equal(t, string(tc.expectedReason), body.Reason)
+				}
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRESTGatewayAuthentication(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks, err := auth.LoadJWKS(context.Background(), keys.path)
+	require.NoError(t, err)
+	authenticator := auth.NewAuthenticator(jwks, server.AccessPolicy())
+
+	userID := uuid.New()
+	path := "/v1/users/" + userID.String() + "/devices"
+
+	testCases := []struct {
+		name           string
+		token          string
+		expectedStatus int
+	}{
+		{name: "No token", expectedStatus: http.StatusUnauthorized},
+		{name: "Invalid token", token: "not-a-token", expectedStatus: http.StatusUnauthorized},
+		{name: "Another user", token: keys.token(t, uuid.NewString()), expectedStatus: http.StatusForbidden},
+		{name: "Same user", token: keys.token(t, userID.String()), expectedStatus: http.StatusOK},
+		{name: "Service", token: keys.token(t, "campaigns", auth.ServiceRole), expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			mockDB.On("ListDeviceTokensByUserID", mock.Anything, userID).Return([]database.DeviceToken{}, nil).Maybe()
+			handler := rest.NewGateway(newTestServer(t, mockDB),
+				rest.WithUnaryInterceptors(authenticator.UnaryInterceptor()),
+				rest.WithStreamInterceptors(authenticator.StreamInterceptor()),
+			).Handler()
+
+			rec := restRequest(t, handler, http.MethodGet, path, "", tc.token)
+			assert.Equal(t, tc.expectedStatus, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestRESTGatewayStreamsProgress(t *testing.T) {
+	mockDB := mocks.NewMockQueries()
+	mockDB.On("CountSegmentUsers", mock.Anything, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("ListSegmentUsers", mock.Anything, mock.Anything, uuid.Nil, mock.Anything).Return([]uuid.UUID{}, nil).Once()
+	handler := rest.NewGateway(newTestServer(t, mockDB)).Handler()
+
+	body, err := json.Marshal(map[string]string{
+		"segment":      base64.StdEncoding.EncodeToString([]byte(`{"field": "is_premium", "op": "eq", "value": true}`)),
+		"notification": base64.StdEncoding.EncodeToString([]byte(`{"title": "Premium perks"}`)),
+	})
+	require.NoError(t, err)
+
+	rec := restRequest(t, handler, http.MethodPost, "/v1/segments/notifications", string(body), "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+	var last map[string]interface{}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &last))
+	}
+	assert.Equal(t, true, last["done"])
+	assert.Equal(t, "0", last["total"])
+
+	// Errors before the first report are plain error responses
+	rec = restRequest(t, handler, http.MethodPost, "/v1/segments/notifications", `{"segment": "e30="}`, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockDB.AssertExpectations(t)
+}
+
+func TestRESTOpenAPI(t *testing.T) {
+	handler := rest.NewGateway(newTestServer(t, mocks.NewMockQueries())).Handler()
+
+	rec := restRequest(t, handler, http.MethodGet, "/openapi.json", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var document struct {
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &document))
+
+	for _, route := range rest.Routes {
+		operation, ok := document.Paths[route.Path][strings.ToLower(route.Method)]
+		if assert.True(t, ok, "%s %s", route.Method, route.Path) {
+			assert.Equal(t, route.RPC, operation["operationId"])
+		}
+	}
+	assert.Contains(t, document.Components.Schemas, "DeviceToken")
+	assert.Contains(t, document.Components.Schemas, "Error")
+}
@@ -0,0 +1,279 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	"github.com/imhasandl/notification-service/internal/routing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChannel returns a fixed outcome and records every message it was asked to send
+type fakeChannel struct {
+	name    string
+	outcome routing.Outcome
+	err     error
+	sent    chan routing.Message
+}
+
+func newFakeChannel(name string, outcome routing.Outcome, err error) *fakeChannel {
+	return &fakeChannel{name: name, outcome: outcome, err: err, sent: make(chan routing.Message, 10)}
+}
+
+func (c *fakeChannel) Name() string {
+	return c.name
+}
+
+func (c *fakeChannel) Send(_ context.Context, msg routing.Message) (routing.Outcome, string, error) {
+	c.sent <- msg
+	return c.outcome, "", c.err
+}
+
+func outcomes(report *routing.Report) []routing.Outcome {
+	var result []routing.Outcome
+	for _, d := range report.Decisions {
+		result = append(result, d.Outcome)
+	}
+	return result
+}
+
+func TestDispatcherChains(t *testing.T) {
+	testCases := []struct {
+		name             string
+		chain            []routing.Step
+		push             routing.Outcome
+		inApp            routing.Outcome
+		expectedOutcomes []routing.Outcome
+		expectDelivered  bool
+	}{
+		{
+			name: "Send on all",
+			chain: []routing.Step{
+				{Channel: "push", When: routing.Always},
+				{Channel: "in_app", When: routing.Always},
+			},
+			push:             routing.Delivered,
+			inApp:            routing.Delivered,
+			expectedOutcomes: []routing.Outcome{routing.Delivered, routing.Delivered},
+			expectDelivered:  true,
+		},
+		{
+			name: "Fallback when user has no device tokens",
+			chain: []routing.Step{
+				{Channel: "push"},
+				{Channel: "in_app", When: routing.NoTarget},
+			},
+			push:             routing.NoTargetFound,
+			inApp:            routing.Delivered,
+			expectedOutcomes: []routing.Outcome{routing.NoTargetFound, routing.Delivered},
+			expectDelivered:  true,
+		},
+		{
+			name: "No fallback when push found a device",
+			chain: []routing.Step{
+				{Channel: "push"},
+				{Channel: "in_app", When: routing.NoTarget},
+			},
+			push:             routing.Failed,
+			inApp:            routing.Delivered,
+			expectedOutcomes: []routing.Outcome{routing.Failed, routing.Skipped},
+			expectDelivered:  false,
+		},
+		{
+			name: "Fallback when push was rejected",
+			chain: []routing.Step{
+				{Channel: "push"},
+				{Channel: "in_app", When: routing.Undelivered},
+			},
+			push:             routing.Failed,
+			inApp:            routing.Delivered,
+			expectedOutcomes: []routing.Outcome{routing.Failed, routing.Delivered},
+			expectDelivered:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := &routing.Policy{
+				Default:    []routing.Step{{Channel: "in_app"}},
+				Categories: map[string][]routing.Step{"message": tc.chain},
+			}
+			dispatcher, err := routing.NewDispatcher(policy, nil, nil,
+				newFakeChannel("push", tc.push, nil),
+				newFakeChannel("in_app", tc.inApp, nil),
+			)
+			require.NoError(t, err)
+
+			report, err := dispatcher.Dispatch(context.Background(), routing.Message{ID: uuid.New(), Category: "message"})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedOutcomes, outcomes(report))
+			assert.Equal(t, tc.expectDelivered, report.Delivered())
+		})
+	}
+}
+
+func TestDispatcherDefaultChainAndErrors(t *testing.T) {
+	down := errors.New("database down")
+	testCases := []struct {
+		name             string
+		push             *fakeChannel
+		inApp            *fakeChannel
+		expectedOutcomes []routing.Outcome
+		expectErr        bool
+	}{
+		{
+			name:             "Push fails before in-app delivers",
+			push:             newFakeChannel("push", routing.Failed, down),
+			inApp:            newFakeChannel("in_app", routing.Delivered, nil),
+			expectedOutcomes: []routing.Outcome{routing.Failed, routing.Delivered},
+		},
+		{
+			name:             "In-app fails after push delivered",
+			push:             newFakeChannel("push", routing.Delivered, nil),
+			inApp:            newFakeChannel("in_app", routing.Failed, down),
+			expectedOutcomes: []routing.Outcome{routing.Delivered, routing.Failed},
+		},
+		{
+			name:             "Nothing delivered",
+			push:             newFakeChannel("push", routing.Failed, down),
+			inApp:            newFakeChannel("in_app", routing.NoTargetFound, nil),
+			expectedOutcomes: []routing.Outcome{routing.Failed, routing.NoTargetFound},
+			expectErr:        true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dispatcher, err := routing.NewDispatcher(routing.DefaultPolicy(), nil, nil, tc.push, tc.inApp)
+			require.NoError(t, err)
+
+			// Unknown categories use the default chain. Infrastructure errors are only surfaced when
+			// nothing was delivered, a retry would deliver again on the channels that worked.
+			report, err := dispatcher.Dispatch(context.Background(), routing.Message{ID: uuid.New(), Category: "unknown"})
+			if tc.expectErr {
+				assert.ErrorIs(t, err, down)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedOutcomes, outcomes(report))
+
+			var reasons []string
+			for _, decision := range report.Decisions {
+				reasons = append(reasons, decision.Reason)
+			}
+			assert.Contains(t, reasons, down.Error(), "the failed step is recorded in the report")
+		})
+	}
+}
+
+// fakeChecker reports a fixed delivery state for delayed fallbacks
+type fakeChecker struct {
+	delivered bool
+	err       error
+}
+
+func (c fakeChecker) Delivered(context.Context, uuid.UUID) (bool, error) {
+	return c.delivered, c.err
+}
+
+func TestDispatcherDelayedFallback(t *testing.T) {
+	policy := &routing.Policy{
+		Default: []routing.Step{
+			{Channel: "push"},
+			{Channel: "in_app", When: routing.Undelivered, After: routing.Duration(10 * time.Minute)},
+		},
+	}
+	now := time.Now()
+
+	testCases := []struct {
+		name          string
+		push          routing.Outcome
+		checker       fakeChecker
+		inAppErr      error
+		attempts      int32
+		expectSend    bool
+		expectRetry   bool
+		expectDeleted bool
+	}{
+		{name: "Still unseen", push: routing.Failed, expectSend: true, expectDeleted: true},
+		{name: "Push accepted but unseen", push: routing.Delivered, expectSend: true, expectDeleted: true},
+		{name: "Opened before the fallback", push: routing.Delivered, checker: fakeChecker{delivered: true}, expectDeleted: true},
+		{name: "Check failed", push: routing.Delivered, checker: fakeChecker{err: errors.New("database down")}, expectRetry: true},
+		{name: "Send failed", push: routing.Failed, inAppErr: errors.New("hub down"), expectSend: true, expectRetry: true},
+		{name: "Out of attempts", push: routing.Failed, inAppErr: errors.New("hub down"), attempts: 4, expectSend: true, expectDeleted: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := mocks.NewMockQueries()
+			inApp := newFakeChannel("in_app", routing.Delivered, tc.inAppErr)
+			dispatcher, err := routing.NewDispatcher(policy, db, tc.checker, newFakeChannel("push", tc.push, nil), inApp)
+			require.NoError(t, err)
+
+			// The delayed step is stored instead of kept in memory
+			var stored database.CreateRoutingFallbackParams
+			db.On("CreateRoutingFallback", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				stored = args.Get(1).(database.CreateRoutingFallbackParams)
+			}).Return(nil).Once()
+
+			msg := routing.Message{ID: uuid.New(), Category: routing.DefaultCategory, Title: "Sale"}
+			report, err := dispatcher.Dispatch(context.Background(), msg)
+			require.NoError(t, err)
+			assert.Equal(t, []routing.Outcome{tc.push, routing.Scheduled}, outcomes(report))
+			assert.Equal(t, "in_app", stored.Channel)
+			assert.WithinDuration(t, now.Add(10*time.Minute), stored.DueAt, time.Minute)
+			require.Len(t, inApp.sent, 0)
+
+			// Once due, the stored fallback runs from the database, e.g. after a restart
+			db.On("ListDueRoutingFallbacks", mock.Anything, mock.Anything).Return([]database.RoutingFallback{{
+				ID: stored.ID, MessageID: msg.ID, Channel: stored.Channel, Message: stored.Message, DueAt: stored.DueAt, Attempts: tc.attempts,
+			}}, nil).Once()
+			if tc.expectDeleted {
+				db.On("DeleteRoutingFallback", mock.Anything, stored.ID).Return(nil).Once()
+			}
+			if tc.expectRetry {
+				db.On("RetryRoutingFallback", mock.Anything, mock.MatchedBy(func(arg database.RetryRoutingFallbackParams) bool {
+					return arg.ID == stored.ID && arg.DueAt.After(stored.DueAt)
+				})).Return(nil).Once()
+			}
+
+			ran, err := dispatcher.RunDueFallbacks(context.Background(), stored.DueAt)
+			require.NoError(t, err)
+			assert.Equal(t, 1, ran)
+			if tc.expectSend {
+				require.Len(t, inApp.sent, 1)
+				assert.Equal(t, msg.Title, (<-inApp.sent).Title)
+			} else {
+				assert.Len(t, inApp.sent, 0)
+			}
+			db.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPolicyValidation(t *testing.T) {
+	channels := []routing.Channel{newFakeChannel("push", routing.Delivered, nil)}
+
+	_, err := routing.NewDispatcher(&routing.Policy{Default: []routing.Step{{Channel: "sms"}}}, nil, nil, channels...)
+	assert.ErrorContains(t, err, `unknown channel "sms"`)
+
+	_, err = routing.NewDispatcher(&routing.Policy{Default: []routing.Step{{Channel: "push", When: "sometimes"}}}, nil, nil, channels...)
+	assert.ErrorContains(t, err, `unknown condition "sometimes"`)
+
+	_, err = routing.NewDispatcher(&routing.Policy{Default: []routing.Step{{Channel: "push", After: routing.Duration(time.Minute)}}}, nil, nil, channels...)
+	assert.Error(t, err)
+
+	// Delayed steps can't be kept without a store
+	delayed := &routing.Policy{Default: []routing.Step{{Channel: "push", When: routing.Undelivered, After: routing.Duration(time.Minute)}}}
+	_, err = routing.NewDispatcher(delayed, nil, nil, channels...)
+	assert.ErrorContains(t, err, "delayed fallbacks need a fallback store")
+	_, err = routing.NewDispatcher(delayed, mocks.NewMockQueries(), fakeChecker{}, channels...)
+	assert.NoError(t, err)
+}
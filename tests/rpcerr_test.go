@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/internal/campaigns"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	"github.com/imhasandl/notification-service/internal/rabbitmq"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	pb "github.com/imhasandl/notification-service/protos"
+	"github.com/lib/pq"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorStatusDetails(t *testing.T) {
+	err := rpcerr.InvalidField("user_id", "must be a UUID").WithMetadata("rpc", "ListDeviceTokens").WithRetry(2 * time.Second)
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Equal(t, "invalid user_id: must be a UUID", st.Message())
+
+	var info *errdetails.ErrorInfo
+	var badRequest *errdetails.BadRequest
+	var retry *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			info = d
+		case *errdetails.BadRequest:
+			badRequest = d
+		case *errdetails.RetryInfo:
+			retry = d
+		}
+	}
+	require.NotNil(t, info)
+	assert.Equal(t, string(rpcerr.ReasonInvalidArgument), info.GetReason())
+	assert.Equal(t, rpcerr.Domain, info.GetDomain())
+	assert.Equal(t, "ListDeviceTokens", info.GetMetadata()["rpc"])
+	require.NotNil(t, badRequest)
+	require.Len(t, badRequest.GetFieldViolations(), 1)
+	assert.Equal(t, "user_id", badRequest.GetFieldViolations()[0].GetField())
+	require.NotNil(t, retry)
+	assert.Equal(t, 2*time.Second, retry.GetRetryDelay().AsDuration())
+
+	// Clients read the same error back from the status
+	received := rpcerr.FromStatus(st)
+	assert.Equal(t, rpcerr.ReasonInvalidArgument, received.Reason)
+	assert.Equal(t, []rpcerr.FieldViolation{{Field: "user_id", Description: "must be a UUID"}}, received.Violations)
+	assert.Equal(t, 2*time.Second, received.RetryDelay)
+
+	// Statuses from elsewhere get the reason of their code
+	assert.Equal(t, rpcerr.ReasonNotFound, rpcerr.ReasonOf(status.Error(codes.NotFound, "missing")))
+	assert.Equal(t, rpcerr.ReasonInternal, rpcerr.ReasonOf(errors.New("boom")))
+}
+
+func TestFromError(t *testing.T) {
+	typed := rpcerr.NotFound(rpcerr.ReasonCampaignNotFound, "campaign not found")
+
+	testCases := []struct {
+		name           string
+		err            error
+		expectedCode   codes.Code
+		expectedReason rpcerr.Reason
+		retryable      bool
+	}{
+		{name: "Already classified", err: fmt.Errorf("wrapped: %w", typed), expectedCode: codes.NotFound, expectedReason: rpcerr.ReasonCampaignNotFound},
+		{name: "Canceled", err: fmt.Errorf("query: %w", context.Canceled), expectedCode: codes.Canceled, expectedReason: rpcerr.ReasonCanceled},
+		{name: "No rows", err: sql.ErrNoRows, expectedCode: codes.NotFound, expectedReason: rpcerr.ReasonNotFound},
+		{name: "Bad connection", err: driver.ErrBadConn, expectedCode: codes.Unavailable, expectedReason: rpcerr.ReasonDatabaseUnavailable, retryable: true},
+		{name: "Unique violation", err: &pq.Error{Code: "23505"}, expectedCode: codes.AlreadyExists, expectedReason: rpcerr.ReasonAlreadyExists},
+		{name: "Serialization failure", err: &pq.Error{Code: "40001"}, expectedCode: codes.Aborted, expectedReason: rpcerr.ReasonConflict, retryable: true},
+		{name: "Database shutting down", err: &pq.Error{Code: "57P01"}, expectedCode: codes.Unavailable, expectedReason: rpcerr.ReasonDatabaseUnavailable, retryable: true},
+		{name: "Other database error", err: &pq.Error{Code: "42P01"}, expectedCode: codes.Internal, expectedReason: rpcerr.ReasonDatabaseError},
+		{name: "Unconfirmed publish", err: fmt.Errorf("publish: %w", rabbitmq.ErrNotConfirmed), expectedCode: codes.Unavailable, expectedReason: rpcerr.ReasonBrokerUnavailable, retryable: true},
+		{name: "Recoverable AMQP error", err: &amqp.Error{Code: amqp.ConnectionForced, Recover: true}, expectedCode: codes.Unavailable, expectedReason: rpcerr.ReasonBrokerUnavailable, retryable: true},
+		{name: "Fatal AMQP error", err: &amqp.Error{Code: amqp.AccessRefused}, expectedCode: codes.Internal, expectedReason: rpcerr.ReasonBrokerError},
+		{name: "Unknown", err: errors.New("boom"), expectedCode: codes.Internal, expectedReason: rpcerr.ReasonInternal},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := rpcerr.FromError(tc.err, "can't do it")
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			assert.Equal(t, tc.expectedReason, err.Reason)
+			assert.Equal(t, tc.retryable, err.RetryDelay > 0)
+			assert.True(t, errors.Is(err, tc.err) || err == typed)
+		})
+	}
+}
+
+func TestHandlerErrorReasons(t *testing.T) {
+	id := uuid.New()
+
+	testCases := []struct {
+		name           string
+		call           func(*testing.T, *mocks.MockQueries) error
+		expectedCode   codes.Code
+		expectedReason rpcerr.Reason
+		expectedField  string
+	}{
+		{
+			name: "Invalid id",
+			call: func(t *testing.T, db *mocks.MockQueries) error {
+				_, err := newTestServer(t, db).ListDeviceTokens(context.Background(), &pb.ListDeviceTokensRequest{UserId: "invalid-uuid"})
+				return err
+			},
+			expectedCode:   codes.InvalidArgument,
+			expectedReason: rpcerr.ReasonInvalidArgument,
+			expectedField:  "user_id",
+		},
+		{
+			name: "Malformed notification",
+			call: func(t *testing.T, db *mocks.MockQueries) error {
+				_, err := newTestServer(t, db).SendNotification(context.Background(), &pb.SendNotificationRequest{Notification: []byte("{")})
+				return err
+			},
+			expectedCode:   codes.InvalidArgument,
+			expectedReason: rpcerr.ReasonMalformedJSON,
+			expectedField:  "notification",
+		},
+		{
+			name: "Campaign in the wrong state",
+			call: func(t *testing.T, db *mocks.MockQueries) error {
+				db.On("StartCampaign", mock.Anything, id).Return(database.Campaign{}, sql.ErrNoRows).Once()
+				db.On("GetCampaign", mock.Anything, id).Return(database.Campaign{ID: id, Status: campaigns.StatusCompleted}, nil).Once()
+				_, err := newTestServer(t, db).StartCampaign(context.Background(), &pb.StartCampaignRequest{Id: id.String()})
+				return err
+			},
+			expectedCode:   codes.FailedPrecondition,
+			expectedReason: rpcerr.ReasonCampaignState,
+		},
+		{
+			name: "Database unavailable",
+			call: func(t *testing.T, db *mocks.MockQueries) error {
+				db.On("GetCampaign", mock.Anything, id).Return(database.Campaign{}, driver.ErrBadConn).Once()
+				_, err := newTestServer(t, db).GetCampaignStats(context.Background(), &pb.GetCampaignStatsRequest{Id: id.String()})
+				return err
+			},
+			expectedCode:   codes.Unavailable,
+			expectedReason: rpcerr.ReasonDatabaseUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			err := tc.call(t, mockDB)
+
+			received := rpcerr.FromStatus(status.Convert(err))
+			assert.Equal(t, tc.expectedCode, received.Code)
+			assert.Equal(t, tc.expectedReason, received.Reason)
+			if tc.expectedField != "" {
+				require.Len(t, received.Violations, 1)
+				assert.Equal(t, tc.expectedField, received.Violations[0].Field)
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestIsServerError(t *testing.T) {
+	for _, code := range []codes.Code{codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss} {
+		assert.True(t, rpcerr.IsServerError(code), code.String())
+	}
+	// Codes numbered above Internal aren't all server errors, nor are those below all client errors
+	for _, code := range []codes.Code{codes.InvalidArgument, codes.NotFound, codes.PermissionDenied, codes.ResourceExhausted, codes.Unauthenticated} {
+		assert.False(t, rpcerr.IsServerError(code), code.String())
+	}
+}
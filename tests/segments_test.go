@@ -0,0 +1,247 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	"github.com/imhasandl/notification-service/internal/segments"
+	pb "github.com/imhasandl/notification-service/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseSegment(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name           string
+		segment        string
+		expectedClause string
+		expectedArgs   []interface{}
+		shouldError    bool
+	}{
+		{
+			name:           "Premium users",
+			segment:        `{"field": "is_premium", "op": "eq", "value": true}`,
+			expectedClause: "is_premium = $1",
+			expectedArgs:   []interface{}{true},
+		},
+		{
+			name: "Unverified users created last week",
+			segment: `{"all": [
+				{"field": "is_verified", "op": "eq", "value": false},
+				{"field": "created_at", "op": "within", "value": "168h"}
+			]}`,
+			expectedClause: "(is_verified = $1) AND (created_at >= $2)",
+			expectedArgs:   []interface{}{false, now.Add(-168 * time.Hour)},
+		},
+		{
+			name: "Nested groups",
+			segment: `{"any": [
+				{"not": {"field": "username", "op": "prefix", "value": "test_"}},
+				{"field": "updated_at", "op": "lt", "value": "2025-01-01T00:00:00Z"}
+			]}`,
+			expectedClause: "(NOT (starts_with(username, $1))) OR (updated_at < $2)",
+			expectedArgs:   []interface{}{"test_", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:           "Everyone",
+			segment:        `{"all": []}`,
+			expectedClause: "TRUE",
+			expectedArgs:   []interface{}{},
+		},
+		{
+			name:        "Empty filter",
+			segment:     `{}`,
+			shouldError: true,
+		},
+		{
+			name:        "Unknown field",
+			segment:     `{"field": "password", "op": "eq", "value": "x"}`,
+			shouldError: true,
+		},
+		{
+			name:        "Injected field",
+			segment:     `{"field": "is_premium = true OR 1=1 --", "op": "eq", "value": true}`,
+			shouldError: true,
+		},
+		{
+			name:        "Unsupported operator",
+			segment:     `{"field": "is_premium", "op": "gt", "value": true}`,
+			shouldError: true,
+		},
+		{
+			name:        "Wrong value type",
+			segment:     `{"field": "is_premium", "op": "eq", "value": "yes"}`,
+			shouldError: true,
+		},
+		{
+			name:        "Field and group together",
+			segment:     `{"field": "is_premium", "op": "eq", "value": true, "all": []}`,
+			shouldError: true,
+		},
+		{
+			name:        "Unknown key",
+			segment:     `{"feild": "is_premium"}`,
+			shouldError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			segment, err := segments.Parse([]byte(tc.segment), now)
+			if tc.shouldError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			clause, args := segment.Clause()
+			assert.Equal(t, tc.expectedClause, clause)
+			assert.Equal(t, tc.expectedArgs, args)
+		})
+	}
+}
+
+// segmentStream collects the progress sent by SendToSegment
+type segmentStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	progress []*pb.SendToSegmentProgress
+}
+
+func (s *segmentStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *segmentStream) Send(progress *pb.SendToSegmentProgress) error {
+	s.progress = append(s.progress, progress)
+	return nil
+}
+
+func TestSendToSegment(t *testing.T) {
+	reachable := []uuid.UUID{uuid.New(), uuid.New()}
+	unreachable := uuid.New()
+
+	mockDB := mocks.NewMockQueries()
+	mockFirebase := mocks.NewMockFirebaseClient()
+	mockFCM := new(mocks.MockFCMClient)
+
+	mockDB.On("CountSegmentUsers", mock.Anything, mock.Anything).Return(int64(3), nil).Once()
+	mockDB.On("ListSegmentUsers", mock.Anything, mock.Anything, uuid.Nil, int32(2)).Return(reachable, nil).Once()
+	mockDB.On("ListSegmentUsers", mock.Anything, mock.Anything, reachable[1], int32(2)).Return([]uuid.UUID{unreachable}, nil).Once()
+	mockDB.On("ListSegmentUsers", mock.Anything, mock.Anything, unreachable, int32(2)).Return([]uuid.UUID{}, nil).Once()
+
+	for _, userID := range reachable {
+		mockDB.On("GetDeviceTokensByUserID", mock.Anything, userID).Return(database.DeviceToken{UserID: userID, DeviceToken: "token-" + userID.String()}, nil).Once()
+	}
+	mockDB.On("GetDeviceTokensByUserID", mock.Anything, unreachable).Return(database.DeviceToken{}, sql.ErrNoRows).Once()
+	mockFirebase.On("GetMessagingClient").Return(mockFCM)
+	mockFCM.On("Send", mock.Anything, mock.Anything).Return("message-id", nil).Twice()
+	expectTracking(&mockDB.Mock)
+
+	srv, err := server.NewServer(mockDB, mocks.NewMockBroker(), "test/path", mockFirebase)
+	require.NoError(t, err)
+
+	notification, err := json.Marshal(server.Notification{Title: "Premium perks", Content: "New perks are live", SentAt: time.Now()})
+	require.NoError(t, err)
+
+	stream := &segmentStream{ctx: context.Background()}
+	err = srv.SendToSegment(&pb.SendToSegmentRequest{
+		Segment:       []byte(`{"field": "is_premium", "op": "eq", "value": true}`),
+		Notification:  notification,
+		BatchSize:     2,
+		RatePerSecond: 1000,
+	}, stream)
+	require.NoError(t, err)
+
+	// One report per batch, then the final one
+	require.Len(t, stream.progress, 3)
+	assert.Equal(t, int64(2), stream.progress[0].Processed)
+	final := stream.progress[2]
+	assert.True(t, final.Done)
+	assert.Equal(t, int64(3), final.Total)
+	assert.Equal(t, int64(3), final.Processed)
+	assert.Equal(t, int64(2), final.Sent)
+	assert.Equal(t, int64(1), final.Skipped)
+	assert.Equal(t, int64(0), final.Failed)
+
+	mockDB.AssertExpectations(t)
+	mockFCM.AssertExpectations(t)
+}
+
+func TestSendToSegmentErrors(t *testing.T) {
+	notification, err := json.Marshal(server.Notification{Title: "Hello"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		segment      string
+		notification []byte
+		setupMocks   func(*mocks.MockQueries)
+		expectedCode codes.Code
+	}{
+		{
+			name:         "Invalid segment",
+			segment:      `{"field": "password", "op": "eq", "value": "x"}`,
+			notification: notification,
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "Invalid notification",
+			segment:      `{"all": []}`,
+			notification: []byte("{invalid"),
+			setupMocks:   func(*mocks.MockQueries) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "Database error",
+			segment:      `{"all": []}`,
+			notification: notification,
+			setupMocks: func(db *mocks.MockQueries) {
+				db.On("CountSegmentUsers", mock.Anything, mock.Anything).Return(int64(0), errors.New("database error")).Once()
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			tc.setupMocks(mockDB)
+			srv := newTestServer(t, mockDB)
+
+			err := srv.SendToSegment(&pb.SendToSegmentRequest{
+				Segment:      []byte(tc.segment),
+				Notification: tc.notification,
+			}, &segmentStream{ctx: context.Background()})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSegmentOptionsDefaults(t *testing.T) {
+	opts := segments.Options{}.WithDefaults()
+	assert.Equal(t, int32(segments.DefaultBatchSize), opts.BatchSize)
+	assert.Equal(t, int32(segments.DefaultRate), opts.Rate)
+
+	// Callers can't ask for more than the caps
+	opts = segments.Options{BatchSize: segments.MaxBatchSize + 1, Rate: 1_000_000}.WithDefaults()
+	assert.Equal(t, int32(segments.MaxBatchSize), opts.BatchSize)
+	assert.Equal(t, int32(segments.MaxRate), opts.Rate)
+}
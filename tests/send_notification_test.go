@@ -2,13 +2,18 @@ package tests
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
+	"firebase.google.com/go/v4/messaging"
 	"github.com/google/uuid"
 	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/database"
 	"github.com/imhasandl/notification-service/internal/mocks"
+	"github.com/imhasandl/notification-service/internal/routing"
 	pb "github.com/imhasandl/notification-service/protos"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -23,27 +28,47 @@ func TestSendNotification(t *testing.T) {
 		deviceToken   string
 		expectError   bool
 		errorContains string
-		setupMocks    func(*mocks.MockDBQuerier, *mocks.MockFCMClient)
+		setupMocks    func(*mocks.MockDBQuerier, *mocks.MockFirebaseClient, *mocks.MockFCMClient)
 	}{
 		{
 			name:        "Success case",
 			receiverID:  "f6b3f9cf-7e9c-48fe-aa1c-e5afbef59770",
 			deviceToken: "device-token-123",
 			expectError: false,
-			setupMocks: func(db *mocks.MockDBQuerier, fcm *mocks.MockFCMClient) {
+			setupMocks: func(db *mocks.MockDBQuerier, firebase *mocks.MockFirebaseClient, fcm *mocks.MockFCMClient) {
 				receiverID, _ := uuid.Parse("f6b3f9cf-7e9c-48fe-aa1c-e5afbef59770")
-				db.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return("device-token-123", nil)
+				firebase.On("GetMessagingClient").Return(fcm)
+				db.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(database.DeviceToken{DeviceToken: "device-token-123"}, nil)
 
 				// The key fix - use correct argument matchers
 				fcm.On("Send", mock.Anything, mock.AnythingOfType("*messaging.Message")).Return("message-id", nil)
 			},
 		},
+		{
+			name:        "No device token",
+			receiverID:  "f6b3f9cf-7e9c-48fe-aa1c-e5afbef59770",
+			expectError: false,
+			setupMocks: func(db *mocks.MockDBQuerier, _ *mocks.MockFirebaseClient, _ *mocks.MockFCMClient) {
+				receiverID, _ := uuid.Parse("f6b3f9cf-7e9c-48fe-aa1c-e5afbef59770")
+				db.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(database.DeviceToken{}, sql.ErrNoRows)
+			},
+		},
+		{
+			name:          "Database error",
+			receiverID:    "f6b3f9cf-7e9c-48fe-aa1c-e5afbef59770",
+			expectError:   true,
+			errorContains: "can't dispatch notification",
+			setupMocks: func(db *mocks.MockDBQuerier, _ *mocks.MockFirebaseClient, _ *mocks.MockFCMClient) {
+				receiverID, _ := uuid.Parse("f6b3f9cf-7e9c-48fe-aa1c-e5afbef59770")
+				db.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(database.DeviceToken{}, errors.New("connection refused"))
+			},
+		},
 		{
 			name:          "Invalid UUID",
 			receiverID:    "invalid-uuid",
 			expectError:   true,
-			errorContains: "can't parse receiver id",
-			setupMocks:    func(*mocks.MockDBQuerier, *mocks.MockFCMClient) {},
+			errorContains: "invalid notification.receiver_id",
+			setupMocks:    func(*mocks.MockDBQuerier, *mocks.MockFirebaseClient, *mocks.MockFCMClient) {},
 		},
 	}
 
@@ -52,18 +77,17 @@ func TestSendNotification(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// Create mocks
 			mockDB := new(mocks.MockDBQuerier)
-			mockRabbitMQ := new(mocks.MockRabbitMQClient)
+			mockBroker := mocks.NewMockBroker()
 			mockFirebase := new(mocks.MockFirebaseClient)
 			mockFCM := new(mocks.MockFCMClient)
 
-			// Setup mockFirebase to return mockFCM
-			mockFirebase.On("GetMessagingClient").Return(mockFCM)
-
 			// Setup test-specific mocks
-			tc.setupMocks(mockDB, mockFCM)
+			tc.setupMocks(mockDB, mockFirebase, mockFCM)
+			expectTracking(&mockDB.Mock)
 
 			// Create server with mocks
-			srv := server.NewServer(mockDB, mockRabbitMQ, "test-path", mockFirebase)
+			srv, err := server.NewServer(mockDB, mockBroker, "test-path", mockFirebase)
+			require.NoError(t, err)
 
 			// Create notification payload
 			notification := server.Notification{
@@ -104,3 +128,82 @@ func TestSendNotification(t *testing.T) {
 		})
 	}
 }
+
+func TestSendNotificationDeviceMetadata(t *testing.T) {
+	receiverID := uuid.New()
+
+	testCases := []struct {
+		name          string
+		device        database.DeviceToken
+		expectPush    bool
+		expectedTitle string
+	}{
+		{
+			name:          "Localized to device locale",
+			device:        database.DeviceToken{DeviceToken: "token", Locale: "pt_BR", AppVersion: "2.4.0", PushPermission: "granted"},
+			expectPush:    true,
+			expectedTitle: "Nova mensagem",
+		},
+		{
+			name:          "Falls back to language",
+			device:        database.DeviceToken{DeviceToken: "token", Locale: "es-MX", AppVersion: "2.4.0"},
+			expectPush:    true,
+			expectedTitle: "Nuevo mensaje",
+		},
+		{
+			name:          "Falls back to default content",
+			device:        database.DeviceToken{DeviceToken: "token", Locale: "de-DE", AppVersion: "2.10"},
+			expectPush:    true,
+			expectedTitle: "New message",
+		},
+		{
+			name:   "Skips devices with push disabled",
+			device: database.DeviceToken{DeviceToken: "token", PushPermission: "denied"},
+		},
+		{
+			name:   "Skips outdated app versions",
+			device: database.DeviceToken{DeviceToken: "token", AppVersion: "2.3.9"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDBQuerier)
+			mockFirebase := new(mocks.MockFirebaseClient)
+			mockFCM := new(mocks.MockFCMClient)
+
+			mockDB.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Return(tc.device, nil)
+			if tc.expectPush {
+				mockFirebase.On("GetMessagingClient").Return(mockFCM)
+				mockFCM.On("Send", mock.Anything, mock.MatchedBy(func(message *messaging.Message) bool {
+					return message.Notification.Title == tc.expectedTitle && message.Data["title"] == tc.expectedTitle
+				})).Return("message-id", nil).Once()
+			}
+			expectTracking(&mockDB.Mock)
+
+			srv, err := server.NewServer(mockDB, mocks.NewMockBroker(), "test-path", mockFirebase)
+			require.NoError(t, err)
+
+			notificationBytes, err := json.Marshal(server.Notification{
+				Title:         "New message",
+				ReceiverID:    receiverID.String(),
+				Content:       "You have a new message",
+				SentAt:        time.Now(),
+				MinAppVersion: "2.4",
+				Translations: map[string]routing.Translation{
+					"es":    {Title: "Nuevo mensaje", Content: "Tienes un nuevo mensaje"},
+					"pt-BR": {Title: "Nova mensagem", Content: "Você tem uma nova mensagem"},
+				},
+			})
+			require.NoError(t, err)
+
+			resp, err := srv.SendNotification(context.Background(), &pb.SendNotificationRequest{Notification: notificationBytes})
+			require.NoError(t, err)
+			assert.True(t, resp.Status)
+
+			mockDB.AssertExpectations(t)
+			mockFirebase.AssertExpectations(t)
+			mockFCM.AssertExpectations(t)
+		})
+	}
+}
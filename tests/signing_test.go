@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/broker"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	"github.com/imhasandl/notification-service/internal/signing"
+	"github.com/imhasandl/notification-service/producer"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fromPublishing converts a producer message to what the consumer receives from the broker
+func fromPublishing(msg amqp.Publishing) broker.Message {
+	headers := make(map[string]string, len(msg.Headers))
+	for name, value := range msg.Headers {
+		headers[name] = value.(string)
+	}
+	return broker.Message{ID: msg.MessageId, ContentType: msg.ContentType, Headers: headers, Body: msg.Body}
+}
+
+func TestConsumeVerifiesSignatures(t *testing.T) {
+	secret := []byte("chat-service-secret")
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	producers := []signing.Producer{
+		{ID: "chat", Algorithm: signing.HMACSHA256, Key: base64.StdEncoding.EncodeToString(secret), Categories: []string{"chat", "default"}},
+		{ID: "billing", Algorithm: signing.Ed25519, Key: base64.StdEncoding.EncodeToString(publicKey), Categories: []string{signing.AnyCategory}},
+	}
+	chat := producer.NewHMACSigner("chat", secret)
+	billing := producer.NewEd25519Signer("billing", privateKey)
+
+	testCases := []struct {
+		name           string
+		category       string
+		signer         *producer.Signer
+		tamper         func(*amqp.Publishing)
+		enforce        bool
+		expectedReason error
+	}{
+		{name: "HMAC", category: "chat", signer: chat, enforce: true},
+		{name: "Ed25519", category: "invoice", signer: billing, enforce: true},
+		{name: "Without category", signer: chat, enforce: true},
+		{name: "Unsigned", category: "chat", enforce: true, expectedReason: signing.ErrUnsigned},
+		{
+			name:     "Changed body",
+			category: "chat",
+			signer:   chat,
+			tamper: func(msg *amqp.Publishing) {
+				msg.Body = append(msg.Body[:len(msg.Body)-1], []byte(`, "title": "Spoofed"}`)...)
+			},
+			enforce:        true,
+			expectedReason: signing.ErrInvalidSignature,
+		},
+		{
+			name:           "Unknown producer",
+			category:       "chat",
+			signer:         producer.NewHMACSigner("marketing", secret),
+			enforce:        true,
+			expectedReason: signing.ErrUnknownProducer,
+		},
+		{name: "Category not allowed", category: "security", signer: chat, enforce: true, expectedReason: signing.ErrForbiddenCategory},
+		{name: "Unsigned without enforcement", category: "chat"},
+		{name: "Category not allowed without enforcement", category: "security", signer: chat},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			receiverID := uuid.New()
+			handled := make(chan struct{})
+			mockDB := mocks.NewMockQueries()
+			mockDB.On("GetDeviceTokensByUserID", mock.Anything, receiverID).Run(func(mock.Arguments) {
+				close(handled)
+			}).Return(database.DeviceToken{}, nil).Maybe()
+			expectTracking(&mockDB.Mock)
+
+			verifier, err := signing.NewVerifier(producers, tc.enforce)
+			require.NoError(t, err)
+			memory := broker.NewMemory()
+			srv, err := server.NewServer(mockDB, memory, "test/path", mocks.NewMockFirebaseClient(), server.WithVerifier(verifier))
+			require.NoError(t, err)
+			consume(t, srv)
+
+			msg, err := producer.Message(producer.Notification{ReceiverID: receiverID, Title: "New message", Category: tc.category}, "")
+			require.NoError(t, err)
+			if tc.signer != nil {
+				producer.Sign(&msg, tc.signer)
+			}
+			if tc.tamper != nil {
+				tc.tamper(&msg)
+			}
+			require.NoError(t, memory.Publish(context.Background(), broker.RequestsTopic, producer.RoutingKey(tc.category), fromPublishing(msg)))
+
+			if tc.expectedReason != nil {
+				require.Eventually(t, func() bool {
+					return len(memory.DeadLettered()) == 1
+				}, time.Second, 10*time.Millisecond)
+				assert.Contains(t, memory.DeadLettered()[0].Headers[broker.HeaderDeadLetterReason], tc.expectedReason.Error())
+				mockDB.AssertNotCalled(t, "GetDeviceTokensByUserID", mock.Anything, mock.Anything)
+				return
+			}
+
+			select {
+			case <-handled:
+			case <-time.After(time.Second):
+				t.Fatal("notification request was not handled")
+			}
+			assert.Empty(t, memory.DeadLettered())
+		})
+	}
+}
+
+func TestLoadProducers(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "producers.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"producers": [
+		{"id": "chat", "algorithm": "hmac-sha256", "key": "c2VjcmV0", "categories": ["chat"]},
+		{"id": "billing", "algorithm": "ed25519", "key": "`+base64.StdEncoding.EncodeToString(publicKey)+`", "categories": ["*"]}
+	]}`), 0o600))
+
+	producers, err := signing.LoadProducers(path)
+	require.NoError(t, err)
+	require.Len(t, producers, 2)
+	assert.Equal(t, []string{"chat"}, producers[0].Categories)
+	_, err = signing.NewVerifier(producers, true)
+	assert.NoError(t, err)
+
+	invalid := []signing.Producer{
+		{ID: "chat", Algorithm: signing.HMACSHA256, Key: "not base64!"},
+		{ID: "billing", Algorithm: signing.Ed25519, Key: "c2VjcmV0"},
+		{ID: "chat", Algorithm: "rsa", Key: "c2VjcmV0"},
+		{Algorithm: signing.HMACSHA256, Key: "c2VjcmV0"},
+	}
+	for _, p := range invalid {
+		_, err := signing.NewVerifier([]signing.Producer{p}, true)
+		assert.Error(t, err, p)
+	}
+	_, err = signing.NewVerifier([]signing.Producer{producers[0], producers[0]}, true)
+	assert.Error(t, err)
+}
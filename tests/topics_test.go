@@ -0,0 +1,306 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/database"
+	"github.com/imhasandl/notification-service/internal/janitor"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	pb "github.com/imhasandl/notification-service/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSubscribeToTopic(t *testing.T) {
+	userID := uuid.New()
+	devices := []database.DeviceToken{
+		{ID: uuid.New(), UserID: userID, DeviceToken: "phone"},
+		{ID: uuid.New(), UserID: userID, DeviceToken: "tablet"},
+	}
+
+	testCases := []struct {
+		name         string
+		userID       string
+		topic        string
+		setupMocks   func(*mocks.MockQueries, *mocks.MockFirebaseClient, *mocks.MockFCMClient)
+		expectedCode codes.Code
+	}{
+		{
+			name:   "Success case",
+			userID: userID.String(),
+			topic:  "creator-42",
+			setupMocks: func(db *mocks.MockQueries, firebase *mocks.MockFirebaseClient, fcm *mocks.MockFCMClient) {
+				db.On("CreateTopicSubscription", mock.Anything, mock.MatchedBy(func(params database.CreateTopicSubscriptionParams) bool {
+					return params.UserID == userID && params.Topic == "creator-42"
+				})).Return(nil).Once()
+				db.On("ListDeviceTokensByUserID", mock.Anything, userID).Return(devices, nil).Once()
+				firebase.On("GetMessagingClient").Return(fcm)
+				fcm.On("SubscribeToTopic", mock.Anything, []string{"phone", "tablet"}, "creator-42").Return(&messaging.TopicManagementResponse{
+					SuccessCount: 1,
+					FailureCount: 1,
+					Errors:       []*messaging.ErrorInfo{{Index: 1, Reason: "registration-token-not-registered"}},
+				}, nil).Once()
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "Invalid topic",
+			userID:       userID.String(),
+			topic:        "creators/42",
+			setupMocks:   func(*mocks.MockQueries, *mocks.MockFirebaseClient, *mocks.MockFCMClient) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "Invalid UUID",
+			userID:       "not-a-valid-uuid",
+			topic:        "creator-42",
+			setupMocks:   func(*mocks.MockQueries, *mocks.MockFirebaseClient, *mocks.MockFCMClient) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:   "FCM error",
+			userID: userID.String(),
+			topic:  "creator-42",
+			setupMocks: func(db *mocks.MockQueries, firebase *mocks.MockFirebaseClient, fcm *mocks.MockFCMClient) {
+				db.On("CreateTopicSubscription", mock.Anything, mock.Anything).Return(nil).Once()
+				db.On("ListDeviceTokensByUserID", mock.Anything, userID).Return(devices, nil).Once()
+				firebase.On("GetMessagingClient").Return(fcm)
+				fcm.On("SubscribeToTopic", mock.Anything, mock.Anything, "creator-42").Return(nil, errors.New("fcm unavailable")).Once()
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := mocks.NewMockQueries()
+			mockFirebase := mocks.NewMockFirebaseClient()
+			mockFCM := new(mocks.MockFCMClient)
+			tc.setupMocks(mockDB, mockFirebase, mockFCM)
+
+			srv, err := server.NewServer(mockDB, mocks.NewMockBroker(), "test/path", mockFirebase)
+			require.NoError(t, err)
+
+			response, err := srv.SubscribeToTopic(context.Background(), &pb.SubscribeToTopicRequest{
+				UserId: tc.userID,
+				Topic:  tc.topic,
+			})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode == codes.OK {
+				assert.Equal(t, int64(1), response.SuccessCount)
+				assert.Equal(t, int64(1), response.FailureCount)
+				assert.Equal(t, []string{"registration-token-not-registered"}, response.Errors)
+			}
+
+			mockDB.AssertExpectations(t)
+			mockFCM.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUnsubscribeFromTopic(t *testing.T) {
+	userID := uuid.New()
+	mockDB := mocks.NewMockQueries()
+	mockFirebase := mocks.NewMockFirebaseClient()
+	mockFCM := new(mocks.MockFCMClient)
+
+	mockDB.On("DeleteTopicSubscription", mock.Anything, database.DeleteTopicSubscriptionParams{
+		UserID: userID,
+		Topic:  "creator-42",
+	}).Return(nil).Once()
+	mockDB.On("ListDeviceTokensByUserID", mock.Anything, userID).Return([]database.DeviceToken{
+		{ID: uuid.New(), UserID: userID, DeviceToken: "phone"},
+	}, nil).Once()
+	mockFirebase.On("GetMessagingClient").Return(mockFCM)
+	mockFCM.On("UnsubscribeFromTopic", mock.Anything, []string{"phone"}, "creator-42").Return(&messaging.TopicManagementResponse{SuccessCount: 1}, nil).Once()
+
+	srv, err := server.NewServer(mockDB, mocks.NewMockBroker(), "test/path", mockFirebase)
+	require.NoError(t, err)
+
+	response, err := srv.UnsubscribeFromTopic(context.Background(), &pb.UnsubscribeFromTopicRequest{
+		UserId: userID.String(),
+		Topic:  "creator-42",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), response.SuccessCount)
+
+	mockDB.AssertExpectations(t)
+	mockFCM.AssertExpectations(t)
+}
+
+func TestSendToTopic(t *testing.T) {
+	notification, err := json.Marshal(server.Notification{
+		Title:          "New post",
+		SenderUsername: "creator",
+		Content:        "Check out my new post",
+		SentAt:         time.Now(),
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		topic        string
+		notification []byte
+		setupMocks   func(*mocks.MockFirebaseClient, *mocks.MockFCMClient)
+		expectedCode codes.Code
+	}{
+		{
+			name:         "Success case",
+			topic:        "creator-42",
+			notification: notification,
+			setupMocks: func(firebase *mocks.MockFirebaseClient, fcm *mocks.MockFCMClient) {
+				firebase.On("GetMessagingClient").Return(fcm)
+				fcm.On("Send", mock.Anything, mock.MatchedBy(func(message *messaging.Message) bool {
+					return message.Topic == "creator-42" &&
+						message.Token == "" &&
+						message.Notification.Title == "New post"
+				})).Return("message-id", nil).Once()
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "Invalid topic",
+			topic:        "",
+			notification: notification,
+			setupMocks:   func(*mocks.MockFirebaseClient, *mocks.MockFCMClient) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "Invalid JSON",
+			topic:        "creator-42",
+			notification: []byte("{invalid"),
+			setupMocks:   func(*mocks.MockFirebaseClient, *mocks.MockFCMClient) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "Title too long",
+			topic:        "creator-42",
+			notification: []byte(`{"title": "` + strings.Repeat("t", 257) + `", "content": "Check out my new post"}`),
+			setupMocks:   func(*mocks.MockFirebaseClient, *mocks.MockFCMClient) {},
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFirebase := mocks.NewMockFirebaseClient()
+			mockFCM := new(mocks.MockFCMClient)
+			tc.setupMocks(mockFirebase, mockFCM)
+
+			srv, err := server.NewServer(mocks.NewMockQueries(), mocks.NewMockBroker(), "test/path", mockFirebase)
+			require.NoError(t, err)
+
+			response, err := srv.SendToTopic(context.Background(), &pb.SendToTopicRequest{
+				Topic:        tc.topic,
+				Notification: tc.notification,
+			})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode == codes.OK {
+				assert.Equal(t, "message-id", response.MessageId)
+			}
+
+			mockFCM.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRegisterDeviceTokenSyncsTopics(t *testing.T) {
+	userID := uuid.New()
+	previousUserID := uuid.New()
+	existing := database.DeviceToken{ID: uuid.New(), UserID: previousUserID, DeviceToken: "shared-phone"}
+
+	mockDB := mocks.NewMockQueries()
+	mockFirebase := mocks.NewMockFirebaseClient()
+	mockFCM := new(mocks.MockFCMClient)
+
+	mockDB.On("LockDeviceToken", mock.Anything, "shared-phone").Return(existing, nil).Once()
+	mockDB.On("CreateDeviceTokenTransfer", mock.Anything, mock.Anything).Return(nil).Once()
+	claimed := existing
+	claimed.UserID = userID
+	mockDB.On("ClaimDeviceToken", mock.Anything, mock.Anything).Return(claimed, nil).Once()
+	mockDB.On("EvictDeviceTokens", mock.Anything, mock.Anything).Return([]database.DeviceToken{}, nil).Once()
+
+	// The device leaves the previous user's topics and joins the new user's
+	mockDB.On("ListTopicsByUserID", mock.Anything, previousUserID).Return([]string{"creator-1"}, nil).Once()
+	mockDB.On("ListTopicsByUserID", mock.Anything, userID).Return([]string{"creator-2", "creator-3"}, nil).Once()
+	mockFirebase.On("GetMessagingClient").Return(mockFCM)
+	mockFCM.On("UnsubscribeFromTopic", mock.Anything, []string{"shared-phone"}, "creator-1").Return(&messaging.TopicManagementResponse{SuccessCount: 1}, nil).Once()
+	mockFCM.On("SubscribeToTopic", mock.Anything, []string{"shared-phone"}, "creator-2").Return(&messaging.TopicManagementResponse{SuccessCount: 1}, nil).Once()
+	mockFCM.On("SubscribeToTopic", mock.Anything, []string{"shared-phone"}, "creator-3").Return(nil, errors.New("fcm unavailable")).Once()
+
+	srv, err := server.NewServer(mockDB, mocks.NewMockBroker(), "test/path", mockFirebase)
+	require.NoError(t, err)
+
+	// Topic sync failures are logged without failing the registration
+	response, err := srv.RegisterDeviceToken(context.Background(), &pb.RegisterDeviceTokenRequest{
+		UserId:      userID.String(),
+		DeviceToken: "shared-phone",
+		DeviceType:  "android",
+	})
+	require.NoError(t, err)
+	assert.True(t, response.Reassigned)
+
+	mockDB.AssertExpectations(t)
+	mockFCM.AssertExpectations(t)
+}
+
+func TestJanitorReportsDeletedDevicesPerUser(t *testing.T) {
+	firstUser := uuid.New()
+	secondUser := uuid.New()
+
+	mockDB := mocks.NewMockQueries()
+	mockDB.On("CountStaleDeviceTokens", mock.Anything, mock.Anything).Return(database.CountStaleDeviceTokensRow{Active: 3, Total: 3}, nil).Once()
+	mockDB.On("DeleteStaleDeviceTokens", mock.Anything, mock.Anything).Return([]database.DeleteStaleDeviceTokensRow{
+		{UserID: firstUser, DeviceToken: "old-phone"},
+		{UserID: secondUser, DeviceToken: "old-tablet"},
+		{UserID: firstUser, DeviceToken: "old-laptop"},
+	}, nil).Once()
+
+	deleted := make(map[uuid.UUID][]string)
+	j, err := janitor.NewJanitor(mockDB, janitor.Config{
+		Action: janitor.ActionDelete,
+		OnDelete: func(_ context.Context, userID uuid.UUID, tokens ...string) {
+			deleted[userID] = append(deleted[userID], tokens...)
+		},
+	})
+	require.NoError(t, err)
+
+	report, err := j.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), report.Affected)
+	assert.Equal(t, map[uuid.UUID][]string{
+		firstUser:  {"old-phone", "old-laptop"},
+		secondUser: {"old-tablet"},
+	}, deleted)
+}
+
+func TestSubscribeToTopicWithoutDevices(t *testing.T) {
+	userID := uuid.New()
+	mockDB := mocks.NewMockQueries()
+	mockDB.On("CreateTopicSubscription", mock.Anything, mock.Anything).Return(nil).Once()
+	mockDB.On("ListDeviceTokensByUserID", mock.Anything, userID).Return([]database.DeviceToken{}, nil).Once()
+
+	// The subscription is recorded so devices registered later join the topic, FCM isn't called yet
+	srv := newTestServer(t, mockDB)
+	response, err := srv.SubscribeToTopic(context.Background(), &pb.SubscribeToTopicRequest{
+		UserId: userID.String(),
+		Topic:  "creator-42",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), response.SuccessCount)
+
+	mockDB.AssertExpectations(t)
+}
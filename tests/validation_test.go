@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imhasandl/notification-service/cmd/server"
+	"github.com/imhasandl/notification-service/internal/mocks"
+	"github.com/imhasandl/notification-service/internal/rest"
+	"github.com/imhasandl/notification-service/internal/routing"
+	"github.com/imhasandl/notification-service/internal/rpcerr"
+	"github.com/imhasandl/notification-service/internal/validation"
+	pb "github.com/imhasandl/notification-service/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// violatedFields returns the fields named by the violations of a validation error
+func violatedFields(err error) []string {
+	var fields []string
+	for _, violation := range rpcerr.FromStatus(status.Convert(err)).Violations {
+		fields = append(fields, violation.Field)
+	}
+	return fields
+}
+
+func TestValidateRequests(t *testing.T) {
+	validator, err := validation.New()
+	require.NoError(t, err)
+
+	userID := uuid.NewString()
+	notification := []byte(`{"title": "Hi"}`)
+
+	testCases := []struct {
+		name           string
+		req            proto.Message
+		expectedFields []string
+	}{
+		{
+			name: "Valid registration",
+			req: &pb.RegisterDeviceTokenRequest{
+				UserId: userID, DeviceToken: "fcm:APA91b-token_1", DeviceType: "ios",
+				Locale: "pt-BR", Timezone: "America/Sao_Paulo", PushPermission: " Granted ",
+			},
+		},
+		{
+			name:           "Garbage registration",
+			req:            &pb.RegisterDeviceTokenRequest{UserId: "42", DeviceToken: "", DeviceType: "fridge", PushPermission: "maybe"},
+			expectedFields: []string{"user_id", "device_token", "device_type", "push_permission"},
+		},
+		{
+			name:           "Oversized device token",
+			req:            &pb.RegisterDeviceTokenRequest{UserId: userID, DeviceToken: strings.Repeat("a", 4097), DeviceType: "android"},
+			expectedFields: []string{"device_token"},
+		},
+		{
+			name:           "Device token with spaces",
+			req:            &pb.TouchDeviceTokenRequest{UserId: userID, DeviceToken: "not a token"},
+			expectedFields: []string{"device_token"},
+		},
+		{
+			name:           "Update to an unknown device type",
+			req:            &pb.UpdateDeviceTokenRequest{Id: uuid.NewString(), UserId: userID, DeviceToken: "phone", DeviceType: "IOS"},
+			expectedFields: []string{"device_type"},
+		},
+		{
+			name:           "Invalid topic",
+			req:            &pb.SubscribeToTopicRequest{UserId: userID, Topic: "news/today"},
+			expectedFields: []string{"topic"},
+		},
+		{
+			name:           "Empty notification",
+			req:            &pb.SendNotificationRequest{},
+			expectedFields: []string{"notification"},
+		},
+		{
+			name:           "Negative segment rate",
+			req:            &pb.SendToSegmentRequest{Segment: []byte(`{}`), Notification: notification, RatePerSecond: -1},
+			expectedFields: []string{"rate_per_second"},
+		},
+		{
+			name:           "Campaign without name",
+			req:            &pb.CreateCampaignRequest{Notification: notification, Segment: []byte(`{}`)},
+			expectedFields: []string{"name"},
+		},
+		{
+			name:           "Unknown experiment event",
+			req:            &pb.ReportExperimentEventRequest{MessageId: uuid.NewString(), UserId: userID, Event: "shared"},
+			expectedFields: []string{"event"},
+		},
+		{
+			name:           "Invalid tracking id",
+			req:            &pb.ReportNotificationOpenedRequest{TrackingId: "abc", UserId: userID},
+			expectedFields: []string{"tracking_id"},
+		},
+		{
+			name: "Unset since",
+			req:  &pb.GetEngagementStatsRequest{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validator.Validate(tc.req)
+			if len(tc.expectedFields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			assert.Equal(t, rpcerr.ReasonInvalidArgument, rpcerr.ReasonOf(err))
+			assert.ElementsMatch(t, tc.expectedFields, violatedFields(err))
+		})
+	}
+}
+
+func TestValidationInterceptor(t *testing.T) {
+	validator, err := validation.New()
+	require.NoError(t, err)
+	interceptor := validator.UnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/notification.NotificationService/ListDeviceTokens"}
+
+	called := false
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		called = true
+		return &pb.ListDeviceTokensResponse{}, nil
+	}
+
+	_, err = interceptor(context.Background(), &pb.ListDeviceTokensRequest{UserId: "not-a-uuid"}, info, handler)
+	require.Error(t, err)
+	assert.False(t, called, "invalid requests must not reach the handler")
+	assert.Equal(t, "invalid user_id: value must be a valid UUID", status.Convert(err).Message())
+	assert.Equal(t, []string{"user_id"}, violatedFields(err))
+
+	_, err = interceptor(context.Background(), &pb.ListDeviceTokensRequest{UserId: uuid.NewString()}, info, handler)
+	assert.NoError(t, err)
+	assert.True(t, called)
+}
+
+func TestRESTGatewayValidation(t *testing.T) {
+	validator, err := validation.New()
+	require.NoError(t, err)
+	handler := rest.NewGateway(newTestServer(t, mocks.NewMockQueries()),
+		rest.WithUnaryInterceptors(validator.UnaryInterceptor()),
+		rest.WithStreamInterceptors(validator.StreamInterceptor()),
+	).Handler()
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedFields []string
+	}{
+		{
+			name:           "Unary",
+			method:         http.MethodPost,
+			path:           "/v1/users/" + uuid.NewString() + "/devices",
+			body:           `{"device_token": "", "device_type": "toaster"}`,
+			expectedFields: []string{"device_token", "device_type"},
+		},
+		{
+			name:           "Stream",
+			method:         http.MethodPost,
+			path:           "/v1/segments/notifications",
+			body:           `{"segment": "e30=", "notification": "e30=", "batch_size": -5}`,
+			expectedFields: []string{"batch_size"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := restRequest(t, handler, tc.method, tc.path, tc.body, "")
+			assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+			var body rest.ErrorBody
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, string(rpcerr.ReasonInvalidArgument), body.Reason)
+			var fields []string
+			for _, violation := range body.FieldViolations {
+				fields = append(fields, violation.Field)
+			}
+			assert.ElementsMatch(t, tc.expectedFields, fields)
+		})
+	}
+}
+
+func TestNotificationContentLimits(t *testing.T) {
+	testCases := []struct {
+		name          string
+		notification  server.Notification
+		errorContains string
+	}{
+		{
+			name:          "Title too long",
+			notification:  server.Notification{Title: strings.Repeat("t", 257), Content: "Hi"},
+			errorContains: "title must be at most 256 characters",
+		},
+		{
+			name:          "Content too long",
+			notification:  server.Notification{Title: "Hi", Content: strings.Repeat("ç", 2049)},
+			errorContains: "content must be at most 2048 characters",
+		},
+		{
+			name: "Translation too long",
+			notification: server.Notification{Title: "Hi", Content: "Hi", Translations: map[string]routing.Translation{
+				"es": {Title: strings.Repeat("t", 257), Content: "Hola"},
+			}},
+			errorContains: `"es" translation title must be at most 256 characters`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newTestServer(t, mocks.NewMockQueries())
+			tc.notification.ReceiverID = uuid.NewString()
+			tc.notification.SentAt = time.Now()
+			payload, err := json.Marshal(tc.notification)
+			require.NoError(t, err)
+
+			_, err = srv.SendNotification(context.Background(), &pb.SendNotificationRequest{Notification: payload})
+			require.Error(t, err)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			assert.Equal(t, rpcerr.ReasonInvalidNotification, rpcerr.ReasonOf(err))
+			assert.Contains(t, err.Error(), tc.errorContains)
+		})
+	}
+}